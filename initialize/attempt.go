@@ -0,0 +1,24 @@
+package initialize
+
+import (
+	"context"
+	"time"
+
+	"github.com/ppxb/oreo-admin-go/pkg/attempt"
+	"github.com/ppxb/oreo-admin-go/pkg/global"
+	"github.com/ppxb/oreo-admin-go/pkg/log"
+)
+
+// Attempt limits password guessing, counts are in memory until redis connects in degraded mode, see attachRedis
+func Attempt(ctx context.Context) error {
+	cfg := global.Conf.Password.Attempts
+	global.Attempts = attempt.New(
+		attempt.WithRedis(global.Redis),
+		attempt.WithPrefix(global.AppName+"_attempt"),
+		attempt.WithWindow(time.Duration(cfg.Window)*time.Minute),
+		attempt.WithLimit(attempt.KindUser, cfg.MaxUser),
+		attempt.WithLimit(attempt.KindIp, cfg.MaxIp),
+	)
+	log.WithContext(ctx).WithComponent("init").Info("Initialize password attempt limit successfully", "maxUser", cfg.MaxUser, "maxIp", cfg.MaxIp, "window", cfg.Window)
+	return nil
+}
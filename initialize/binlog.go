@@ -0,0 +1,49 @@
+package initialize
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/ppxb/oreo-admin-go/pkg/cache_service"
+	"github.com/ppxb/oreo-admin-go/pkg/dialect"
+	"github.com/ppxb/oreo-admin-go/pkg/global"
+	"github.com/ppxb/oreo-admin-go/pkg/log"
+)
+
+// Binlog starts mirroring prefixed tables into redis for query.Redis in background
+func Binlog(ctx context.Context) error {
+	if !global.Conf.Redis.Enable || !global.Conf.Redis.EnableBinlog {
+		log.WithContext(ctx).WithComponent("init").Info("Binlog sync is not enabled")
+		return nil
+	}
+	if global.Conf.Mysql.Driver != dialect.Mysql {
+		log.WithContext(ctx).WithComponent("init").Warn("Binlog sync only supports mysql, skip", "driver", global.Conf.Mysql.Driver)
+		return nil
+	}
+	if global.Redis == nil {
+		log.WithContext(ctx).WithComponent("init").Warn("Binlog sync waits for redis to connect")
+		return nil
+	}
+
+	b, err := cache_service.New(
+		cache_service.WithCtx(ctx),
+		cache_service.WithRedis(global.Redis),
+		cache_service.WithLocker(global.Locker),
+		cache_service.WithUri(global.Conf.Mysql.DSN.FormatDSN()),
+		cache_service.WithPosKey(global.Conf.Redis.BinlogPos),
+		cache_service.WithTablePrefix(global.Conf.Mysql.TablePrefix+"_"),
+		cache_service.WithServerId(1000+global.Conf.System.MachineId),
+	)
+	if err != nil {
+		return errors.Wrap(err, "initialize binlog sync failed")
+	}
+
+	go func() {
+		if err := b.Run(); err != nil {
+			log.WithContext(ctx).WithError(err).WithComponent("binlog").Error("Binlog sync stopped")
+		}
+	}()
+	log.WithContext(ctx).WithComponent("init").Info("Binlog sync started")
+	return nil
+}
@@ -0,0 +1,195 @@
+package initialize
+
+import (
+	"context"
+	stdErrors "errors"
+	"fmt"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+
+	m "github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/pkg/errors"
+
+	"github.com/ppxb/oreo-admin-go/pkg/global"
+	"github.com/ppxb/oreo-admin-go/pkg/log"
+)
+
+const (
+	minRetryInterval      = 200 * time.Millisecond
+	maxRetryInterval      = 5 * time.Second
+	maxReconnectInterval  = 30 * time.Second
+	mysqlAccessDenied     = 1045
+	mysqlUnknownDatabase  = 1049
+	postgresInvalidAuth   = "28000"
+	postgresInvalidPasswd = "28P01"
+	postgresUnknownDB     = "3D000"
+)
+
+// bootstrapped is closed when Bootstrap is done
+var bootstrapped = make(chan struct{})
+
+var (
+	ErrDNS             = errors.New("host can not be resolved")
+	ErrConnRefused     = errors.New("connection refused")
+	ErrAuth            = errors.New("authentication failed")
+	ErrUnknownDatabase = errors.New("unknown database")
+	ErrTimeout         = errors.New("connect timeout")
+	ErrUnknown         = errors.New("connect failed")
+)
+
+// Bootstrap runs the initializers in order, later ones use the globals set by earlier ones
+func Bootstrap(ctx context.Context) error {
+	initializers := []func(ctx context.Context) error{
+		Mysql,
+		Redis,
+		Cache,
+		Attempt,
+		Lock,
+		Keyring,
+		Geo,
+		Binlog,
+		Worker,
+		WeChat,
+		Metrics,
+	}
+	for _, fn := range initializers {
+		if err := fn(ctx); err != nil {
+			return err
+		}
+	}
+	close(bootstrapped)
+	return nil
+}
+
+// BootstrapError explains why a component could not be connected, errors.Is(err, ErrAuth) etc. works on it
+type BootstrapError struct {
+	Component string
+	Kind      error
+	Attempts  int
+	Err       error
+}
+
+func (e *BootstrapError) Error() string {
+	return fmt.Sprintf("initialize %s failed: %s after %d attempt(s): %v", e.Component, e.Kind, e.Attempts, e.Err)
+}
+
+func (e *BootstrapError) Unwrap() error {
+	return e.Err
+}
+
+func (e *BootstrapError) Is(target error) bool {
+	return target == e.Kind
+}
+
+// connect retries fn with exponential backoff until System.ConnectTimeout is reached,
+// errors which can not be fixed by retrying(auth, unknown database) return immediately
+func connect(ctx context.Context, component string, fn func(ctx context.Context) error) error {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(global.Conf.System.ConnectTimeout)*time.Second)
+	defer cancel()
+
+	interval := minRetryInterval
+	for attempt := 1; ; attempt++ {
+		err := fn(ctx)
+		if err == nil {
+			if attempt > 1 {
+				log.WithContext(ctx).WithComponent("init").Info("Connect success", "target", component, "attempts", attempt)
+			}
+			return nil
+		}
+
+		kind := classifyError(err)
+		if kind == ErrAuth || kind == ErrUnknownDatabase {
+			return &BootstrapError{Component: component, Kind: kind, Attempts: attempt, Err: err}
+		}
+
+		log.WithContext(ctx).WithError(err).WithComponent("init").Warn("Connect failed, retry later", "target", component, "kind", kind, "attempt", attempt, "retryIn", interval)
+		select {
+		case <-ctx.Done():
+			if kind == ErrUnknown {
+				kind = ErrTimeout
+			}
+			return &BootstrapError{Component: component, Kind: kind, Attempts: attempt, Err: err}
+		case <-time.After(interval):
+		}
+		interval = nextInterval(interval, maxRetryInterval)
+	}
+}
+
+// reconnect keeps retrying in background for degraded start, then runs ready once
+func reconnect(ctx context.Context, component string, fn func(ctx context.Context) error, ready func(ctx context.Context) error) {
+	go func() {
+		interval := minRetryInterval
+		for {
+			err := connect(ctx, component, fn)
+			if err == nil {
+				if err = ready(ctx); err == nil {
+					log.WithContext(ctx).WithComponent("init").Info("Reconnected, leave degraded mode", "target", component)
+					return
+				}
+			}
+			log.WithContext(ctx).WithError(err).WithComponent("init").Error("Still unavailable, running in degraded mode", "target", component)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(interval):
+			}
+			interval = nextInterval(interval, maxReconnectInterval)
+		}
+	}()
+}
+
+func nextInterval(interval, max time.Duration) time.Duration {
+	interval *= 2
+	if interval > max {
+		return max
+	}
+	return interval
+}
+
+func classifyError(err error) error {
+	var dnsErr *net.DNSError
+	if stdErrors.As(err, &dnsErr) {
+		return ErrDNS
+	}
+	if stdErrors.Is(err, syscall.ECONNREFUSED) {
+		return ErrConnRefused
+	}
+
+	var mysqlErr *m.MySQLError
+	if stdErrors.As(err, &mysqlErr) {
+		switch mysqlErr.Number {
+		case mysqlAccessDenied:
+			return ErrAuth
+		case mysqlUnknownDatabase:
+			return ErrUnknownDatabase
+		}
+	}
+
+	var pgErr *pgconn.PgError
+	if stdErrors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case postgresInvalidAuth, postgresInvalidPasswd:
+			return ErrAuth
+		case postgresUnknownDB:
+			return ErrUnknownDatabase
+		}
+	}
+
+	// redis returns plain errors
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "WRONGPASS"), strings.Contains(msg, "NOAUTH"), strings.Contains(msg, "invalid password"), strings.Contains(msg, "invalid username-password"):
+		return ErrAuth
+	case strings.Contains(msg, "DB index is out of range"):
+		return ErrUnknownDatabase
+	}
+
+	var netErr net.Error
+	if stdErrors.Is(err, context.DeadlineExceeded) || (stdErrors.As(err, &netErr) && netErr.Timeout()) {
+		return ErrTimeout
+	}
+	return ErrUnknown
+}
@@ -0,0 +1,24 @@
+package initialize
+
+import (
+	"context"
+
+	"github.com/ppxb/oreo-admin-go/pkg/cache"
+	"github.com/ppxb/oreo-admin-go/pkg/global"
+	"github.com/ppxb/oreo-admin-go/pkg/log"
+)
+
+// Cache is memory only until redis connects in degraded mode, see attachRedis
+func Cache(ctx context.Context) error {
+	global.Cache = cache.New(
+		cache.WithCtx(ctx),
+		cache.WithRedis(global.Redis),
+		cache.WithPrefix(global.AppName+"_cache"),
+	)
+	if global.Redis == nil {
+		log.WithContext(ctx).WithComponent("init").Info("Initialize cache in memory only")
+		return nil
+	}
+	log.WithContext(ctx).WithComponent("init").Info("Initialize cache successfully")
+	return nil
+}
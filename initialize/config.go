@@ -7,14 +7,17 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/spf13/viper"
 
 	"github.com/ppxb/oreo-admin-go/pkg/config"
 	"github.com/ppxb/oreo-admin-go/pkg/constant"
+	"github.com/ppxb/oreo-admin-go/pkg/dialect"
 	"github.com/ppxb/oreo-admin-go/pkg/global"
 	"github.com/ppxb/oreo-admin-go/pkg/log"
+	"github.com/ppxb/oreo-admin-go/pkg/password"
 	"github.com/ppxb/oreo-admin-go/pkg/utils"
 )
 
@@ -28,6 +31,7 @@ const (
 	defaultEnvPrefix      = "CFG"
 	defaultUrlPrefix      = "api"
 	defaultApiVersion     = "v1"
+	defaultMetricsPath    = "/metrics"
 )
 
 var (
@@ -37,6 +41,9 @@ var (
 		"CFG_JWT_REALM",
 		"CFG_JWT_KEY",
 		"CFG_UPLOAD_OSS_MINIO_SECRET",
+		"CFG_SYSTEM_ADMIN_TOKEN",
+		"CFG_SYSTEM_AMAP_KEY",
+		"CFG_WE_CHAT_OFFICIAL_APP_SECRET",
 	}
 )
 
@@ -51,12 +58,15 @@ func Config(ctx context.Context, conf embed.FS) {
 		panic(errors.Wrapf(err, "initialize config failed, config env: %s_CONF: %s", global.AppEnvName, confBox.Dir))
 	}
 
-	applyEnvOverrides()
-	setupLogger()
+	// built from the config files, it masks the env overrides while they are applied
+	redactor := newRedactor()
+	applyEnvOverrides(redactor)
+	setupLogger(redactor)
 	normalizeConfig()
+	setupPassword()
 	loadRSAKeys(ctx, confBox)
 
-	log.WithContext(ctx).Info("[INIT] Initialize config success, config env: `%s_CONF: /%s`", global.AppEnvName, confBox.Dir)
+	log.WithContext(ctx).WithComponent("init").Info("Initialize config success", "env", global.AppEnvName+"_CONF", "dir", "/"+confBox.Dir)
 }
 
 func initConfBox(ctx context.Context, conf embed.FS) config.ConfBox {
@@ -110,7 +120,7 @@ func getMode(env string) string {
 	return constant.Dev
 }
 
-func applyEnvOverrides() {
+func applyEnvOverrides(redactor *log.Redactor) {
 	envPrefix := strings.ToUpper(os.Getenv(fmt.Sprintf("%s_ENV", global.AppEnvName)))
 	if envPrefix == "" {
 		envPrefix = defaultEnvPrefix
@@ -119,21 +129,29 @@ func applyEnvOverrides() {
 	utils.EnvToInterface(
 		utils.WithEnvObj(&global.Conf),
 		utils.WithEnvPrefix(envPrefix),
-		utils.WithEnvFormat(formatEnvValue),
+		utils.WithEnvFormat(envValueFormatter(redactor)),
 	)
 }
 
-func formatEnvValue(key string, val interface{}) string {
-	if utils.Contains(sensitiveKeys, key) {
-		val = "******"
+// envValueFormatter masks sensitive keys, env keys matching redact fields are masked too, e.g. CFG_REDIS_PASSWORD
+func envValueFormatter(redactor *log.Redactor) func(key string, val interface{}) string {
+	return func(key string, val interface{}) string {
+		if utils.Contains(sensitiveKeys, key) || redactor.Key(key) {
+			val = "******"
+		}
+		return fmt.Sprintf("%s: %v", key, val)
 	}
-	return fmt.Sprintf("%s: %v", key, val)
 }
 
-func setupLogger() {
+func setupLogger(redactor *log.Redactor) {
 	log.DefaultWrapper = log.NewWrapper(log.New(
 		log.WithCategory(global.Conf.Logs.Category),
+		log.WithSinks(logSinks()...),
 		log.WithLevel(global.Conf.Logs.Level),
+		log.WithComponentLevels(global.Conf.Logs.ComponentLevels),
+		log.WithRedactor(redactor),
+		log.WithSampler(newSampler(global.Conf.Logs.Sampling.App)),
+		log.WithGormSampler(newSampler(global.Conf.Logs.Sampling.Gorm)),
 		log.WithJson(global.Conf.Logs.Json),
 		log.WithLineNumPrefix(global.RuntimeRoot),
 		log.WithLineNum(!global.Conf.Logs.LineNum.Disable),
@@ -143,6 +161,136 @@ func setupLogger() {
 	))
 }
 
+// newRedactor returns nil if redaction is disabled
+func newRedactor() *log.Redactor {
+	if !global.Conf.Logs.Redact.Enable {
+		return nil
+	}
+	r, err := log.NewRedactor(log.RedactOptions{
+		Fields:   global.Conf.Logs.Redact.Fields,
+		Patterns: global.Conf.Logs.Redact.Patterns,
+		Cards:    global.Conf.Logs.Redact.Cards,
+		Columns:  global.Conf.Logs.Redact.Columns,
+		Mask:     global.Conf.Logs.Redact.Mask,
+	})
+	if err != nil {
+		panic(errors.Wrap(err, "initialize log redactor failed"))
+	}
+	return r
+}
+
+// newSampler returns nil if the rule is disabled
+func newSampler(rule global.LogsSamplingRuleConfiguration) *log.Sampler {
+	if !rule.Enable {
+		return nil
+	}
+	return log.NewSampler(log.SampleOptions{
+		Tick:       time.Duration(global.Conf.Logs.Sampling.Tick) * time.Millisecond,
+		First:      rule.First,
+		Thereafter: rule.Thereafter,
+	})
+}
+
+func logSinks() []log.Sink {
+	sinks := make([]log.Sink, 0, len(global.Conf.Logs.Sinks))
+	for _, item := range global.Conf.Logs.Sinks {
+		// sinks without level follow the runtime level
+		sink := log.Sink{
+			Level: log.TraceLevel,
+			Json:  global.Conf.Logs.Json,
+		}
+		if item.Level != nil {
+			sink.Level = *item.Level
+		}
+		if item.Json != nil {
+			sink.Json = *item.Json
+		}
+		switch strings.ToLower(item.Type) {
+		case "", "stdout":
+			sink.Output = os.Stdout
+		case "stderr":
+			sink.Output = os.Stderr
+		case "file":
+			if item.Path == "" {
+				panic("path of log file sink is empty")
+			}
+			sink.Output = log.NewRotateFile(item.Path, log.RotateOptions{
+				MaxSize:    item.MaxSize,
+				MaxAge:     item.MaxAge,
+				MaxBackups: item.MaxBackups,
+				Compress:   item.Compress,
+			})
+		default:
+			panic(errors.Errorf("unknown log sink type %s", item.Type))
+		}
+		sinks = append(sinks, sink)
+	}
+	if global.Conf.Logs.Export.Enable {
+		if len(sinks) == 0 {
+			// keep the default stdout sink
+			sinks = append(sinks, log.Sink{Output: os.Stdout, Level: log.TraceLevel, Json: global.Conf.Logs.Json})
+		}
+		sinks = append(sinks, exportSink())
+	}
+	return sinks
+}
+
+func exportSink() log.Sink {
+	cfg := global.Conf.Logs.Export
+	headers := cfg.Headers
+	url := cfg.Endpoint
+	switch cfg.Protocol {
+	case log.ExportOtlp:
+		if url == "" {
+			url = global.Conf.Tracer.Endpoint
+			headers = global.Conf.Tracer.Headers
+		}
+		url = exportUrl(url, "/v1/logs")
+	case log.ExportLoki:
+		url = exportUrl(url, "/loki/api/v1/push")
+	}
+	e, err := log.NewExporter(log.ExportOptions{
+		Protocol:      cfg.Protocol,
+		Url:           url,
+		Headers:       headers,
+		Labels:        cfg.Labels,
+		BatchSize:     cfg.BatchSize,
+		BufferSize:    cfg.BufferSize,
+		FlushInterval: time.Duration(cfg.FlushInterval) * time.Millisecond,
+		MaxRetry:      cfg.MaxRetry,
+	})
+	if err != nil {
+		panic(errors.Wrap(err, "initialize log exporter failed"))
+	}
+	sink := log.Sink{
+		Output: e,
+		Level:  log.TraceLevel,
+		Json:   true,
+	}
+	if cfg.Level != nil {
+		sink.Level = *cfg.Level
+	}
+	return sink
+}
+
+// exportUrl completes an endpoint like 127.0.0.1:4318 with the scheme of tracer.insecure and the default path
+func exportUrl(endpoint, path string) string {
+	if endpoint == "" {
+		return ""
+	}
+	if !strings.Contains(endpoint, "://") {
+		scheme := "https://"
+		if global.Conf.Tracer.Insecure {
+			scheme = "http://"
+		}
+		endpoint = scheme + endpoint
+	}
+	if strings.Count(endpoint, "/") == 2 {
+		endpoint += path
+	}
+	return endpoint
+}
+
 func normalizeConfig() {
 	if global.Conf.System.ConnectTimeout < 1 {
 		global.Conf.System.ConnectTimeout = defaultConnectTimeout
@@ -158,22 +306,75 @@ func normalizeConfig() {
 
 	global.Conf.System.Base = fmt.Sprintf("/%s/%s", global.Conf.System.UrlPrefix, global.Conf.System.ApiVersion)
 
+	global.Conf.Mysql.Driver = strings.ToLower(strings.TrimSpace(global.Conf.Mysql.Driver))
+	if global.Conf.Mysql.Driver == "" {
+		global.Conf.Mysql.Driver = dialect.Mysql
+	}
+
 	global.Conf.Mysql.TablePrefix = strings.TrimSuffix(strings.TrimSpace(global.Conf.Mysql.TablePrefix), "_")
 
+	if strings.TrimSpace(global.Conf.Metrics.Path) == "" {
+		global.Conf.Metrics.Path = defaultMetricsPath
+	}
+
 	if !global.Conf.Redis.Enable {
 		global.Conf.Redis.EnableBinlog = false
+		global.Conf.Job.Enable = false
+	}
+}
+
+// setupPassword applies the configured policy and hash parameters, zero hash parameters keep the defaults
+func setupPassword() {
+	cfg := global.Conf.Password
+	password.DefaultPolicy = password.Policy{
+		MinLength:        cfg.MinLength,
+		MaxLength:        cfg.MaxLength,
+		RequireLower:     cfg.RequireLower,
+		RequireUpper:     cfg.RequireUpper,
+		RequireDigit:     cfg.RequireDigit,
+		RequireSymbol:    cfg.RequireSymbol,
+		MaxContinuousNum: cfg.MaxContinuousNum,
+		AllowChinese:     cfg.AllowChinese,
+		History:          cfg.History,
+		MaxAge:           time.Duration(cfg.MaxAge) * 24 * time.Hour,
+	}
+	h := password.DefaultHasher
+	switch strings.ToLower(cfg.Hash.Algorithm) {
+	case "", password.Argon2id:
+		h.Algorithm = password.Argon2id
+	case password.Bcrypt:
+		h.Algorithm = password.Bcrypt
+	default:
+		panic(errors.Errorf("unknown password hash algorithm %s", cfg.Hash.Algorithm))
+	}
+	if cfg.Hash.BcryptCost > 0 {
+		h.BcryptCost = cfg.Hash.BcryptCost
 	}
+	if cfg.Hash.Argon2.Memory > 0 {
+		h.Argon2.Memory = cfg.Hash.Argon2.Memory
+	}
+	if cfg.Hash.Argon2.Iterations > 0 {
+		h.Argon2.Iterations = cfg.Hash.Argon2.Iterations
+	}
+	if cfg.Hash.Argon2.Parallelism > 0 {
+		h.Argon2.Parallelism = cfg.Hash.Argon2.Parallelism
+	}
+	password.DefaultHasher = h
 }
 
 func loadRSAKeys(ctx context.Context, box config.ConfBox) {
 	loadRSAKey(ctx, box, &global.Conf.Jwt.RSAPublicBytes, global.Conf.Jwt.RSAPublicKey, "public")
 	loadRSAKey(ctx, box, &global.Conf.Jwt.RSAPrivateBytes, global.Conf.Jwt.RSAPrivateKey, "private")
+	for i := range global.Conf.Jwt.RSARetiredKeys {
+		item := &global.Conf.Jwt.RSARetiredKeys[i]
+		loadRSAKey(ctx, box, &item.RSAPrivateBytes, item.RSAPrivateKey, "retired")
+	}
 }
 
 func loadRSAKey(ctx context.Context, box config.ConfBox, target *[]byte, path, keyType string) {
 	data := box.Get(path)
 	if len(data) == 0 {
-		log.WithContext(ctx).Warn("[RSA] Read rsa %s file failed, please check path: %s", keyType, path)
+		log.WithContext(ctx).WithComponent("rsa").Warn("Read rsa file failed, please check path", "keyType", keyType, "path", path)
 		return
 	}
 	*target = data
@@ -1,3 +1,3 @@
 -- +migrate Up
 -- SQL in section 'Up' is executed when this migration is applied
-SELECT NOW();
\ No newline at end of file
+SELECT CURRENT_TIMESTAMP;
\ No newline at end of file
@@ -0,0 +1,31 @@
+package initialize
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/ppxb/oreo-admin-go/pkg/geo"
+	"github.com/ppxb/oreo-admin-go/pkg/global"
+	"github.com/ppxb/oreo-admin-go/pkg/log"
+)
+
+func Geo(ctx context.Context) error {
+	if global.Conf.System.AmapKey == "" && global.Conf.System.GeoFile == "" {
+		log.WithContext(ctx).WithComponent("init").Info("Geo lookup is not enabled")
+		return nil
+	}
+
+	g, err := geo.New(
+		geo.WithCtx(ctx),
+		geo.WithAmapKey(global.Conf.System.AmapKey),
+		geo.WithCache(global.Cache),
+		geo.WithFile(global.Conf.System.GeoFile),
+	)
+	if err != nil {
+		return errors.Wrap(err, "initialize geo failed")
+	}
+	global.Geo = g
+	log.WithContext(ctx).WithComponent("init").Info("Initialize geo successfully")
+	return nil
+}
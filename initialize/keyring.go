@@ -0,0 +1,46 @@
+package initialize
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/ppxb/oreo-admin-go/pkg/crypt"
+	"github.com/ppxb/oreo-admin-go/pkg/global"
+	"github.com/ppxb/oreo-admin-go/pkg/log"
+)
+
+// Keyring decrypts rsa encrypted credentials, used nonces are shared by redis if enabled
+func Keyring(ctx context.Context) error {
+	cfg := global.Conf.Jwt
+	if len(cfg.RSAPrivateBytes) == 0 {
+		if cfg.RSARequired {
+			return errors.New("initialize keyring failed: jwt.rsa-required needs jwt.rsa-private-key")
+		}
+		log.WithContext(ctx).WithComponent("init").Info("Rsa encrypted credentials are not enabled")
+		return nil
+	}
+	retired := make([]crypt.RetiredKey, 0, len(cfg.RSARetiredKeys))
+	for _, item := range cfg.RSARetiredKeys {
+		if len(item.RSAPrivateBytes) == 0 {
+			return errors.Errorf("initialize keyring failed: retired key %s is empty", item.Id)
+		}
+		retired = append(retired, crypt.RetiredKey{Id: item.Id, PrivateKey: item.RSAPrivateBytes})
+	}
+	k, err := crypt.NewKeyring(
+		cfg.RSAPrivateBytes,
+		cfg.RSAPublicBytes,
+		crypt.WithRedis(global.Redis),
+		crypt.WithPrefix(global.AppName+"_rsa_nonce"),
+		crypt.WithMaxSkew(time.Duration(cfg.RSAMaxSkew)*time.Second),
+		crypt.WithKeyId(cfg.RSAKeyId),
+		crypt.WithRetiredKeys(retired...),
+	)
+	if err != nil {
+		return errors.Wrap(err, "initialize keyring failed")
+	}
+	global.Keyring = k
+	log.WithContext(ctx).WithComponent("init").Info("Initialize keyring successfully", "keyId", k.Current().Id, "retired", len(retired))
+	return nil
+}
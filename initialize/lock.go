@@ -0,0 +1,57 @@
+package initialize
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/pkg/errors"
+
+	"github.com/ppxb/oreo-admin-go/pkg/dialect"
+	"github.com/ppxb/oreo-admin-go/pkg/global"
+	"github.com/ppxb/oreo-admin-go/pkg/lock"
+	"github.com/ppxb/oreo-admin-go/pkg/log"
+)
+
+// Lock prefers redis locks and falls back to mysql advisory locks, the backend is never switched
+// at runtime because nodes on different backends would hold the same lock
+func Lock(ctx context.Context) error {
+	var err error
+	switch {
+	case global.Redis != nil:
+		global.Locker, err = lock.NewRedis(
+			lock.WithRedis(global.Redis),
+			lock.WithPrefix(global.AppName+"_lock"),
+		)
+	case global.Mysql() != nil && global.Conf.Mysql.Driver == dialect.Mysql:
+		db, e := global.Mysql().DB()
+		if e != nil {
+			return errors.Wrap(e, "initialize lock failed")
+		}
+		global.Locker, err = newMysqlLocker(db)
+	case pendingRedis != nil:
+		// locks fail until redis connects, a memory lock would not exclude other nodes
+		global.Locker, err = lock.NewRedis(
+			lock.WithRedis(pendingRedis),
+			lock.WithPrefix(global.AppName+"_lock"),
+		)
+	case pendingMysql != nil:
+		// the same for mysql, the locker keeps its own pool after mysql connects
+		global.Locker, err = newMysqlLocker(pendingMysql)
+	default:
+		log.WithContext(ctx).WithComponent("init").Warn("Lock is not available without redis or mysql")
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "initialize lock failed")
+	}
+	log.WithContext(ctx).WithComponent("init").Info("Initialize lock successfully")
+	return nil
+}
+
+func newMysqlLocker(db *sql.DB) (lock.Locker, error) {
+	return lock.NewMysql(
+		db,
+		lock.WithPrefix(global.AppName+"_lock"),
+		lock.WithTable(global.Conf.Mysql.TablePrefix+"_lock_token"),
+	)
+}
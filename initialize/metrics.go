@@ -0,0 +1,88 @@
+package initialize
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/pprof"
+
+	"github.com/pkg/errors"
+
+	"github.com/ppxb/oreo-admin-go/pkg/database"
+	"github.com/ppxb/oreo-admin-go/pkg/global"
+	"github.com/ppxb/oreo-admin-go/pkg/job"
+	"github.com/ppxb/oreo-admin-go/pkg/log"
+	"github.com/ppxb/oreo-admin-go/pkg/metrics"
+)
+
+// Metrics collects mysql pools and job queues, metrics are served with pprof on system.pprof-port if metrics.pprof is set
+func Metrics(ctx context.Context) error {
+	if !global.Conf.Metrics.Enable {
+		log.WithContext(ctx).WithComponent("init").Info("Metrics is not enabled")
+		return nil
+	}
+
+	// a degraded start registers the pools after mysql connects, see attachMysql
+	if global.Mysql() != nil {
+		if err := registerPoolMetrics(); err != nil {
+			return err
+		}
+	}
+	if global.Worker != nil {
+		if err := metrics.RegisterQueues(queueSizes); err != nil {
+			return errors.Wrap(err, "initialize metrics failed")
+		}
+	}
+
+	if global.Conf.Metrics.Pprof {
+		if global.Conf.System.PprofPort <= 0 {
+			return errors.New("initialize metrics failed: pprof port is not configured")
+		}
+		mux := http.NewServeMux()
+		mux.HandleFunc("/debug/pprof/", pprof.Index)
+		mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+		mux.Handle(global.Conf.Metrics.Path, metrics.Handler())
+		srv := &http.Server{
+			Addr:    fmt.Sprintf(":%d", global.Conf.System.PprofPort),
+			Handler: mux,
+		}
+		go func() {
+			if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.WithContext(ctx).WithError(err).WithComponent("init").Error("Pprof server stopped")
+			}
+		}()
+	}
+
+	log.WithContext(ctx).WithComponent("init").Info("Initialize metrics successfully", "path", global.Conf.Metrics.Path, "pprof", global.Conf.Metrics.Pprof)
+	return nil
+}
+
+func registerPoolMetrics() error {
+	if err := metrics.RegisterPool(database.Stats); err != nil {
+		return errors.Wrap(err, "initialize metrics failed")
+	}
+	return nil
+}
+
+// queueSizes converts queues of the worker to counts by state
+func queueSizes() (map[string]map[string]int, error) {
+	queues, err := global.Worker.Queues()
+	if err != nil {
+		return nil, err
+	}
+	sizes := make(map[string]map[string]int, len(queues))
+	for _, q := range queues {
+		sizes[q.Name] = map[string]int{
+			job.StatePending:   q.Pending,
+			job.StateActive:    q.Active,
+			job.StateScheduled: q.Scheduled,
+			job.StateRetry:     q.Retry,
+			job.StateArchived:  q.Archived,
+			job.StateCompleted: q.Completed,
+		}
+	}
+	return sizes, nil
+}
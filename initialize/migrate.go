@@ -0,0 +1,216 @@
+package initialize
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/dromara/carbon/v2"
+	"github.com/pkg/errors"
+
+	"github.com/ppxb/oreo-admin-go/pkg/constant"
+	"github.com/ppxb/oreo-admin-go/pkg/global"
+	"github.com/ppxb/oreo-admin-go/pkg/migrate"
+)
+
+const migrateUsage = `usage: %s migrate <command> [flags]
+
+commands:
+  status        print applied and pending migrations
+  up [N]        apply N pending migrations(default all)
+  down N        roll back N applied migrations(default 1)
+  redo          roll back the last migration and apply it again
+  new <name>    create an empty migration file in initialize/db
+  diff [name]   compare registered models with the database, write a migration skeleton when name is given
+
+flags:
+`
+
+// Migrate runs the migration command line, args are the arguments after `migrate`
+func Migrate(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", false, "print the sql without executing it, the database is opened read only")
+	yes := fs.Bool("yes", false, "skip the confirmation of down/redo in production")
+	failFast := fs.Bool("fail-fast", global.Conf.Mysql.Migration.LockFailFast, "exit if another instance holds the migration lock")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), migrateUsage, filepath.Base(os.Args[0]))
+		fs.PrintDefaults()
+	}
+
+	flags, positional := splitArgs(args)
+	if err := fs.Parse(flags); err != nil {
+		return err
+	}
+	if len(positional) == 0 {
+		fs.Usage()
+		return errors.New("migrate command is empty")
+	}
+
+	if err := parseDSNConfig(); err != nil {
+		return errors.Wrap(err, "parse mysql uri failed")
+	}
+
+	options := append(
+		migrateOptions(ctx),
+		migrate.WithDryRun(*dryRun),
+		migrate.WithLockFailFast(*failFast),
+	)
+
+	cmd, rest := positional[0], positional[1:]
+	switch cmd {
+	case "status":
+		records, err := migrate.Status(options...)
+		if err != nil {
+			return err
+		}
+		printStatus(os.Stdout, records)
+		return nil
+	case "up":
+		n, err := parseCount(rest, 0)
+		if err != nil {
+			return err
+		}
+		plans, err := migrate.Up(append(options, migrate.WithMax(n))...)
+		printPlans(os.Stdout, plans, *dryRun)
+		return err
+	case "down":
+		n, err := parseCount(rest, 1)
+		if err != nil {
+			return err
+		}
+		if !*dryRun && !confirmProduction(os.Stdin, os.Stdout, *yes, fmt.Sprintf("roll back %d migration(s)", n)) {
+			return errors.New("migrate down is cancelled")
+		}
+		plans, err := migrate.Down(append(options, migrate.WithMax(n))...)
+		printPlans(os.Stdout, plans, *dryRun)
+		return err
+	case "redo":
+		if !*dryRun && !confirmProduction(os.Stdin, os.Stdout, *yes, "redo the last migration") {
+			return errors.New("migrate redo is cancelled")
+		}
+		plans, err := migrate.Redo(options...)
+		printPlans(os.Stdout, plans, *dryRun)
+		return err
+	case "new":
+		if len(rest) == 0 {
+			return errors.New("migration name is required")
+		}
+		filename, err := migrate.New(migrationDir(), strings.Join(rest, "-"))
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stdout, "created %s\n", filename)
+		return nil
+	case "diff":
+		return diff(ctx, rest)
+	default:
+		fs.Usage()
+		return errors.Errorf("unknown migrate command: %s", cmd)
+	}
+}
+
+func diff(ctx context.Context, args []string) error {
+	db, err := createDBConnection()
+	if err != nil {
+		return errors.Wrap(err, "connect mysql failed")
+	}
+
+	report, err := migrate.Diff(db.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(os.Stdout, report.String())
+	if report.Empty() {
+		return nil
+	}
+	if len(args) == 0 {
+		return migrate.ErrSchemaDrift
+	}
+
+	filename, err := migrate.NewFromDiff(migrationDir(), strings.Join(args, "-"), report)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stdout, "created %s\n", filename)
+	return nil
+}
+
+func migrationDir() string {
+	return filepath.Join(global.RuntimeRoot, "initialize", "db")
+}
+
+// splitArgs moves flags in front of positional arguments so that `down 2 --dry-run` works as well
+func splitArgs(args []string) (flags, positional []string) {
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "-") {
+			flags = append(flags, arg)
+		} else {
+			positional = append(positional, arg)
+		}
+	}
+	return
+}
+
+func parseCount(args []string, def int) (int, error) {
+	if len(args) == 0 {
+		return def, nil
+	}
+	n, err := strconv.Atoi(args[0])
+	if err != nil || n < 1 {
+		return 0, errors.Errorf("invalid migration count: %s", args[0])
+	}
+	return n, nil
+}
+
+func confirmProduction(in io.Reader, out io.Writer, yes bool, action string) bool {
+	if global.Mode != constant.Prod || yes {
+		return true
+	}
+	fmt.Fprintf(out, "You are about to %s in %s, type 'yes' to continue: ", action, global.Mode)
+	answer, _ := bufio.NewReader(in).ReadString('\n')
+	return strings.TrimSpace(answer) == "yes"
+}
+
+func printStatus(out io.Writer, records []migrate.Record) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tSTATUS\tAPPLIED AT")
+	pending := 0
+	for _, record := range records {
+		if record.Applied {
+			fmt.Fprintf(w, "%s\tapplied\t%s\n", record.Id, carbon.CreateFromStdTime(record.AppliedAt).ToDateTimeString())
+		} else {
+			pending++
+			fmt.Fprintf(w, "%s\tpending\t-\n", record.Id)
+		}
+	}
+	w.Flush()
+	fmt.Fprintf(out, "\n%d applied, %d pending\n", len(records)-pending, pending)
+}
+
+func printPlans(out io.Writer, plans []migrate.Plan, dryRun bool) {
+	if len(plans) == 0 {
+		fmt.Fprintln(out, "no migration to execute")
+		return
+	}
+	for _, plan := range plans {
+		if !dryRun {
+			fmt.Fprintf(out, "%s %s\n", plan.Direction, plan.Id)
+			continue
+		}
+		fmt.Fprintf(out, "-- %s %s\n", plan.Direction, plan.Id)
+		if plan.Code {
+			fmt.Fprintln(out, "-- go migration, executed in a transaction")
+		}
+		for _, query := range plan.Queries {
+			fmt.Fprintln(out, strings.TrimSpace(query))
+		}
+		fmt.Fprintln(out)
+	}
+}
@@ -2,39 +2,98 @@ package initialize
 
 import (
 	"context"
+	"database/sql"
 	"embed"
-	"fmt"
 	"time"
 
 	m "github.com/go-sql-driver/mysql"
 	"github.com/pkg/errors"
-	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
 	"gorm.io/gorm/schema"
 
 	glogger "gorm.io/gorm/logger"
 
+	"github.com/ppxb/oreo-admin-go/pkg/database"
+	"github.com/ppxb/oreo-admin-go/pkg/dialect"
 	"github.com/ppxb/oreo-admin-go/pkg/global"
 	"github.com/ppxb/oreo-admin-go/pkg/log"
+	"github.com/ppxb/oreo-admin-go/pkg/metrics"
 	"github.com/ppxb/oreo-admin-go/pkg/migrate"
 )
 
 //go:embed db/*.sql
 var sqlFs embed.FS
 
-func Mysql(ctx context.Context) {
+// pendingMysql is the pool of a degraded start until mysql connects, database/sql dials lazily
+// so that the mysql lock fallback can be set up on start like pendingRedis
+var pendingMysql *sql.DB
+
+func Mysql(ctx context.Context) error {
 	if err := parseDSNConfig(); err != nil {
-		panic(errors.Wrap(err, "initialize mysql failed"))
+		return errors.Wrap(err, "initialize mysql failed")
+	}
+
+	if err := connect(ctx, "mysql", pingDatabase); err != nil {
+		if !global.Conf.System.DegradedStart {
+			return err
+		}
+		log.WithContext(ctx).WithError(err).WithComponent("init").Error("Mysql is unavailable, start in degraded mode")
+		if global.Conf.Mysql.Driver == dialect.Mysql {
+			if pendingMysql, err = sql.Open(dialect.Mysql, global.Conf.Mysql.DSN.FormatDSN()); err != nil {
+				return errors.Wrap(err, "initialize mysql failed")
+			}
+		}
+		reconnect(ctx, "mysql", pingDatabase, func(ctx context.Context) error {
+			if err := startMysql(ctx); err != nil {
+				return err
+			}
+			return attachMysql(ctx)
+		})
+		return nil
 	}
 
+	return startMysql(ctx)
+}
+
+func startMysql(ctx context.Context) error {
 	if err := executeMigration(ctx); err != nil {
-		panic(errors.Wrap(err, "mysql migration failed"))
+		return errors.Wrap(err, "mysql migration failed")
 	}
 
-	log.WithContext(ctx).Info("[INIT] Mysql initialized successfully")
+	log.WithContext(ctx).WithComponent("init").Info("Mysql initialized successfully", "pool", database.StatsString())
+	return nil
+}
+
+// attachMysql runs what bootstrap skipped without mysql once it is done, the lock fallback
+// already uses pendingMysql, see Lock
+func attachMysql(ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-bootstrapped:
+	}
+	if global.Conf.Metrics.Enable {
+		return registerPoolMetrics()
+	}
+	return nil
+}
+
+// pingDatabase connects the server and creates the database if not exists
+func pingDatabase(ctx context.Context) error {
+	d, err := dialect.Get(global.Conf.Mysql.Driver)
+	if err != nil {
+		return err
+	}
+	return d.EnsureDatabase(ctx, global.Conf.Mysql.Uri)
 }
 
 func parseDSNConfig() error {
+	if _, err := dialect.Get(global.Conf.Mysql.Driver); err != nil {
+		return err
+	}
+	if global.Conf.Mysql.Driver != dialect.Mysql {
+		return nil
+	}
 	cfg, err := m.ParseDSN(global.Conf.Mysql.Uri)
 	if err != nil {
 		return err
@@ -44,31 +103,69 @@ func parseDSNConfig() error {
 }
 
 func executeMigration(ctx context.Context) error {
-	return migrate.Do(
+	return migrate.Do(append(
+		migrateOptions(ctx),
+		migrate.WithBefore(initializeDatabase),
+	)...)
+}
+
+func migrateOptions(ctx context.Context) []func(*migrate.Options) {
+	return []func(*migrate.Options){
 		migrate.WithCtx(ctx),
+		migrate.WithDriver(global.Conf.Mysql.Driver),
 		migrate.WithUri(global.Conf.Mysql.Uri),
 		migrate.WithFs(sqlFs),
 		migrate.WithFsRoot("db"),
-		migrate.WithBefore(initializeDatabase),
-	)
+		migrate.WithLockTimeout(time.Duration(global.Conf.Mysql.Migration.LockTimeout) * time.Second),
+		migrate.WithLockFailFast(global.Conf.Mysql.Migration.LockFailFast),
+		migrate.WithGormConfig(gormConfig()),
+	}
 }
 
 func initializeDatabase(ctx context.Context) error {
-	ctx, cancel := context.WithTimeout(ctx, time.Duration(global.Conf.System.ConnectTimeout)*time.Second)
-	defer cancel()
-
-	go monitorTimeout(ctx)
-
 	db, err := createDBConnection()
 	if err != nil {
 		return err
 	}
 
-	global.Mysql = db
+	if err = database.Register(
+		db,
+		database.WithCtx(ctx),
+		database.WithDriver(global.Conf.Mysql.Driver),
+		database.WithReplicas(global.Conf.Mysql.Replicas...),
+		database.WithPool(database.PoolOptions{
+			MaxOpenConns:    global.Conf.Mysql.Pool.MaxOpenConns,
+			MaxIdleConns:    global.Conf.Mysql.Pool.MaxIdleConns,
+			ConnMaxLifetime: time.Duration(global.Conf.Mysql.Pool.ConnMaxLifetime) * time.Second,
+			ConnMaxIdleTime: time.Duration(global.Conf.Mysql.Pool.ConnMaxIdleTime) * time.Second,
+		}),
+		database.WithReadYourWrites(time.Duration(global.Conf.Mysql.ReadYourWrites)*time.Second),
+	); err != nil {
+		return err
+	}
+	if global.Conf.Metrics.Enable {
+		if err = db.Use(metrics.GormPlugin{}); err != nil {
+			return err
+		}
+	}
+
+	global.SetMysql(db)
 	return nil
 }
 
 func createDBConnection() (*gorm.DB, error) {
+	d, err := dialect.Get(global.Conf.Mysql.Driver)
+	if err != nil {
+		return nil, err
+	}
+	uri := global.Conf.Mysql.Uri
+	if d.Name() == dialect.Mysql {
+		uri = global.Conf.Mysql.DSN.FormatDSN()
+	}
+	return gorm.Open(d.Open(uri), gormConfig())
+}
+
+func gormConfig() *gorm.Config {
 	l := log.NewDefaultGormLogger()
 	if global.Conf.Mysql.NoSql {
 		l = l.LogMode(glogger.Silent)
@@ -76,7 +173,7 @@ func createDBConnection() (*gorm.DB, error) {
 		l = l.LogMode(glogger.Info)
 	}
 
-	return gorm.Open(mysql.Open(global.Conf.Mysql.DSN.FormatDSN()), &gorm.Config{
+	return &gorm.Config{
 		DisableForeignKeyConstraintWhenMigrating: true,
 		NamingStrategy: schema.NamingStrategy{
 			TablePrefix:   global.Conf.Mysql.TablePrefix + "_",
@@ -84,12 +181,5 @@ func createDBConnection() (*gorm.DB, error) {
 		},
 		QueryFields: true,
 		Logger:      l,
-	})
-}
-
-func monitorTimeout(ctx context.Context) {
-	<-ctx.Done()
-	if global.Mysql == nil {
-		panic(fmt.Sprintf("mysql connection timeout after %d seconds", global.Conf.System.ConnectTimeout))
 	}
 }
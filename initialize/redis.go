@@ -2,49 +2,88 @@ package initialize
 
 import (
 	"context"
-	"fmt"
-	"time"
 
 	"github.com/pkg/errors"
+	"github.com/redis/go-redis/v9"
 
 	"github.com/ppxb/oreo-admin-go/pkg/global"
 	"github.com/ppxb/oreo-admin-go/pkg/log"
+	"github.com/ppxb/oreo-admin-go/pkg/metrics"
 	"github.com/ppxb/oreo-admin-go/pkg/query"
 )
 
-// TODO: NEED REFACTOR(NO CONNECTION FAILED DESCRIPTION LIKE PASSWORD ERROR OR ETC.)
+// pendingRedis is the client of a degraded start until it connects, go-redis dials lazily
+// so that components which must not fall back to memory(e.g. locks) can use it already
+var pendingRedis redis.UniversalClient
 
-func Redis(ctx context.Context) {
+func Redis(ctx context.Context) error {
 	if !global.Conf.Redis.Enable {
-		log.WithContext(ctx).Info("[INIT] Redis is not enabled")
-		return
-	}
-	init := false
-	ctx, cancel := context.WithTimeout(ctx, time.Duration(global.Conf.System.ConnectTimeout)*time.Second)
-	defer cancel()
-
-	go func() {
-		for {
-			select {
-			case <-ctx.Done():
-				if !init {
-					panic(fmt.Sprintf("initialize redis failed: connect timeout(%ds)", global.Conf.System.ConnectTimeout))
-				}
-				return
-			}
-		}
-	}()
+		log.WithContext(ctx).WithComponent("init").Info("Redis is not enabled")
+		return nil
+	}
 
 	client, err := query.ParseRedisURI(global.Conf.Redis.Uri)
 	if err != nil {
-		panic(errors.Wrap(err, "initialize redis failed"))
+		return errors.Wrap(err, "initialize redis failed")
 	}
-	err = client.Ping(ctx).Err()
-	if err != nil {
-		panic(errors.Wrap(err, "initialize redis failed"))
+
+	if global.Conf.Metrics.Enable {
+		client.AddHook(metrics.RedisHook{})
+	}
+
+	ping := func(ctx context.Context) error {
+		return client.Ping(ctx).Err()
+	}
+	ready := func(ctx context.Context) error {
+		global.Redis = client
+		log.WithContext(ctx).WithComponent("init").Info("Initialize redis successfully")
+		return nil
+	}
+
+	if err = connect(ctx, "redis", ping); err != nil {
+		if !global.Conf.System.DegradedStart {
+			closeRedis(ctx, client)
+			return err
+		}
+		log.WithContext(ctx).WithError(err).WithComponent("init").Error("Redis is unavailable, start in degraded mode")
+		pendingRedis = client
+		reconnect(ctx, "redis", ping, func(ctx context.Context) error {
+			if err := ready(ctx); err != nil {
+				return err
+			}
+			return attachRedis(ctx)
+		})
+		return nil
 	}
-	global.Redis = client
 
-	init = true
-	log.WithContext(ctx).Info("[INIT] Initialize redis successfully")
+	return ready(ctx)
+}
+
+// attachRedis moves the components started without redis onto it once bootstrap is done,
+// they keep their identity so that references taken on start(router, geo) follow
+func attachRedis(ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-bootstrapped:
+	}
+	if global.Cache != nil {
+		global.Cache.UseRedis(global.Redis)
+	}
+	if global.Attempts != nil {
+		global.Attempts.UseRedis(global.Redis)
+	}
+	if global.Keyring != nil {
+		global.Keyring.UseRedis(global.Redis)
+	}
+	if global.WeChat != nil {
+		global.WeChat.UseRedis(global.Redis)
+	}
+	return Binlog(ctx)
+}
+
+func closeRedis(ctx context.Context, client redis.UniversalClient) {
+	if err := client.Close(); err != nil {
+		log.WithContext(ctx).WithError(err).WithComponent("init").Warn("Close redis client failed")
+	}
 }
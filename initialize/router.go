@@ -0,0 +1,42 @@
+package initialize
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+
+	"github.com/ppxb/oreo-admin-go/internal/router"
+	"github.com/ppxb/oreo-admin-go/pkg/constant"
+	"github.com/ppxb/oreo-admin-go/pkg/database"
+	"github.com/ppxb/oreo-admin-go/pkg/global"
+	"github.com/ppxb/oreo-admin-go/pkg/log"
+	"github.com/ppxb/oreo-admin-go/pkg/metrics"
+	"github.com/ppxb/oreo-admin-go/pkg/middleware"
+)
+
+func Router(ctx context.Context) (*gin.Engine, error) {
+	if global.Mode != constant.Dev {
+		gin.SetMode(gin.ReleaseMode)
+	}
+	r := gin.New()
+	if err := r.SetTrustedProxies(global.Conf.System.TrustedProxies); err != nil {
+		return nil, errors.Wrap(err, "invalid trusted proxies")
+	}
+	r.Use(gin.Recovery(), middleware.RequestId(), database.Session(), middleware.ClientIp(global.Geo))
+	if global.Conf.Metrics.Enable {
+		r.Use(middleware.Metrics())
+		if !global.Conf.Metrics.Pprof {
+			r.GET(global.Conf.Metrics.Path, gin.WrapH(metrics.Handler()))
+		}
+	}
+
+	base := r.Group(global.Conf.System.Base)
+	router.InitUserRouter(base)
+	admin := base.Group("admin", middleware.AdminToken(global.Conf.System.AdminToken))
+	router.InitJobRouter(admin)
+	router.InitLogRouter(admin)
+
+	log.WithContext(ctx).WithComponent("init").Info("Initialize router successfully", "base", global.Conf.System.Base)
+	return r, nil
+}
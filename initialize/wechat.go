@@ -0,0 +1,52 @@
+package initialize
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/ppxb/oreo-admin-go/internal/task"
+	"github.com/ppxb/oreo-admin-go/pkg/global"
+	"github.com/ppxb/oreo-admin-go/pkg/job"
+	"github.com/ppxb/oreo-admin-go/pkg/log"
+	"github.com/ppxb/oreo-admin-go/pkg/wechat"
+)
+
+func WeChat(ctx context.Context) error {
+	cfg := global.Conf.WeChat.Official
+	if cfg.AppId == "" {
+		log.WithContext(ctx).WithComponent("init").Info("WeChat official account is not enabled")
+		return nil
+	}
+
+	official, err := wechat.NewOfficial(
+		wechat.WithCtx(ctx),
+		wechat.WithAppId(cfg.AppId),
+		wechat.WithAppSecret(cfg.AppSecret),
+		wechat.WithBaseUrl(cfg.BaseUrl),
+		wechat.WithRedis(global.Redis),
+		wechat.WithLocker(global.Locker),
+		wechat.WithPrefix(global.AppName+"_wechat"),
+	)
+	if err != nil {
+		return errors.Wrap(err, "initialize wechat official account failed")
+	}
+	global.WeChat = official
+
+	tpl := cfg.TplMessageCronTask
+	if tpl.Expr != "" {
+		if global.Worker == nil {
+			log.WithContext(ctx).WithComponent("init").Warn("Job worker is not enabled, wechat template message cron task is skipped")
+		} else if err = job.Schedule(global.Worker, tpl.Expr, task.TypeWeChatTplMessage, task.WeChatTplMessage{
+			Users:               tpl.Users,
+			TemplateId:          tpl.TemplateId,
+			MiniProgramAppId:    tpl.MiniProgramAppId,
+			MiniProgramPagePath: tpl.MiniProgramPagePath,
+			Data:                tpl.Data,
+		}); err != nil {
+			return errors.Wrap(err, "initialize wechat template message cron task failed")
+		}
+	}
+	log.WithContext(ctx).WithComponent("init").Info("Initialize wechat official account successfully")
+	return nil
+}
@@ -0,0 +1,39 @@
+package initialize
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/ppxb/oreo-admin-go/pkg/global"
+	"github.com/ppxb/oreo-admin-go/pkg/job"
+	"github.com/ppxb/oreo-admin-go/pkg/log"
+)
+
+func Worker(ctx context.Context) error {
+	if !global.Conf.Job.Enable {
+		log.WithContext(ctx).WithComponent("init").Info("Job worker is not enabled")
+		return nil
+	}
+
+	w, err := job.New(
+		job.WithCtx(ctx),
+		job.WithUri(global.Conf.Redis.Uri),
+		job.WithLocker(global.Locker),
+		job.WithConcurrency(global.Conf.Job.Concurrency),
+		job.WithQueues(global.Conf.Job.Queues),
+		job.WithMaxRetry(global.Conf.Job.MaxRetry),
+		job.WithShutdownTimeout(time.Duration(global.Conf.Job.ShutdownTimeout)*time.Second),
+	)
+	if err != nil {
+		return errors.Wrap(err, "initialize job worker failed")
+	}
+	if err = w.Start(); err != nil {
+		return errors.Wrap(err, "initialize job worker failed")
+	}
+
+	global.Worker = w
+	log.WithContext(ctx).WithComponent("init").Info("Initialize job worker successfully")
+	return nil
+}
@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/ppxb/oreo-admin-go/pkg/global"
+	"github.com/ppxb/oreo-admin-go/pkg/log"
+	"github.com/ppxb/oreo-admin-go/pkg/req"
+	"github.com/ppxb/oreo-admin-go/pkg/resp"
+)
+
+// GetJobQueues lists queues with their task counts
+func GetJobQueues(c *gin.Context) {
+	if !workerEnabled(c) {
+		return
+	}
+	list, err := global.Worker.Queues()
+	if err != nil {
+		log.WithContext(c).WithError(err).WithComponent("job").Error("List queues failed")
+		resp.FailWithMsg(c, err.Error())
+		return
+	}
+	resp.SuccessWithData(c, list)
+}
+
+type findJobTasksReq struct {
+	Queue    string `form:"queue,default=default"`
+	State    string `form:"state,default=archived" binding:"oneof=pending active scheduled retry archived completed"`
+	PageNum  int    `form:"pageNum,default=1" binding:"min=1"`
+	PageSize int    `form:"pageSize,default=20" binding:"min=1,max=100"`
+}
+
+// FindJobTasks pages tasks by queue and state(pending/active/scheduled/retry/archived/completed)
+func FindJobTasks(c *gin.Context) {
+	if !workerEnabled(c) {
+		return
+	}
+	var r findJobTasksReq
+	if !req.Query(c, &r) {
+		return
+	}
+	list, err := global.Worker.ListTasks(r.Queue, r.State, r.PageNum, r.PageSize)
+	if err != nil {
+		resp.FailWithMsg(c, err.Error())
+		return
+	}
+	resp.SuccessWithData(c, list)
+}
+
+// RetryJobTask runs a scheduled, retry or archived task now
+func RetryJobTask(c *gin.Context) {
+	if !workerEnabled(c) {
+		return
+	}
+	if err := global.Worker.RetryTask(c.Param("queue"), c.Param("id")); err != nil {
+		resp.FailWithMsg(c, err.Error())
+		return
+	}
+	log.WithContext(c).WithComponent("job").Info("Task is retried by admin", "queue", c.Param("queue"), "taskId", c.Param("id"))
+	resp.Success(c)
+}
+
+// CancelJobTask cancels an active task or archives a waiting one
+func CancelJobTask(c *gin.Context) {
+	if !workerEnabled(c) {
+		return
+	}
+	if err := global.Worker.CancelTask(c.Param("queue"), c.Param("id")); err != nil {
+		resp.FailWithMsg(c, err.Error())
+		return
+	}
+	log.WithContext(c).WithComponent("job").Info("Task is canceled by admin", "queue", c.Param("queue"), "taskId", c.Param("id"))
+	resp.Success(c)
+}
+
+func workerEnabled(c *gin.Context) bool {
+	if global.Worker == nil {
+		resp.FailWithMsg(c, "job worker is not enabled")
+		return false
+	}
+	return true
+}
@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ppxb/oreo-admin-go/pkg/log"
+	"github.com/ppxb/oreo-admin-go/pkg/req"
+	"github.com/ppxb/oreo-admin-go/pkg/resp"
+)
+
+// maxRequestLevelMinutes limits how long a request id is logged verbosely
+const maxRequestLevelMinutes = 24 * 60
+
+type logLevelReq struct {
+	Level *log.Level `json:"level" binding:"required"`
+}
+
+type logRequestLevelReq struct {
+	RequestId string `json:"requestId" binding:"required"`
+	// Level defaults to debug
+	Level *log.Level `json:"level"`
+	// Minutes defaults to 10
+	Minutes int `json:"minutes"`
+}
+
+// GetLogLevel returns the base level with component and request overrides
+func GetLogLevel(c *gin.Context) {
+	levels := log.DefaultLevels()
+	resp.SuccessWithData(c, map[string]interface{}{
+		"level":      levels.Level(),
+		"components": levels.Components(),
+		"requests":   levels.Requests(),
+	})
+}
+
+// UpdateLogLevel changes the base level until restart
+func UpdateLogLevel(c *gin.Context) {
+	var r logLevelReq
+	if !bindLogLevel(c, &r) {
+		return
+	}
+	log.DefaultLevels().SetLevel(*r.Level)
+	log.WithContext(c).WithComponent("log").Warn("Log level is changed by admin", "level", *r.Level)
+	resp.Success(c)
+}
+
+// UpdateLogComponentLevel overrides the level of a component, e.g. gorm
+func UpdateLogComponentLevel(c *gin.Context) {
+	var r logLevelReq
+	if !bindLogLevel(c, &r) {
+		return
+	}
+	log.DefaultLevels().SetComponentLevel(c.Param("name"), *r.Level)
+	log.WithContext(c).WithComponent("log").Warn("Log level of component is changed by admin", "target", c.Param("name"), "level", *r.Level)
+	resp.Success(c)
+}
+
+func DeleteLogComponentLevel(c *gin.Context) {
+	log.DefaultLevels().ResetComponentLevel(c.Param("name"))
+	log.WithContext(c).WithComponent("log").Warn("Log level of component is reset by admin", "target", c.Param("name"))
+	resp.Success(c)
+}
+
+// CreateLogRequestLevel logs every record of a request id at the level for some minutes
+func CreateLogRequestLevel(c *gin.Context) {
+	var r logRequestLevelReq
+	if !req.Json(c, &r) {
+		return
+	}
+	level := log.DebugLevel
+	if r.Level != nil {
+		level = *r.Level
+	}
+	if !level.Valid() {
+		resp.FailWithMsg(c, "invalid log level")
+		return
+	}
+	if r.Minutes <= 0 {
+		r.Minutes = 10
+	}
+	if r.Minutes > maxRequestLevelMinutes {
+		r.Minutes = maxRequestLevelMinutes
+	}
+	log.DefaultLevels().SetRequestLevel(r.RequestId, level, time.Duration(r.Minutes)*time.Minute)
+	log.WithContext(c).WithComponent("log").Warn("Log level of request is changed by admin", "target", r.RequestId, "level", level, "minutes", r.Minutes)
+	resp.Success(c)
+}
+
+func DeleteLogRequestLevel(c *gin.Context) {
+	log.DefaultLevels().ResetRequestLevel(c.Param("id"))
+	resp.Success(c)
+}
+
+func bindLogLevel(c *gin.Context, r *logLevelReq) bool {
+	if !req.Json(c, r) {
+		return false
+	}
+	if !r.Level.Valid() {
+		resp.FailWithMsg(c, "invalid log level")
+		return false
+	}
+	return true
+}
@@ -0,0 +1,248 @@
+package handler
+
+import (
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+
+	"github.com/ppxb/oreo-admin-go/internal/model"
+	"github.com/ppxb/oreo-admin-go/pkg/attempt"
+	"github.com/ppxb/oreo-admin-go/pkg/crypt"
+	"github.com/ppxb/oreo-admin-go/pkg/global"
+	"github.com/ppxb/oreo-admin-go/pkg/log"
+	"github.com/ppxb/oreo-admin-go/pkg/password"
+	"github.com/ppxb/oreo-admin-go/pkg/req"
+	"github.com/ppxb/oreo-admin-go/pkg/resp"
+)
+
+const (
+	invalidLoginMsg    = "invalid username or password"
+	tooManyAttemptsMsg = "too many failed attempts, please retry later"
+)
+
+// dummyHash is verified for unknown users so that they take as long as wrong passwords,
+// it is made on first use since DefaultHasher is configured on start
+var dummyHash = sync.OnceValue(func() string {
+	hash, _ := password.DefaultHasher.Hash("dummy password")
+	return hash
+})
+
+// loginReq and changePasswordReq carry plaintext, encrypted fields are opened by middleware.Decrypt
+type loginReq struct {
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+type changePasswordReq struct {
+	Username    string `json:"username" binding:"required"`
+	OldPassword string `json:"oldPassword" binding:"required"`
+	NewPassword string `json:"newPassword" binding:"required,password"`
+}
+
+// Login verifies the password, re-hashes it if the hash parameters changed,
+// and asks for a change if the password is expired or no longer meets the policy
+func Login(c *gin.Context) {
+	if !mysqlEnabled(c) {
+		return
+	}
+	var r loginReq
+	if !req.Json(c, &r) {
+		return
+	}
+	user, ok := verifyUser(c, r.Username, r.Password)
+	if !ok {
+		return
+	}
+	if password.DefaultHasher.NeedsRehash(user.Password) {
+		rehash(c, user, r.Password)
+	}
+	expired := password.DefaultPolicy.Expired(user.PasswordChangedAt)
+	resp.SuccessWithData(c, map[string]interface{}{
+		"id":                 user.Id,
+		"username":           user.Username,
+		"passwordExpired":    expired,
+		"mustChangePassword": expired || password.DefaultPolicy.Check(r.Password) != nil,
+	})
+}
+
+// ChangePassword replaces the password after checking the policy and the history of the user
+func ChangePassword(c *gin.Context) {
+	if !mysqlEnabled(c) {
+		return
+	}
+	var r changePasswordReq
+	if !req.Json(c, &r) {
+		return
+	}
+	user, ok := verifyUser(c, r.Username, r.OldPassword)
+	if !ok {
+		return
+	}
+
+	// the current password is the newest one of the history
+	hashes := []string{user.Password}
+	if n := password.DefaultPolicy.History - 1; n > 0 {
+		var list []string
+		if err := global.Mysql().WithContext(c).Model(&model.SysUserPasswordHistory{}).
+			Where("user_id = ?", user.Id).Order("id DESC").Limit(n).Pluck("password", &list).Error; err != nil {
+			log.WithContext(c).WithError(err).WithComponent("user").Error("Find password history failed")
+			resp.FailWithMsg(c, resp.InternalServerErrorMsg)
+			return
+		}
+		hashes = append(hashes, list...)
+	}
+	if err := password.DefaultPolicy.CheckHistory(password.DefaultHasher, r.NewPassword, hashes); err != nil {
+		resp.FailWithMsg(c, req.PasswordMsg(err, req.Locale(c.GetHeader("Accept-Language"))))
+		return
+	}
+
+	hash, err := password.DefaultHasher.Hash(r.NewPassword)
+	if err != nil {
+		log.WithContext(c).WithError(err).WithComponent("user").Error("Hash password failed")
+		resp.FailWithMsg(c, resp.InternalServerErrorMsg)
+		return
+	}
+	err = global.Mysql().WithContext(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&model.SysUserPasswordHistory{UserId: user.Id, Password: user.Password}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(user).Updates(map[string]interface{}{
+			"password":            hash,
+			"password_changed_at": time.Now(),
+		}).Error; err != nil {
+			return err
+		}
+		return trimPasswordHistory(tx, user.Id)
+	})
+	if err != nil {
+		log.WithContext(c).WithError(err).WithComponent("user").Error("Change password failed")
+		resp.FailWithMsg(c, resp.InternalServerErrorMsg)
+		return
+	}
+	log.WithContext(c).WithComponent("user").Info("Password is changed", "userId", user.Id)
+	resp.Success(c)
+}
+
+// GetRsaKey serves the key clients encrypt credentials with, a payload encrypted by a retired key
+// fails with an unknown key id so that clients fetch this again
+func GetRsaKey(c *gin.Context) {
+	if global.Keyring == nil {
+		resp.FailWithMsg(c, "rsa encrypted credentials are not enabled")
+		return
+	}
+	key := global.Keyring.Current()
+	resp.SuccessWithData(c, map[string]interface{}{
+		"keyId":       key.Id,
+		"algorithm":   crypt.Algorithm,
+		"publicKey":   key.PublicPem(),
+		"fingerprint": key.Fingerprint,
+		"maxSkew":     int64(global.Keyring.MaxSkew().Seconds()),
+	})
+}
+
+// verifyUser writes the same failure for an unknown user and a wrong password,
+// failures are limited per username and per ip so that passwords can not be guessed
+func verifyUser(c *gin.Context, username, pwd string) (*model.SysUser, bool) {
+	keys := []attempt.Key{
+		{Kind: attempt.KindUser, Id: strings.ToLower(username)},
+		{Kind: attempt.KindIp, Id: c.ClientIP()},
+	}
+	if attemptsBlocked(c, keys) {
+		return nil, false
+	}
+	var user model.SysUser
+	err := global.Mysql().WithContext(c).Where("username = ?", username).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		_, _ = password.DefaultHasher.Verify(dummyHash(), pwd)
+		failAttempt(c, keys)
+		resp.FailWithMsg(c, invalidLoginMsg)
+		return nil, false
+	}
+	if err != nil {
+		log.WithContext(c).WithError(err).WithComponent("user").Error("Find user failed")
+		resp.FailWithMsg(c, resp.InternalServerErrorMsg)
+		return nil, false
+	}
+	ok, err := password.DefaultHasher.Verify(user.Password, pwd)
+	if err != nil {
+		log.WithContext(c).WithError(err).WithComponent("user").Error("Verify password failed", "userId", user.Id)
+	}
+	if !ok {
+		failAttempt(c, keys)
+		resp.FailWithMsg(c, invalidLoginMsg)
+		return nil, false
+	}
+	// the ip keeps its count, otherwise one known password would reset the guessing of others
+	if global.Attempts != nil {
+		if err = global.Attempts.Reset(c, keys[0]); err != nil {
+			log.WithContext(c).WithError(err).WithComponent("user").Warn("Reset password attempts failed")
+		}
+	}
+	return &user, true
+}
+
+// attemptsBlocked writes the failure of a blocked key, a broken limiter does not block logins
+func attemptsBlocked(c *gin.Context, keys []attempt.Key) bool {
+	if global.Attempts == nil {
+		return false
+	}
+	retryAfter, err := global.Attempts.Blocked(c, keys...)
+	if err != nil {
+		log.WithContext(c).WithError(err).WithComponent("user").Warn("Check password attempts failed")
+		return false
+	}
+	if retryAfter <= 0 {
+		return false
+	}
+	log.WithContext(c).WithComponent("user").Warn("Too many failed password attempts", "username", keys[0].Id, "retryAfter", retryAfter)
+	resp.FailWithData(c, tooManyAttemptsMsg, map[string]interface{}{
+		"retryAfter": int64(math.Ceil(retryAfter.Seconds())),
+	})
+	return true
+}
+
+func failAttempt(c *gin.Context, keys []attempt.Key) {
+	if global.Attempts == nil {
+		return
+	}
+	if err := global.Attempts.Fail(c, keys...); err != nil {
+		log.WithContext(c).WithError(err).WithComponent("user").Warn("Count password attempt failed")
+	}
+}
+
+// rehash upgrades the hash to the current parameters, a failure only delays it to the next login
+func rehash(c *gin.Context, user *model.SysUser, pwd string) {
+	hash, err := password.DefaultHasher.Hash(pwd)
+	if err == nil {
+		err = global.Mysql().WithContext(c).Model(user).Update("password", hash).Error
+	}
+	if err != nil {
+		log.WithContext(c).WithError(err).WithComponent("user").Warn("Rehash password failed", "userId", user.Id)
+	}
+}
+
+// trimPasswordHistory keeps the hashes needed by the history rule
+func trimPasswordHistory(tx *gorm.DB, userId uint) error {
+	var ids []uint
+	if err := tx.Model(&model.SysUserPasswordHistory{}).
+		Where("user_id = ?", userId).Order("id DESC").Offset(password.DefaultPolicy.History).Pluck("id", &ids).Error; err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	return tx.Delete(&model.SysUserPasswordHistory{}, ids).Error
+}
+
+func mysqlEnabled(c *gin.Context) bool {
+	if global.Mysql() == nil {
+		resp.FailWithMsg(c, "mysql is unavailable")
+		return false
+	}
+	return true
+}
@@ -0,0 +1,41 @@
+package model
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/ppxb/oreo-admin-go/pkg/migrate"
+)
+
+// SysUser is an admin account, Password is the hash of pkg/password
+type SysUser struct {
+	Id                uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt         time.Time `json:"createdAt"`
+	UpdatedAt         time.Time `json:"updatedAt"`
+	Username          string    `gorm:"size:64;uniqueIndex" json:"username"`
+	Password          string    `gorm:"size:255" json:"-"`
+	PasswordChangedAt time.Time `json:"passwordChangedAt"`
+}
+
+// SysUserPasswordHistory keeps hashes of previous passwords for the history rule
+type SysUserPasswordHistory struct {
+	Id        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+	UserId    uint      `gorm:"index" json:"userId"`
+	Password  string    `gorm:"size:255" json:"-"`
+}
+
+func init() {
+	migrate.RegisterModel(new(SysUser), new(SysUserPasswordHistory))
+	migrate.Register(migrate.CodeMigration{
+		Id: "20261019110000-sys-user",
+		Up: func(ctx context.Context, tx *gorm.DB) error {
+			return tx.AutoMigrate(new(SysUser), new(SysUserPasswordHistory))
+		},
+		Down: func(ctx context.Context, tx *gorm.DB) error {
+			return tx.Migrator().DropTable(new(SysUserPasswordHistory), new(SysUser))
+		},
+	})
+}
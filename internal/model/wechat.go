@@ -0,0 +1,36 @@
+package model
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/ppxb/oreo-admin-go/pkg/migrate"
+)
+
+// WeChatTplMessageLog is the delivery result of a template message
+type WeChatTplMessageLog struct {
+	Id         uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt  time.Time `gorm:"index" json:"createdAt"`
+	TemplateId string    `gorm:"size:64" json:"templateId"`
+	ToUser     string    `gorm:"size:64;index" json:"toUser"`
+	MsgId      int64     `json:"msgId"`
+	Success    bool      `json:"success"`
+	ErrCode    int       `json:"errCode"`
+	ErrMsg     string    `gorm:"size:255" json:"errMsg"`
+	RequestId  string    `gorm:"size:64" json:"requestId"`
+}
+
+func init() {
+	migrate.RegisterModel(new(WeChatTplMessageLog))
+	migrate.Register(migrate.CodeMigration{
+		Id: "20261019100000-wechat-tpl-message-log",
+		Up: func(ctx context.Context, tx *gorm.DB) error {
+			return tx.AutoMigrate(new(WeChatTplMessageLog))
+		},
+		Down: func(ctx context.Context, tx *gorm.DB) error {
+			return tx.Migrator().DropTable(new(WeChatTplMessageLog))
+		},
+	})
+}
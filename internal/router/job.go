@@ -0,0 +1,16 @@
+package router
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/ppxb/oreo-admin-go/internal/handler"
+)
+
+func InitJobRouter(r *gin.RouterGroup) gin.IRoutes {
+	router := r.Group("job")
+	router.GET("/queue", handler.GetJobQueues)
+	router.GET("/task", handler.FindJobTasks)
+	router.PATCH("/task/:queue/:id/retry", handler.RetryJobTask)
+	router.PATCH("/task/:queue/:id/cancel", handler.CancelJobTask)
+	return router
+}
@@ -0,0 +1,18 @@
+package router
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/ppxb/oreo-admin-go/internal/handler"
+)
+
+func InitLogRouter(r *gin.RouterGroup) gin.IRoutes {
+	router := r.Group("log")
+	router.GET("/level", handler.GetLogLevel)
+	router.PATCH("/level", handler.UpdateLogLevel)
+	router.PUT("/level/component/:name", handler.UpdateLogComponentLevel)
+	router.DELETE("/level/component/:name", handler.DeleteLogComponentLevel)
+	router.POST("/level/request", handler.CreateLogRequestLevel)
+	router.DELETE("/level/request/:id", handler.DeleteLogRequestLevel)
+	return router
+}
@@ -0,0 +1,17 @@
+package router
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/ppxb/oreo-admin-go/internal/handler"
+	"github.com/ppxb/oreo-admin-go/pkg/global"
+	"github.com/ppxb/oreo-admin-go/pkg/middleware"
+)
+
+func InitUserRouter(r *gin.RouterGroup) gin.IRoutes {
+	router := r.Group("user")
+	router.GET("/rsa/key", handler.GetRsaKey)
+	router.POST("/login", middleware.Decrypt(global.Keyring, global.Conf.Jwt.RSARequired, "password"), handler.Login)
+	router.PATCH("/password", middleware.Decrypt(global.Keyring, global.Conf.Jwt.RSARequired, "oldPassword", "newPassword"), handler.ChangePassword)
+	return router
+}
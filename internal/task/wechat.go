@@ -0,0 +1,117 @@
+package task
+
+import (
+	"context"
+	"crypto/md5"
+	"fmt"
+	"strings"
+
+	"github.com/hibiken/asynq"
+	"github.com/pkg/errors"
+
+	"github.com/ppxb/oreo-admin-go/internal/model"
+	"github.com/ppxb/oreo-admin-go/pkg/global"
+	"github.com/ppxb/oreo-admin-go/pkg/job"
+	"github.com/ppxb/oreo-admin-go/pkg/log"
+	"github.com/ppxb/oreo-admin-go/pkg/tracing"
+	"github.com/ppxb/oreo-admin-go/pkg/wechat"
+)
+
+const TypeWeChatTplMessage = "wechat:tpl_message"
+
+// WeChatTplMessage is the payload of TypeWeChatTplMessage, Users are openids separated by commas
+type WeChatTplMessage struct {
+	Users               string            `json:"users"`
+	TemplateId          string            `json:"templateId"`
+	MiniProgramAppId    string            `json:"miniProgramAppId"`
+	MiniProgramPagePath string            `json:"miniProgramPagePath"`
+	Data                map[string]string `json:"data"`
+}
+
+func init() {
+	job.Register(TypeWeChatTplMessage, sendWeChatTplMessage)
+}
+
+// sendWeChatTplMessage sends the message to every user and records the results,
+// errcodes of wechat are final for the user while other errors retry the task,
+// client_msg_id lets wechat drop messages already sent by the previous attempt
+func sendWeChatTplMessage(ctx context.Context, payload WeChatTplMessage) error {
+	if global.WeChat == nil {
+		return errors.Wrap(asynq.SkipRetry, "wechat official account is not initialized")
+	}
+	data := make(map[string]wechat.TemplateValue, len(payload.Data))
+	for k, v := range payload.Data {
+		data[k] = wechat.TemplateValue{Value: v}
+	}
+	var miniProgram *wechat.MiniProgram
+	if payload.MiniProgramAppId != "" {
+		miniProgram = &wechat.MiniProgram{
+			AppId:    payload.MiniProgramAppId,
+			PagePath: payload.MiniProgramPagePath,
+		}
+	}
+	taskId, _ := asynq.GetTaskID(ctx)
+
+	var failed error
+	sent := 0
+	for _, user := range strings.Split(payload.Users, ",") {
+		user = strings.TrimSpace(user)
+		if user == "" {
+			continue
+		}
+		msgId, err := global.WeChat.SendTemplateMessage(ctx, wechat.TemplateMessage{
+			ToUser:      user,
+			TemplateId:  payload.TemplateId,
+			MiniProgram: miniProgram,
+			Data:        data,
+			ClientMsgId: fmt.Sprintf("%x", md5.Sum([]byte(taskId+"_"+user))),
+		})
+		record := model.WeChatTplMessageLog{
+			TemplateId: payload.TemplateId,
+			ToUser:     user,
+			MsgId:      msgId,
+			Success:    err == nil,
+			RequestId:  tracing.RequestId(ctx),
+		}
+		var e *wechat.Error
+		switch {
+		case err == nil:
+			sent++
+		case errors.As(err, &e):
+			record.ErrCode = e.Code
+			record.ErrMsg = e.Msg
+			log.WithContext(ctx).WithError(err).WithComponent("wechat").Warn("Send template message failed", "user", user)
+		default:
+			record.ErrMsg = failedMessage(err)
+			failed = err
+			log.WithContext(ctx).WithError(err).WithComponent("wechat").Warn("Send template message failed, retry later", "user", user)
+		}
+		saveWeChatTplMessageLog(ctx, record)
+	}
+	log.WithContext(ctx).WithComponent("wechat").Info("Template message sent", "templateId", payload.TemplateId, "users", sent)
+	return failed
+}
+
+// failedMessage is the stored message of an error which is not an errcode, never err.Error() which may wrap
+// anything, the log of the request id has the details
+func failedMessage(err error) string {
+	var e *wechat.RequestError
+	if errors.As(err, &e) {
+		// the message of RequestError leaves out the query
+		return "network error: " + e.Error()
+	}
+	return "internal error"
+}
+
+func saveWeChatTplMessageLog(ctx context.Context, record model.WeChatTplMessageLog) {
+	db := global.Mysql()
+	if db == nil {
+		return
+	}
+	if msg := []rune(record.ErrMsg); len(msg) > 255 {
+		record.ErrMsg = string(msg[:255])
+	}
+	if err := db.WithContext(ctx).Create(&record).Error; err != nil {
+		log.WithContext(ctx).WithError(err).WithComponent("wechat").Warn("Save template message log failed")
+	}
+}
@@ -1,10 +1,17 @@
 package main
 
 import (
+	"context"
 	"embed"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
 	"runtime"
 	"runtime/debug"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/pkg/errors"
 
@@ -14,6 +21,9 @@ import (
 	"github.com/ppxb/oreo-admin-go/pkg/tracing"
 )
 
+// shutdownTimeout is how long in-flight requests may finish on shutdown
+const shutdownTimeout = 10 * time.Second
+
 //go:embed conf
 var conf embed.FS
 
@@ -22,7 +32,7 @@ func main() {
 
 	defer func() {
 		if err := recover(); err != nil {
-			log.WithContext(ctx).WithError(errors.Errorf("%v", err)).Error("[SERVER] Failed to start server, stack is: %s", string(debug.Stack()))
+			log.WithContext(ctx).WithError(errors.Errorf("%v", err)).WithComponent("server").Error("Failed to start server", "stack", string(debug.Stack()))
 		}
 	}()
 
@@ -30,6 +40,54 @@ func main() {
 	global.RuntimeRoot = strings.TrimSuffix(file, "main.go")
 
 	initialize.Config(ctx, conf)
-	initialize.Mysql(ctx)
-	initialize.Redis(ctx)
+
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := initialize.Migrate(ctx, os.Args[2:]); err != nil {
+			log.WithContext(ctx).WithError(err).WithComponent("migrate").Error("Execute migrate command failed")
+			os.Exit(1)
+		}
+		return
+	}
+
+	if err := initialize.Bootstrap(ctx); err != nil {
+		log.WithContext(ctx).WithError(err).WithComponent("server").Error("Failed to start server")
+		os.Exit(1)
+	}
+
+	handler, err := initialize.Router(ctx)
+	if err != nil {
+		log.WithContext(ctx).WithError(err).WithComponent("server").Error("Failed to start server")
+		os.Exit(1)
+	}
+	srv := &http.Server{
+		Addr:    fmt.Sprintf(":%d", global.Conf.System.Port),
+		Handler: handler,
+	}
+	// log.Fatal closes the listener before exiting
+	log.RegisterExitHook(func() {
+		_ = srv.Close()
+	})
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.WithContext(ctx).WithError(err).WithComponent("server").Error("Failed to start server")
+			os.Exit(1)
+		}
+	}()
+	log.WithContext(ctx).WithComponent("server").Info("Server is running", "addr", srv.Addr)
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+
+	log.WithContext(ctx).WithComponent("server").Info("Shutting down server...")
+	shutdownCtx, cancel := context.WithTimeout(ctx, shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.WithContext(ctx).WithError(err).WithComponent("server").Error("Server forced to shutdown")
+	}
+	if global.Worker != nil {
+		global.Worker.Shutdown()
+	}
+	log.WithContext(ctx).WithComponent("server").Info("Server exited")
+	_ = log.Sync()
 }
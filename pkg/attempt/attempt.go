@@ -0,0 +1,185 @@
+package attempt
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/ppxb/oreo-admin-go/pkg/utils"
+)
+
+// kinds of keys used by the password checks
+const (
+	KindUser = "user"
+	KindIp   = "ip"
+)
+
+// maxMemoryKeys triggers a sweep of expired counts in memory
+const maxMemoryKeys = 10000
+
+// failScript counts a failure, the window starts with the first one
+var failScript = redis.NewScript(`
+local n = redis.call('INCR', KEYS[1])
+if n == 1 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+return n
+`)
+
+// Key is a counted subject, e.g. Key{Kind: "user", Id: "admin"}
+type Key struct {
+	Kind string
+	Id   string
+}
+
+// Limiter blocks keys after too many failures in a fixed window, e.g. password guessing,
+// counts are shared over redis and kept in memory without it
+type Limiter struct {
+	ops Options
+	// rd is ops.redis or the client attached by UseRedis, it never goes back to nil
+	rdLock sync.RWMutex
+	rd     redis.UniversalClient
+	lock   sync.Mutex
+	counts map[string]*count
+}
+
+type count struct {
+	n        int64
+	expireAt time.Time
+}
+
+func New(options ...func(*Options)) *Limiter {
+	ops := getOptionsOrSetDefault(nil)
+	for _, f := range options {
+		f(ops)
+	}
+	l := &Limiter{
+		ops:    *ops,
+		counts: make(map[string]*count),
+	}
+	l.UseRedis(ops.redis)
+	return l
+}
+
+// UseRedis attaches redis to a memory only limiter, e.g. redis connected after a degraded start,
+// it does nothing if redis is attached already
+func (l *Limiter) UseRedis(rd redis.UniversalClient) {
+	if utils.InterfaceIsNil(rd) {
+		return
+	}
+	l.rdLock.Lock()
+	defer l.rdLock.Unlock()
+	if l.rd == nil {
+		l.rd = rd
+	}
+}
+
+func (l *Limiter) client() redis.UniversalClient {
+	l.rdLock.RLock()
+	defer l.rdLock.RUnlock()
+	return l.rd
+}
+
+// Blocked returns how long the first blocked key of keys stays blocked, 0 if none is blocked
+func (l *Limiter) Blocked(ctx context.Context, keys ...Key) (time.Duration, error) {
+	for _, key := range keys {
+		limit, ok := l.ops.limits[key.Kind]
+		if !ok {
+			continue
+		}
+		n, ttl, err := l.get(ctx, l.key(key))
+		if err != nil {
+			return 0, err
+		}
+		if n >= limit && ttl > 0 {
+			return ttl, nil
+		}
+	}
+	return 0, nil
+}
+
+// Fail counts a failure of every key
+func (l *Limiter) Fail(ctx context.Context, keys ...Key) error {
+	for _, key := range keys {
+		if _, ok := l.ops.limits[key.Kind]; !ok {
+			continue
+		}
+		if err := l.incr(ctx, l.key(key)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Reset clears the failures of keys, e.g. the user after a successful login
+func (l *Limiter) Reset(ctx context.Context, keys ...Key) error {
+	names := make([]string, 0, len(keys))
+	for _, key := range keys {
+		names = append(names, l.key(key))
+	}
+	if rd := l.client(); rd != nil {
+		return rd.Del(ctx, names...).Err()
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	for _, name := range names {
+		delete(l.counts, name)
+	}
+	return nil
+}
+
+func (l *Limiter) key(key Key) string {
+	return fmt.Sprintf("%s:%s:%s", l.ops.prefix, key.Kind, key.Id)
+}
+
+func (l *Limiter) get(ctx context.Context, name string) (int64, time.Duration, error) {
+	if rd := l.client(); rd != nil {
+		pipe := rd.Pipeline()
+		get := pipe.Get(ctx, name)
+		ttl := pipe.PTTL(ctx, name)
+		if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+			return 0, 0, err
+		}
+		n, _ := get.Int64()
+		return n, ttl.Val(), nil
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	c, ok := l.counts[name]
+	if !ok {
+		return 0, 0, nil
+	}
+	ttl := time.Until(c.expireAt)
+	if ttl <= 0 {
+		delete(l.counts, name)
+		return 0, 0, nil
+	}
+	return c.n, ttl, nil
+}
+
+func (l *Limiter) incr(ctx context.Context, name string) error {
+	if rd := l.client(); rd != nil {
+		return failScript.Run(ctx, rd, []string{name}, l.ops.window.Milliseconds()).Err()
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	now := time.Now()
+	if len(l.counts) >= maxMemoryKeys {
+		for k, c := range l.counts {
+			if !c.expireAt.After(now) {
+				delete(l.counts, k)
+			}
+		}
+	}
+	c, ok := l.counts[name]
+	if !ok || !c.expireAt.After(now) {
+		c = &count{expireAt: now.Add(l.ops.window)}
+		l.counts[name] = c
+	}
+	c.n++
+	return nil
+}
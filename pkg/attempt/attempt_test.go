@@ -0,0 +1,133 @@
+package attempt
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+const testWindow = 200 * time.Millisecond
+
+// backends returns a memory limiter and two limiters sharing one miniredis, expire lets the window pass
+func backends(t *testing.T) []struct {
+	name   string
+	nodes  []*Limiter
+	expire func()
+} {
+	options := []func(*Options){WithWindow(testWindow), WithLimit(KindUser, 3), WithLimit(KindIp, 5)}
+	mr := miniredis.RunT(t)
+	rd := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rd.Close() })
+	shared := append([]func(*Options){WithRedis(rd)}, options...)
+	return []struct {
+		name   string
+		nodes  []*Limiter
+		expire func()
+	}{
+		{"memory", []*Limiter{New(options...)}, func() { time.Sleep(testWindow + 50*time.Millisecond) }},
+		{"redis", []*Limiter{New(shared...), New(shared...)}, func() { mr.FastForward(testWindow + time.Millisecond) }},
+	}
+}
+
+func TestLimiterBlocks(t *testing.T) {
+	ctx := context.Background()
+	user := Key{Kind: KindUser, Id: "admin"}
+	ip := Key{Kind: KindIp, Id: "1.2.3.4"}
+	for _, b := range backends(t) {
+		t.Run(b.name, func(t *testing.T) {
+			// failures of every node are counted together
+			for i := 0; i < 3; i++ {
+				node := b.nodes[i%len(b.nodes)]
+				if d, err := node.Blocked(ctx, user, ip); err != nil || d != 0 {
+					t.Fatalf("blocked after %d failures: %v, %v", i, d, err)
+				}
+				if err := node.Fail(ctx, user, ip); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for _, node := range b.nodes {
+				d, err := node.Blocked(ctx, user, ip)
+				if err != nil || d <= 0 || d > testWindow {
+					t.Fatalf("blocked = %v, %v, want (0, %v]", d, err, testWindow)
+				}
+			}
+			// the ip is not blocked yet, another user from it is allowed
+			other := Key{Kind: KindUser, Id: "other"}
+			if d, _ := b.nodes[0].Blocked(ctx, other, ip); d != 0 {
+				t.Errorf("other user is blocked for %v", d)
+			}
+			b.expire()
+			if d, err := b.nodes[0].Blocked(ctx, user, ip); err != nil || d != 0 {
+				t.Errorf("blocked after the window: %v, %v", d, err)
+			}
+		})
+	}
+}
+
+func TestLimiterIpBlocksEveryUser(t *testing.T) {
+	ctx := context.Background()
+	ip := Key{Kind: KindIp, Id: "1.2.3.4"}
+	for _, b := range backends(t) {
+		t.Run(b.name, func(t *testing.T) {
+			for i := 0; i < 5; i++ {
+				if err := b.nodes[0].Fail(ctx, Key{Kind: KindUser, Id: string(rune('a' + i))}, ip); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if d, _ := b.nodes[0].Blocked(ctx, Key{Kind: KindUser, Id: "z"}, ip); d <= 0 {
+				t.Error("ip is not blocked after 5 failures")
+			}
+		})
+	}
+}
+
+func TestLimiterReset(t *testing.T) {
+	ctx := context.Background()
+	user := Key{Kind: KindUser, Id: "admin"}
+	for _, b := range backends(t) {
+		t.Run(b.name, func(t *testing.T) {
+			node := b.nodes[0]
+			for i := 0; i < 3; i++ {
+				_ = node.Fail(ctx, user)
+			}
+			if err := node.Reset(ctx, user); err != nil {
+				t.Fatal(err)
+			}
+			if d, _ := b.nodes[len(b.nodes)-1].Blocked(ctx, user); d != 0 {
+				t.Errorf("blocked for %v after reset", d)
+			}
+		})
+	}
+}
+
+func TestLimiterKindWithoutLimit(t *testing.T) {
+	ctx := context.Background()
+	l := New(WithLimit(KindUser, 1))
+	key := Key{Kind: KindIp, Id: "1.2.3.4"}
+	for i := 0; i < 10; i++ {
+		_ = l.Fail(ctx, key)
+	}
+	if d, _ := l.Blocked(ctx, key); d != 0 {
+		t.Errorf("key without a limit is blocked for %v", d)
+	}
+	if len(l.counts) != 0 {
+		t.Errorf("key without a limit is counted: %v", l.counts)
+	}
+}
+
+func TestLimiterUseRedis(t *testing.T) {
+	mr := miniredis.RunT(t)
+	rd := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rd.Close() })
+	l := New(WithLimit(KindUser, 1))
+	l.UseRedis(rd)
+	if err := l.Fail(context.Background(), Key{Kind: KindUser, Id: "admin"}); err != nil {
+		t.Fatal(err)
+	}
+	if !mr.Exists("attempt:user:admin") {
+		t.Errorf("keys = %v, want the count in redis", mr.Keys())
+	}
+}
@@ -0,0 +1,63 @@
+package attempt
+
+import (
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/ppxb/oreo-admin-go/pkg/utils"
+)
+
+type Options struct {
+	redis  redis.UniversalClient
+	prefix string
+	window time.Duration
+	limits map[string]int64
+}
+
+// WithRedis shares counts between nodes, they are kept in memory without it
+func WithRedis(rd redis.UniversalClient) func(*Options) {
+	return func(options *Options) {
+		if !utils.InterfaceIsNil(rd) {
+			getOptionsOrSetDefault(options).redis = rd
+		}
+	}
+}
+
+// WithPrefix namespaces redis keys
+func WithPrefix(s string) func(*Options) {
+	return func(options *Options) {
+		if s != "" {
+			getOptionsOrSetDefault(options).prefix = s
+		}
+	}
+}
+
+// WithWindow is how long failures are counted, a blocked key is released when its window ends
+func WithWindow(d time.Duration) func(*Options) {
+	return func(options *Options) {
+		if d > 0 {
+			getOptionsOrSetDefault(options).window = d
+		}
+	}
+}
+
+// WithLimit blocks keys of kind after max failures, keys of kinds without a limit are not counted
+func WithLimit(kind string, max int64) func(*Options) {
+	return func(options *Options) {
+		if max > 0 {
+			getOptionsOrSetDefault(options).limits[kind] = max
+		}
+	}
+}
+
+func getOptionsOrSetDefault(options *Options) *Options {
+	if options == nil {
+		return &Options{
+			prefix: "attempt",
+			window: 15 * time.Minute,
+			limits: make(map[string]int64),
+		}
+	}
+	return options
+}
@@ -0,0 +1,250 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/sync/singleflight"
+	"gorm.io/gorm"
+
+	"github.com/ppxb/oreo-admin-go/pkg/log"
+	"github.com/ppxb/oreo-admin-go/pkg/utils"
+)
+
+// ErrNotFound is returned on a miss and for negative cached keys
+var ErrNotFound = errors.New("cache: not found")
+
+// Cache is a cache-aside store with a memory L1 in front of a redis L2,
+// L1 of other nodes is invalidated over redis pub/sub, it is memory only without redis
+type Cache struct {
+	ops   Options
+	node  string
+	l1    *memory
+	group singleflight.Group
+	// rd is ops.redis or the client attached by UseRedis, it never goes back to nil
+	rdLock sync.RWMutex
+	rd     redis.UniversalClient
+}
+
+// entry is the stored envelope of a value, values are JSON so that every node decodes them into its own type
+type entry struct {
+	Value    json.RawMessage `json:"v,omitempty"`
+	Negative bool            `json:"n,omitempty"`
+	Tags     []string        `json:"t,omitempty"`
+	// ExpireAt is unix milliseconds, L1 never outlives L2
+	ExpireAt int64 `json:"e"`
+}
+
+func New(options ...func(*Options)) *Cache {
+	ops := getOptionsOrSetDefault(nil)
+	for _, f := range options {
+		f(ops)
+	}
+	c := &Cache{
+		ops:  *ops,
+		node: uuid.NewString(),
+		l1:   newMemory(ops.l1Size),
+	}
+	c.UseRedis(ops.redis)
+	return c
+}
+
+// UseRedis attaches redis to a memory only cache, e.g. redis connected after a degraded start,
+// it does nothing if redis is attached already
+func (c *Cache) UseRedis(rd redis.UniversalClient) {
+	if utils.InterfaceIsNil(rd) {
+		return
+	}
+	c.rdLock.Lock()
+	defer c.rdLock.Unlock()
+	if c.rd != nil {
+		return
+	}
+	c.rd = rd
+	// entries cached without redis missed the invalidations of other nodes
+	c.l1.clear()
+	go c.subscribe(rd)
+}
+
+func (c *Cache) client() redis.UniversalClient {
+	c.rdLock.RLock()
+	defer c.rdLock.RUnlock()
+	return c.rd
+}
+
+// Get returns the cached value of key, ErrNotFound on a miss
+func Get[T any](ctx context.Context, c *Cache, key string) (T, error) {
+	var v T
+	e, err := c.get(ctx, key)
+	if err != nil {
+		return v, err
+	}
+	return decode[T](e)
+}
+
+func Set[T any](ctx context.Context, c *Cache, key string, value T, options ...func(*EntryOptions)) error {
+	ops := getEntryOptionsOrSetDefault(nil)
+	for _, f := range options {
+		f(ops)
+	}
+	b, err := json.Marshal(value)
+	if err != nil {
+		return errors.Wrapf(err, "encode cache %s", key)
+	}
+	return c.set(ctx, key, &entry{Value: b, Tags: ops.tags}, ops.ttl)
+}
+
+// GetOrLoad returns the cached value of key or stores the result of load,
+// concurrent misses of the same key on a node share one load.
+// load returns ErrNotFound or gorm.ErrRecordNotFound for missing data, which is cached
+// for the negative ttl and always reported as ErrNotFound
+func GetOrLoad[T any](ctx context.Context, c *Cache, key string, load func(ctx context.Context) (T, error), options ...func(*EntryOptions)) (T, error) {
+	var v T
+	res, err, _ := c.group.Do(key, func() (interface{}, error) {
+		e, err := c.get(ctx, key)
+		if err == nil {
+			return e, nil
+		}
+		if !errors.Is(err, ErrNotFound) {
+			log.WithContext(ctx).WithError(err).WithComponent("cache").Warn("Get failed, load it", "key", key)
+		}
+
+		ops := getEntryOptionsOrSetDefault(nil)
+		for _, f := range options {
+			f(ops)
+		}
+		// waiters share the load, so the first caller canceling must not fail the others
+		value, err := load(context.WithoutCancel(ctx))
+		if errors.Is(err, ErrNotFound) || errors.Is(err, gorm.ErrRecordNotFound) {
+			e = &entry{Negative: true, Tags: ops.tags}
+			if c.ops.negativeTtl > 0 {
+				c.store(ctx, key, e, c.ops.negativeTtl)
+			}
+			return e, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		b, err := json.Marshal(value)
+		if err != nil {
+			return nil, errors.Wrapf(err, "encode cache %s", key)
+		}
+		e = &entry{Value: b, Tags: ops.tags}
+		c.store(ctx, key, e, ops.ttl)
+		return e, nil
+	})
+	if err != nil {
+		return v, err
+	}
+	return decode[T](res.(*entry))
+}
+
+// Delete evicts keys on every node
+func (c *Cache) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	c.l1.del(keys...)
+	rd := c.client()
+	if rd == nil {
+		return nil
+	}
+	return c.delRemote(ctx, rd, keys)
+}
+
+// InvalidateTags evicts every entry with any of the tags on every node, e.g. user:42
+func (c *Cache) InvalidateTags(ctx context.Context, tags ...string) error {
+	c.l1.del(c.l1.tagged(tags...)...)
+	rd := c.client()
+	if rd == nil {
+		return nil
+	}
+	var keys []string
+	for _, tag := range tags {
+		items, err := popTagScript.Run(ctx, rd, []string{c.tagKey(tag)}).StringSlice()
+		if err != nil {
+			return errors.Wrapf(err, "invalidate tag %s", tag)
+		}
+		keys = append(keys, items...)
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	c.l1.del(keys...)
+	return c.delRemote(ctx, rd, keys)
+}
+
+func (c *Cache) get(ctx context.Context, key string) (*entry, error) {
+	if e, ok := c.l1.get(key); ok {
+		return e, nil
+	}
+	rd := c.client()
+	if rd == nil {
+		return nil, ErrNotFound
+	}
+	e, err := c.getRemote(ctx, rd, key)
+	if err != nil {
+		return nil, err
+	}
+	c.l1.set(key, e, c.l1ExpireAt(e))
+	return e, nil
+}
+
+func (c *Cache) set(ctx context.Context, key string, e *entry, ttl time.Duration) error {
+	if ttl <= 0 {
+		ttl = c.ops.ttl
+	}
+	ttl = c.jitter(ttl)
+	e.ExpireAt = time.Now().Add(ttl).UnixMilli()
+	if rd := c.client(); rd != nil {
+		if err := c.setRemote(ctx, rd, key, e, ttl); err != nil {
+			// a stale L1 value is worse than a miss
+			c.l1.del(key)
+			return err
+		}
+	}
+	c.l1.set(key, e, c.l1ExpireAt(e))
+	return nil
+}
+
+// store is set of GetOrLoad, the loaded value is returned even if it can not be cached
+func (c *Cache) store(ctx context.Context, key string, e *entry, ttl time.Duration) {
+	if err := c.set(ctx, key, e, ttl); err != nil {
+		log.WithContext(ctx).WithError(err).WithComponent("cache").Warn("Set failed", "key", key)
+	}
+}
+
+func (c *Cache) jitter(ttl time.Duration) time.Duration {
+	if n := int64(float64(ttl) * c.ops.jitter); n > 0 {
+		ttl += time.Duration(rand.Int64N(n))
+	}
+	return ttl
+}
+
+func (c *Cache) l1ExpireAt(e *entry) time.Time {
+	expireAt := time.UnixMilli(e.ExpireAt)
+	if c.client() == nil {
+		return expireAt
+	}
+	if max := time.Now().Add(c.ops.l1Ttl); max.Before(expireAt) {
+		return max
+	}
+	return expireAt
+}
+
+func decode[T any](e *entry) (T, error) {
+	var v T
+	if e.Negative {
+		return v, ErrNotFound
+	}
+	if err := json.Unmarshal(e.Value, &v); err != nil {
+		return v, errors.Wrap(err, "decode cache")
+	}
+	return v, nil
+}
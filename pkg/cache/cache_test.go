@@ -0,0 +1,336 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+const testPrefix = "test_cache"
+
+// newNodes returns n caches sharing one miniredis, they are subscribed to invalidations when returned
+func newNodes(t *testing.T, n int, options ...func(*Options)) ([]*Cache, *miniredis.Miniredis) {
+	mr := miniredis.RunT(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	nodes := make([]*Cache, 0, n)
+	for i := 0; i < n; i++ {
+		rd := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+		t.Cleanup(func() { rd.Close() })
+		nodes = append(nodes, New(append([]func(*Options){
+			WithCtx(ctx),
+			WithRedis(rd),
+			WithPrefix(testPrefix),
+			WithJitter(0),
+		}, options...)...))
+	}
+	eventually(t, "subscriptions", func() bool {
+		return mr.PubSubNumSub(testPrefix + ":invalidate")[testPrefix+":invalidate"] == n
+	})
+	return nodes, mr
+}
+
+func eventually(t *testing.T, name string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for %s", name)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+type item struct {
+	Name string `json:"name"`
+}
+
+func TestGetOrLoadSingleflight(t *testing.T) {
+	nodes, _ := newNodes(t, 1)
+	c := nodes[0]
+	var loads atomic.Int32
+	load := func(ctx context.Context) (item, error) {
+		loads.Add(1)
+		time.Sleep(100 * time.Millisecond)
+		return item{Name: "loaded"}, nil
+	}
+
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			v, err := GetOrLoad(context.Background(), c, "item:1", load)
+			if err == nil && v.Name != "loaded" {
+				err = errors.New("unexpected value " + v.Name)
+			}
+			errs <- err
+		}()
+	}
+	close(start)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := loads.Load(); n != 1 {
+		t.Errorf("loads = %d, want 1", n)
+	}
+	// later calls are served from the cache
+	if _, err := GetOrLoad(context.Background(), c, "item:1", load); err != nil || loads.Load() != 1 {
+		t.Errorf("loads = %d, %v after the value is cached", loads.Load(), err)
+	}
+}
+
+func TestGetOrLoadCanceledCallerDoesNotFailWaiters(t *testing.T) {
+	c := New(WithJitter(0))
+	ctx, cancel := context.WithCancel(context.Background())
+	started := make(chan struct{})
+	load := func(ctx context.Context) (item, error) {
+		close(started)
+		cancel()
+		time.Sleep(50 * time.Millisecond)
+		return item{Name: "loaded"}, ctx.Err()
+	}
+	done := make(chan error, 1)
+	go func() {
+		_, err := GetOrLoad(ctx, c, "item:1", load)
+		done <- err
+	}()
+	<-started
+	v, err := GetOrLoad(context.Background(), c, "item:1", load)
+	if err != nil || v.Name != "loaded" {
+		t.Errorf("waiter = %+v, %v", v, err)
+	}
+	if err = <-done; err != nil {
+		t.Errorf("canceled caller = %v", err)
+	}
+}
+
+func TestGetOrLoadNegative(t *testing.T) {
+	tests := []struct {
+		name        string
+		negativeTtl time.Duration
+		loadErr     error
+		// loads after 3 calls within the negative ttl
+		loads int32
+	}{
+		{"not found is cached", 100 * time.Millisecond, ErrNotFound, 1},
+		{"record not found is cached", 100 * time.Millisecond, gorm.ErrRecordNotFound, 1},
+		{"disabled", 0, ErrNotFound, 3},
+		{"other errors are not cached", 100 * time.Millisecond, errors.New("db down"), 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nodes, mr := newNodes(t, 1, WithNegativeTtl(tt.negativeTtl))
+			c := nodes[0]
+			var loads atomic.Int32
+			load := func(ctx context.Context) (item, error) {
+				loads.Add(1)
+				return item{}, tt.loadErr
+			}
+			for i := 0; i < 3; i++ {
+				_, err := GetOrLoad(context.Background(), c, "item:404", load)
+				want := ErrNotFound
+				if !errors.Is(tt.loadErr, ErrNotFound) && !errors.Is(tt.loadErr, gorm.ErrRecordNotFound) {
+					want = tt.loadErr
+				}
+				if !errors.Is(err, want) {
+					t.Fatalf("err = %v, want %v", err, want)
+				}
+			}
+			if n := loads.Load(); n != tt.loads {
+				t.Fatalf("loads = %d, want %d", n, tt.loads)
+			}
+			if tt.loads != 1 {
+				return
+			}
+			// the negative entry expires like any other entry
+			time.Sleep(tt.negativeTtl + 20*time.Millisecond)
+			mr.FastForward(tt.negativeTtl)
+			_, _ = GetOrLoad(context.Background(), c, "item:404", load)
+			if n := loads.Load(); n != 2 {
+				t.Errorf("loads = %d after the negative ttl, want 2", n)
+			}
+		})
+	}
+}
+
+func TestTtlJitter(t *testing.T) {
+	tests := []struct {
+		jitter float64
+		ttl    time.Duration
+	}{
+		{0, time.Minute},
+		{0.1, time.Minute},
+		{0.5, 10 * time.Second},
+	}
+	for _, tt := range tests {
+		nodes, mr := newNodes(t, 1, WithJitter(tt.jitter))
+		c := nodes[0]
+		high := tt.ttl + time.Duration(float64(tt.ttl)*tt.jitter)
+		for i := 0; i < 200; i++ {
+			if d := c.jitter(tt.ttl); d < tt.ttl || (tt.jitter > 0 && d >= high) || (tt.jitter == 0 && d != tt.ttl) {
+				t.Fatalf("jitter %v of %v = %v, want [%v, %v)", tt.jitter, tt.ttl, d, tt.ttl, high)
+			}
+		}
+		if err := Set(context.Background(), c, "item:1", item{Name: "a"}, WithTtl(tt.ttl)); err != nil {
+			t.Fatal(err)
+		}
+		if d := mr.TTL(testPrefix + ":item:1"); d < tt.ttl || d > high {
+			t.Errorf("redis ttl with jitter %v = %v, want [%v, %v]", tt.jitter, d, tt.ttl, high)
+		}
+	}
+}
+
+func TestInvalidateTags(t *testing.T) {
+	nodes, _ := newNodes(t, 2)
+	ctx := context.Background()
+	entries := []struct {
+		key  string
+		tags []string
+	}{
+		{"user:1:profile", []string{"user:1"}},
+		{"user:1:roles", []string{"user:1", "roles"}},
+		{"user:2:profile", []string{"user:2"}},
+	}
+	for _, e := range entries {
+		if err := Set(ctx, nodes[0], e.key, item{Name: e.key}, WithTags(e.tags...)); err != nil {
+			t.Fatal(err)
+		}
+		// fill the L1 of the other node
+		if _, err := Get[item](ctx, nodes[1], e.key); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := nodes[1].InvalidateTags(ctx, "user:1"); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		key     string
+		removed bool
+	}{
+		{"user:1:profile", true},
+		{"user:1:roles", true},
+		{"user:2:profile", false},
+	}
+	for _, tt := range tests {
+		for i, c := range nodes {
+			eventually(t, tt.key, func() bool {
+				_, err := Get[item](ctx, c, tt.key)
+				return errors.Is(err, ErrNotFound) == tt.removed
+			})
+			if _, err := Get[item](ctx, c, tt.key); errors.Is(err, ErrNotFound) != tt.removed {
+				t.Errorf("node %d: %s err = %v, removed %v", i, tt.key, err, tt.removed)
+			}
+		}
+	}
+}
+
+func TestCrossNodeInvalidation(t *testing.T) {
+	nodes, _ := newNodes(t, 2)
+	ctx := context.Background()
+	if err := Set(ctx, nodes[0], "item:1", item{Name: "v1"}); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := Get[item](ctx, nodes[1], "item:1"); err != nil || v.Name != "v1" {
+		t.Fatalf("node 1 = %+v, %v", v, err)
+	}
+
+	// a set on node 0 evicts the L1 entry of node 1
+	if err := Set(ctx, nodes[0], "item:1", item{Name: "v2"}); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "updated value on node 1", func() bool {
+		v, err := Get[item](ctx, nodes[1], "item:1")
+		return err == nil && v.Name == "v2"
+	})
+
+	if err := nodes[0].Delete(ctx, "item:1"); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "deleted value on node 1", func() bool {
+		_, err := Get[item](ctx, nodes[1], "item:1")
+		return errors.Is(err, ErrNotFound)
+	})
+
+	// a node keeps its own L1 entry on its own messages
+	if err := Set(ctx, nodes[0], "item:2", item{Name: "own"}); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if _, ok := nodes[0].l1.get("item:2"); !ok {
+		t.Error("own invalidation evicted the L1 entry")
+	}
+}
+
+func TestMemoryOnly(t *testing.T) {
+	ctx := context.Background()
+	c := New(WithJitter(0))
+	if _, err := Get[item](ctx, c, "item:1"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("miss err = %v", err)
+	}
+	if err := Set(ctx, c, "item:1", item{Name: "a"}, WithTags("t")); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := Get[item](ctx, c, "item:1"); err != nil || v.Name != "a" {
+		t.Fatalf("get = %+v, %v", v, err)
+	}
+	if err := c.InvalidateTags(ctx, "t"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Get[item](ctx, c, "item:1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("tagged entry err = %v", err)
+	}
+
+	var loads int
+	load := func(ctx context.Context) (item, error) {
+		loads++
+		return item{Name: "loaded"}, nil
+	}
+	for i := 0; i < 2; i++ {
+		if v, err := GetOrLoad(ctx, c, "item:2", load); err != nil || v.Name != "loaded" {
+			t.Fatalf("load = %+v, %v", v, err)
+		}
+	}
+	if loads != 1 {
+		t.Errorf("loads = %d, want 1", loads)
+	}
+	if err := c.Delete(ctx, "item:2"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Get[item](ctx, c, "item:2"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("deleted entry err = %v", err)
+	}
+
+	// entries cached without redis are dropped when it is attached
+	if err := Set(ctx, c, "item:3", item{Name: "memory"}); err != nil {
+		t.Fatal(err)
+	}
+	mr := miniredis.RunT(t)
+	rd := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rd.Close() })
+	c.UseRedis(rd)
+	if _, err := Get[item](ctx, c, "item:3"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("memory entry after UseRedis err = %v", err)
+	}
+	if err := Set(ctx, c, "item:3", item{Name: "redis"}); err != nil {
+		t.Fatal(err)
+	}
+	if !mr.Exists("cache:item:3") {
+		t.Errorf("keys = %v after UseRedis", mr.Keys())
+	}
+}
@@ -0,0 +1,111 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// memory is the L1, a size bounded lru with a tag index
+type memory struct {
+	lock  sync.Mutex
+	size  int
+	lru   *list.List
+	items map[string]*list.Element
+	tags  map[string]map[string]struct{}
+}
+
+type memoryItem struct {
+	key      string
+	entry    *entry
+	expireAt time.Time
+}
+
+func newMemory(size int) *memory {
+	return &memory{
+		size:  size,
+		lru:   list.New(),
+		items: make(map[string]*list.Element),
+		tags:  make(map[string]map[string]struct{}),
+	}
+}
+
+func (m *memory) get(key string) (*entry, bool) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	el, ok := m.items[key]
+	if !ok {
+		return nil, false
+	}
+	item := el.Value.(*memoryItem)
+	if time.Now().After(item.expireAt) {
+		m.remove(el)
+		return nil, false
+	}
+	m.lru.MoveToFront(el)
+	return item.entry, true
+}
+
+func (m *memory) set(key string, e *entry, expireAt time.Time) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if el, ok := m.items[key]; ok {
+		m.remove(el)
+	}
+	m.items[key] = m.lru.PushFront(&memoryItem{key: key, entry: e, expireAt: expireAt})
+	for _, tag := range e.Tags {
+		keys, ok := m.tags[tag]
+		if !ok {
+			keys = make(map[string]struct{})
+			m.tags[tag] = keys
+		}
+		keys[key] = struct{}{}
+	}
+	for m.lru.Len() > m.size {
+		m.remove(m.lru.Back())
+	}
+}
+
+func (m *memory) del(keys ...string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	for _, key := range keys {
+		if el, ok := m.items[key]; ok {
+			m.remove(el)
+		}
+	}
+}
+
+func (m *memory) clear() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.lru.Init()
+	m.items = make(map[string]*list.Element)
+	m.tags = make(map[string]map[string]struct{})
+}
+
+// tagged returns keys of entries with any of the tags
+func (m *memory) tagged(tags ...string) []string {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	var keys []string
+	for _, tag := range tags {
+		for key := range m.tags[tag] {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+func (m *memory) remove(el *list.Element) {
+	item := m.lru.Remove(el).(*memoryItem)
+	delete(m.items, item.key)
+	for _, tag := range item.entry.Tags {
+		if keys, ok := m.tags[tag]; ok {
+			delete(keys, item.key)
+			if len(keys) == 0 {
+				delete(m.tags, tag)
+			}
+		}
+	}
+}
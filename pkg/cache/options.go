@@ -0,0 +1,135 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/ppxb/oreo-admin-go/pkg/utils"
+)
+
+type Options struct {
+	ctx         context.Context
+	redis       redis.UniversalClient
+	prefix      string
+	ttl         time.Duration
+	jitter      float64
+	negativeTtl time.Duration
+	l1Size      int
+	l1Ttl       time.Duration
+}
+
+func WithCtx(ctx context.Context) func(*Options) {
+	return func(options *Options) {
+		if !utils.InterfaceIsNil(ctx) {
+			getOptionsOrSetDefault(options).ctx = ctx
+		}
+	}
+}
+
+// WithRedis enables the redis L2, the cache is memory only without it
+func WithRedis(rd redis.UniversalClient) func(*Options) {
+	return func(options *Options) {
+		if !utils.InterfaceIsNil(rd) {
+			getOptionsOrSetDefault(options).redis = rd
+		}
+	}
+}
+
+// WithPrefix namespaces redis keys and the invalidation channel
+func WithPrefix(s string) func(*Options) {
+	return func(options *Options) {
+		if s != "" {
+			getOptionsOrSetDefault(options).prefix = s
+		}
+	}
+}
+
+// WithDefaultTtl is used by entries without WithTtl
+func WithDefaultTtl(ttl time.Duration) func(*Options) {
+	return func(options *Options) {
+		if ttl > 0 {
+			getOptionsOrSetDefault(options).ttl = ttl
+		}
+	}
+}
+
+// WithJitter adds a random [0, ttl*jitter) to every ttl so that entries written together do not expire together
+func WithJitter(jitter float64) func(*Options) {
+	return func(options *Options) {
+		if jitter >= 0 {
+			getOptionsOrSetDefault(options).jitter = jitter
+		}
+	}
+}
+
+// WithNegativeTtl is how long GetOrLoad remembers not found, 0 disables negative caching
+func WithNegativeTtl(ttl time.Duration) func(*Options) {
+	return func(options *Options) {
+		if ttl >= 0 {
+			getOptionsOrSetDefault(options).negativeTtl = ttl
+		}
+	}
+}
+
+// WithL1Size is the max entries of the memory cache, least recently used entries are evicted
+func WithL1Size(size int) func(*Options) {
+	return func(options *Options) {
+		if size > 0 {
+			getOptionsOrSetDefault(options).l1Size = size
+		}
+	}
+}
+
+// WithL1Ttl caps how long the memory cache keeps an entry in front of redis,
+// it bounds staleness if an invalidation message is lost
+func WithL1Ttl(ttl time.Duration) func(*Options) {
+	return func(options *Options) {
+		if ttl > 0 {
+			getOptionsOrSetDefault(options).l1Ttl = ttl
+		}
+	}
+}
+
+func getOptionsOrSetDefault(options *Options) *Options {
+	if options == nil {
+		return &Options{
+			ctx:         context.Background(),
+			prefix:      "cache",
+			ttl:         10 * time.Minute,
+			jitter:      0.1,
+			negativeTtl: time.Minute,
+			l1Size:      10000,
+			l1Ttl:       time.Minute,
+		}
+	}
+	return options
+}
+
+type EntryOptions struct {
+	ttl  time.Duration
+	tags []string
+}
+
+func WithTtl(ttl time.Duration) func(*EntryOptions) {
+	return func(options *EntryOptions) {
+		if ttl > 0 {
+			getEntryOptionsOrSetDefault(options).ttl = ttl
+		}
+	}
+}
+
+// WithTags attaches tags to the entry, see Cache.InvalidateTags
+func WithTags(tags ...string) func(*EntryOptions) {
+	return func(options *EntryOptions) {
+		getEntryOptionsOrSetDefault(options).tags = append(getEntryOptionsOrSetDefault(options).tags, tags...)
+	}
+}
+
+func getEntryOptionsOrSetDefault(options *EntryOptions) *EntryOptions {
+	if options == nil {
+		return &EntryOptions{}
+	}
+	return options
+}
@@ -0,0 +1,126 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/ppxb/oreo-admin-go/pkg/log"
+)
+
+var (
+	// tagScript adds the key to the tag set, the set lives as long as its longest entry
+	tagScript = redis.NewScript(`
+redis.call('SADD', KEYS[1], ARGV[1])
+local ttl = redis.call('PTTL', KEYS[1])
+if ttl == -1 or ttl < tonumber(ARGV[2]) then
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 1
+`)
+	popTagScript = redis.NewScript(`
+local keys = redis.call('SMEMBERS', KEYS[1])
+redis.call('DEL', KEYS[1])
+return keys
+`)
+)
+
+type invalidation struct {
+	Node string   `json:"node"`
+	Keys []string `json:"keys"`
+}
+
+func (c *Cache) key(key string) string {
+	return c.ops.prefix + ":" + key
+}
+
+func (c *Cache) tagKey(tag string) string {
+	return c.ops.prefix + ":tag:" + tag
+}
+
+func (c *Cache) channel() string {
+	return c.ops.prefix + ":invalidate"
+}
+
+func (c *Cache) getRemote(ctx context.Context, rd redis.UniversalClient, key string) (*entry, error) {
+	b, err := rd.Get(ctx, c.key(key)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var e entry
+	if err = json.Unmarshal(b, &e); err != nil {
+		return nil, errors.Wrapf(err, "decode cache %s", key)
+	}
+	return &e, nil
+}
+
+func (c *Cache) setRemote(ctx context.Context, rd redis.UniversalClient, key string, e *entry, ttl time.Duration) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	msg, err := c.invalidation(key)
+	if err != nil {
+		return err
+	}
+	pipe := rd.Pipeline()
+	pipe.Set(ctx, c.key(key), b, ttl)
+	for _, tag := range e.Tags {
+		tagScript.Eval(ctx, pipe, []string{c.tagKey(tag)}, key, ttl.Milliseconds())
+	}
+	pipe.Publish(ctx, c.channel(), msg)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+func (c *Cache) delRemote(ctx context.Context, rd redis.UniversalClient, keys []string) error {
+	msg, err := c.invalidation(keys...)
+	if err != nil {
+		return err
+	}
+	pipe := rd.Pipeline()
+	// one command per key, keys of a cluster may be in different slots
+	for _, key := range keys {
+		pipe.Del(ctx, c.key(key))
+	}
+	pipe.Publish(ctx, c.channel(), msg)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+func (c *Cache) invalidation(keys ...string) (string, error) {
+	b, err := json.Marshal(invalidation{Node: c.node, Keys: keys})
+	return string(b), err
+}
+
+// subscribe evicts L1 entries changed by other nodes until ctx is done
+func (c *Cache) subscribe(rd redis.UniversalClient) {
+	ctx := c.ops.ctx
+	sub := rd.Subscribe(ctx, c.channel())
+	defer sub.Close()
+	ch := sub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
+			var item invalidation
+			if err := json.Unmarshal([]byte(msg.Payload), &item); err != nil {
+				log.WithContext(ctx).WithError(err).WithComponent("cache").Warn("Invalid invalidation message", "payload", msg.Payload)
+				continue
+			}
+			if item.Node != c.node {
+				c.l1.del(item.Keys...)
+			}
+		}
+	}
+}
@@ -0,0 +1,416 @@
+package cache_service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	gomysql "github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-mysql-org/go-mysql/replication"
+	m "github.com/go-sql-driver/mysql"
+	"github.com/pkg/errors"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/ppxb/oreo-admin-go/pkg/lock"
+	"github.com/ppxb/oreo-admin-go/pkg/log"
+	"github.com/ppxb/oreo-admin-go/pkg/query"
+)
+
+const (
+	actionInsert = "insert"
+	actionUpdate = "update"
+	actionDelete = "delete"
+)
+
+var (
+	// ErrPosLost means the checkpoint points to a binlog which was purged, a full resync is needed
+	ErrPosLost       = errors.New("binlog position is no longer available on source")
+	errSchemaChanged = errors.New("table schema changed")
+	ddlRe            = regexp.MustCompile(`(?i)^\s*(/\*.*?\*/\s*)*(alter|create|drop|rename|truncate)\s`)
+)
+
+// Binlog mirrors rows of the source database into redis hashes read by query.Redis,
+// the source needs log_bin=ON, binlog_format=ROW and binlog_row_image=FULL
+type Binlog struct {
+	ops Options
+	// ctx is done when the stream must stop, i.e. ops.ctx is done or the lock is lost
+	ctx context.Context
+	cfg *m.Config
+	db  *sql.DB
+	// pos is the last committed position, the stream always resumes from a transaction boundary
+	pos    position
+	tables map[string]*tableMeta
+}
+
+type position struct {
+	Name string `json:"name"`
+	Pos  uint32 `json:"pos"`
+}
+
+func (p position) String() string {
+	return fmt.Sprintf("%s:%d", p.Name, p.Pos)
+}
+
+func New(options ...func(*Options)) (*Binlog, error) {
+	ops := getOptionsOrSetDefault(nil)
+	for _, f := range options {
+		f(ops)
+	}
+	if ops.redis == nil {
+		return nil, errors.New("redis client is empty")
+	}
+	cfg, err := m.ParseDSN(ops.uri)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.DBName == "" {
+		return nil, errors.New("database name is empty")
+	}
+	// the full resync reads time columns as time.Time like the binlog decoder
+	cfg.ParseTime = true
+	connector, err := m.NewConnector(cfg)
+	if err != nil {
+		return nil, err
+	}
+	db := sql.OpenDB(connector)
+	db.SetMaxOpenConns(2)
+
+	return &Binlog{
+		ops:    *ops,
+		ctx:    ops.ctx,
+		cfg:    cfg,
+		db:     db,
+		tables: make(map[string]*tableMeta),
+	}, nil
+}
+
+// Run blocks until ctx is done, the stream reconnects from the last checkpoint after errors
+// and runs a full resync when the checkpoint is missing or purged, with WithLocker only the
+// node holding the lock streams and it stops when the lock is lost
+func (b *Binlog) Run() error {
+	defer b.db.Close()
+	if err := b.check(); err != nil {
+		return err
+	}
+	if b.ops.locker == nil {
+		b.sync(b.ops.ctx)
+		return nil
+	}
+	for {
+		lease, err := b.ops.locker.Lock(b.ops.ctx, b.ops.posKey)
+		if b.ops.ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			log.WithContext(b.ops.ctx).WithError(err).WithComponent("binlog").Warn("Acquire binlog lock failed", "retryIn", b.ops.retryInterval)
+			select {
+			case <-b.ops.ctx.Done():
+				return nil
+			case <-time.After(b.ops.retryInterval):
+			}
+			continue
+		}
+		b.lead(lease)
+		if err = lease.Unlock(context.Background()); err != nil {
+			log.WithContext(b.ops.ctx).WithError(err).WithComponent("binlog").Debug("Release binlog lock failed")
+		}
+		if b.ops.ctx.Err() != nil {
+			return nil
+		}
+	}
+}
+
+// lead streams until the lease is lost, the next leader may have moved the checkpoint meanwhile
+func (b *Binlog) lead(lease lock.Lease) {
+	ctx, cancel := context.WithCancel(b.ops.ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-lease.Lost():
+			log.WithContext(ctx).WithComponent("binlog").Warn("Binlog lock is lost, stop stream")
+			cancel()
+		}
+	}()
+	log.WithContext(ctx).WithComponent("binlog").Info("Binlog lock acquired", "token", lease.Token())
+	b.pos = position{}
+	b.tables = make(map[string]*tableMeta)
+	b.sync(ctx)
+}
+
+// sync streams until ctx is done, interruptions are retried after retryInterval
+func (b *Binlog) sync(ctx context.Context) {
+	b.ctx = ctx
+	for {
+		err := b.stream()
+		if b.ctx.Err() != nil {
+			return
+		}
+		if errors.Is(err, ErrPosLost) {
+			log.WithContext(b.ctx).WithError(err).WithComponent("binlog").Warn("Checkpoint is lost, start full resync", "pos", b.pos)
+			b.pos = position{}
+			// start would load the same checkpoint again
+			if err = b.ops.redis.Del(b.ctx, b.ops.posKey).Err(); err == nil {
+				continue
+			}
+		}
+		log.WithContext(b.ctx).WithError(err).WithComponent("binlog").Error("Stream interrupted", "pos", b.pos, "retryIn", b.ops.retryInterval)
+		select {
+		case <-b.ctx.Done():
+			return
+		case <-time.After(b.ops.retryInterval):
+		}
+	}
+}
+
+func (b *Binlog) check() error {
+	values, err := b.queryFirst("SELECT @@global.log_bin, @@global.binlog_format, @@global.binlog_row_image")
+	if err != nil {
+		return errors.Wrap(err, "read binlog variables")
+	}
+	if s := strings.ToUpper(string(values[0])); s != "1" && s != "ON" {
+		return errors.New("binlog is not enabled, set log_bin=ON")
+	}
+	if s := strings.ToUpper(string(values[1])); s != "ROW" {
+		return errors.Errorf("binlog_format must be ROW, got %s", s)
+	}
+	if s := strings.ToUpper(string(values[2])); s != "FULL" {
+		return errors.Errorf("binlog_row_image must be FULL, got %s", s)
+	}
+	return nil
+}
+
+func (b *Binlog) stream() error {
+	if b.pos.Name == "" {
+		pos, err := b.start()
+		if err != nil {
+			return err
+		}
+		b.pos = pos
+	}
+
+	syncer := replication.NewBinlogSyncer(replication.BinlogSyncerConfig{
+		ServerID: b.ops.serverId,
+		Flavor:   gomysql.MySQLFlavor,
+		// port 0 makes the syncer dial Addr as is, which may be a unix socket
+		Host:             b.cfg.Addr,
+		User:             b.cfg.User,
+		Password:         b.cfg.Passwd,
+		TLSConfig:        b.cfg.TLS,
+		ParseTime:        true,
+		HeartbeatPeriod:  b.ops.heartbeat,
+		ReadTimeout:      2 * b.ops.heartbeat,
+		DisableRetrySync: true,
+		Logger:           newLogger(b.ctx),
+	})
+	defer syncer.Close()
+
+	streamer, err := syncer.StartSync(gomysql.Position{Name: b.pos.Name, Pos: b.pos.Pos})
+	if err != nil {
+		return posErr(err)
+	}
+	log.WithContext(b.ctx).WithComponent("binlog").Info("Stream started", "pos", b.pos)
+
+	for {
+		e, err := streamer.GetEvent(b.ctx)
+		if err != nil {
+			return posErr(err)
+		}
+		if err = b.handle(e); err != nil {
+			return err
+		}
+	}
+}
+
+// start loads the checkpoint, or resyncs every table if it is missing or purged
+func (b *Binlog) start() (position, error) {
+	var pos position
+	s, err := b.ops.redis.Get(b.ctx, b.ops.posKey).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return pos, err
+	}
+	if s != "" {
+		if err = json.Unmarshal([]byte(s), &pos); err != nil {
+			log.WithContext(b.ctx).WithError(err).WithComponent("binlog").Warn("Invalid checkpoint", "checkpoint", s)
+		}
+	}
+	if pos.Name != "" {
+		ok, err := b.posExists(pos)
+		if err != nil {
+			return pos, err
+		}
+		if ok {
+			return pos, nil
+		}
+		log.WithContext(b.ctx).WithComponent("binlog").Warn("Checkpoint is purged on source", "pos", pos)
+	}
+
+	if pos, err = b.resyncAll(); err != nil {
+		return pos, err
+	}
+	return pos, b.savePos(pos)
+}
+
+func (b *Binlog) handle(e *replication.BinlogEvent) error {
+	switch ev := e.Event.(type) {
+	case *replication.RotateEvent:
+		b.pos = position{Name: string(ev.NextLogName), Pos: uint32(ev.Position)}
+	case *replication.RowsEvent:
+		return b.handleRows(e.Header.EventType, ev)
+	case *replication.XIDEvent:
+		b.pos.Pos = e.Header.LogPos
+		return b.savePos(b.pos)
+	case *replication.QueryEvent:
+		stmt := string(ev.Query)
+		if stmt == "BEGIN" {
+			return nil
+		}
+		b.pos.Pos = e.Header.LogPos
+		if ddlRe.MatchString(stmt) {
+			if err := b.handleDDL(stmt); err != nil {
+				return err
+			}
+		}
+		return b.savePos(b.pos)
+	}
+	return nil
+}
+
+func (b *Binlog) handleRows(typ replication.EventType, e *replication.RowsEvent) error {
+	name := string(e.Table.Table)
+	if !b.include(string(e.Table.Schema), name) {
+		return nil
+	}
+	if typ == replication.PARTIAL_UPDATE_ROWS_EVENT {
+		log.WithContext(b.ctx).WithComponent("binlog").Warn("Partial json update is not supported, resync table, set binlog_row_value_options=''", "table", name)
+		return b.resyncTable(name)
+	}
+	t, err := b.table(name)
+	if err != nil || t == nil {
+		return err
+	}
+	if int(e.ColumnCount) != len(t.columns) {
+		// the cached columns are older than the event, read them again
+		delete(b.tables, name)
+		if t, err = b.table(name); err != nil || t == nil {
+			return err
+		}
+	}
+	if int(e.ColumnCount) != len(t.columns) {
+		// the event is older than the current schema(replaying after ddl), the copy is newer anyway
+		log.WithContext(b.ctx).WithError(errSchemaChanged).WithComponent("binlog").Warn("Resync table", "table", name)
+		return b.resyncTable(name)
+	}
+	if len(t.primary) == 0 {
+		return nil
+	}
+
+	rows := make([][]interface{}, 0, len(e.Rows))
+	for _, item := range e.Rows {
+		row := make([]interface{}, len(item))
+		for i, v := range item {
+			row[i] = columnValue(t.columns[i], v, t.loc)
+		}
+		rows = append(rows, row)
+	}
+	return b.apply(t, rowsAction(typ), rows)
+}
+
+// apply writes rows of one event, update rows are pairs of before and after images
+func (b *Binlog) apply(t *tableMeta, action string, rows [][]interface{}) error {
+	key := query.TableKey(b.ops.database, t.name)
+	pipe := b.ops.redis.Pipeline()
+	for i := 0; i < len(rows); i++ {
+		row := rows[i]
+		switch action {
+		case actionDelete:
+			pipe.HDel(b.ctx, key, t.field(row))
+			continue
+		case actionUpdate:
+			i++
+			after := rows[i]
+			// primary key changed
+			if before := t.field(row); before != t.field(after) {
+				pipe.HDel(b.ctx, key, before)
+			}
+			row = after
+		}
+		value, err := t.encode(row)
+		if err != nil {
+			return err
+		}
+		pipe.HSet(b.ctx, key, t.field(row), value)
+	}
+	_, err := pipe.Exec(b.ctx)
+	return err
+}
+
+// handleDDL resyncs tables mentioned by the statement, which also covers truncate/drop/rename,
+// the statement may qualify another schema so that a same named table is resynced needlessly at worst
+func (b *Binlog) handleDDL(stmt string) error {
+	re, err := regexp.Compile(`\b` + regexp.QuoteMeta(b.ops.tablePrefix) + `\w+`)
+	if err != nil {
+		return err
+	}
+	seen := make(map[string]bool)
+	for _, name := range re.FindAllString(stmt, -1) {
+		if seen[name] || !b.include(b.cfg.DBName, name) {
+			continue
+		}
+		seen[name] = true
+		log.WithContext(b.ctx).WithComponent("binlog").Info("Table changed by ddl, resync", "table", name)
+		if err = b.resyncTable(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *Binlog) savePos(pos position) error {
+	v, err := json.Marshal(pos)
+	if err != nil {
+		return err
+	}
+	return b.ops.redis.Set(b.ctx, b.ops.posKey, v, 0).Err()
+}
+
+func (b *Binlog) include(schema, table string) bool {
+	if schema != b.cfg.DBName || !strings.HasPrefix(table, b.ops.tablePrefix) {
+		return false
+	}
+	for _, item := range b.ops.excludes {
+		if item == table {
+			return false
+		}
+	}
+	return true
+}
+
+// posErr marks the error of a purged or invalid checkpoint
+func posErr(err error) error {
+	var e *gomysql.MyError
+	if errors.As(err, &e) && e.Code == gomysql.ER_MASTER_FATAL_ERROR_READING_BINLOG {
+		return errors.Wrap(ErrPosLost, e.Error())
+	}
+	return err
+}
+
+func rowsAction(typ replication.EventType) string {
+	switch typ {
+	case replication.UPDATE_ROWS_EVENTv0, replication.UPDATE_ROWS_EVENTv1, replication.UPDATE_ROWS_EVENTv2:
+		return actionUpdate
+	case replication.DELETE_ROWS_EVENTv0, replication.DELETE_ROWS_EVENTv1, replication.DELETE_ROWS_EVENTv2:
+		return actionDelete
+	}
+	return actionInsert
+}
+
+func parseUint32(b []byte) (uint32, error) {
+	v, err := strconv.ParseUint(string(b), 10, 32)
+	return uint32(v), err
+}
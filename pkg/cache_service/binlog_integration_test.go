@@ -0,0 +1,301 @@
+//go:build integration
+
+package cache_service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	m "github.com/go-sql-driver/mysql"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/ppxb/oreo-admin-go/pkg/lock"
+	"github.com/ppxb/oreo-admin-go/pkg/query"
+)
+
+// the source needs log_bin=ON, binlog_format=ROW and a user allowed to replicate, e.g.
+// docker run -e MYSQL_ROOT_PASSWORD=root -p 3306:3306 mysql:8.4
+// go test -tags integration ./pkg/cache_service/
+const (
+	testDatabase = "cache_service_test"
+	testTable    = "tb_item"
+	testPosKey   = "binlog_pos_test"
+)
+
+type testEnv struct {
+	uri string
+	db  *sql.DB
+	rd  *redis.Client
+	mr  *miniredis.Miniredis
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	dsn := os.Getenv("CACHE_SERVICE_MYSQL_DSN")
+	if dsn == "" {
+		dsn = "root:root@tcp(127.0.0.1:3306)/"
+	}
+	cfg, err := m.ParseDSN(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.DBName = ""
+	cfg.MultiStatements = true
+	root, err := sql.Open("mysql", cfg.FormatDSN())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer root.Close()
+	if _, err = root.Exec("DROP DATABASE IF EXISTS " + testDatabase + "; CREATE DATABASE " + testDatabase); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg.DBName = testDatabase
+	cfg.ParseTime = true
+	db, err := sql.Open("mysql", cfg.FormatDSN())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if _, err = db.Exec(`CREATE TABLE ` + testTable + ` (
+		id INT UNSIGNED NOT NULL PRIMARY KEY,
+		name VARCHAR(64) NOT NULL,
+		price DECIMAL(10, 2) NOT NULL,
+		kind ENUM('a', 'b') NOT NULL,
+		extra JSON NULL,
+		created_at DATETIME(3) NOT NULL
+	)`); err != nil {
+		t.Fatal(err)
+	}
+
+	mr := miniredis.RunT(t)
+	rd := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rd.Close() })
+	return &testEnv{uri: cfg.FormatDSN(), db: db, rd: rd, mr: mr}
+}
+
+// run starts the sync and waits for the checkpoint, the returned func stops it
+func (e *testEnv) run(t *testing.T) func() {
+	stop := e.start(t)
+	eventually(t, "checkpoint", func() bool {
+		return e.mr.Exists(testPosKey)
+	})
+	return stop
+}
+
+// start runs a sync without waiting, options override the defaults
+func (e *testEnv) start(t *testing.T, options ...func(*Options)) func() {
+	ctx, cancel := context.WithCancel(context.Background())
+	b, err := New(append([]func(*Options){
+		WithCtx(ctx),
+		WithRedis(e.rd),
+		WithUri(e.uri),
+		WithPosKey(testPosKey),
+		WithTablePrefix("tb_"),
+		WithServerId(4001),
+		WithHeartbeat(time.Second),
+		WithRetryInterval(100 * time.Millisecond),
+	}, options...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- b.Run()
+	}()
+	return func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func (e *testEnv) exec(t *testing.T, stmt string, args ...interface{}) {
+	if _, err := e.db.Exec(stmt, args...); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (e *testEnv) row(id string) map[string]*string {
+	s := e.mr.HGet(query.TableKey("query_redis", testTable), id)
+	if s == "" {
+		return nil
+	}
+	var r map[string]*string
+	_ = json.Unmarshal([]byte(s), &r)
+	return r
+}
+
+func eventually(t *testing.T, name string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for %s", name)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func field(r map[string]*string, name string) string {
+	if r == nil || r[name] == nil {
+		return ""
+	}
+	return *r[name]
+}
+
+func TestMirrorInsertUpdateDelete(t *testing.T) {
+	e := newTestEnv(t)
+	e.exec(t, "INSERT INTO "+testTable+" VALUES (1, 'before', 1.50, 'a', NULL, '2026-01-02 03:04:05.678')")
+	stop := e.run(t)
+	defer stop()
+
+	// the first start copies existing rows
+	if got := field(e.row("1"), "name"); got != "before" {
+		t.Fatalf("resynced name = %q", got)
+	}
+
+	e.exec(t, "INSERT INTO "+testTable+` VALUES (2, 'new', 2.00, 'b', '{"z": 1, "a": [true]}', '2026-01-02 03:04:05.678')`)
+	eventually(t, "insert", func() bool {
+		return e.row("2") != nil
+	})
+	r := e.row("2")
+	for name, want := range map[string]string{
+		"name":       "new",
+		"price":      "2.00",
+		"kind":       "b",
+		"extra":      `{"a":[true],"z":1}`,
+		"created_at": field(e.row("1"), "created_at"),
+	} {
+		if got := field(r, name); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+
+	e.exec(t, "UPDATE "+testTable+" SET name = 'after' WHERE id = 1")
+	eventually(t, "update", func() bool {
+		return field(e.row("1"), "name") == "after"
+	})
+
+	e.exec(t, "UPDATE "+testTable+" SET id = 3 WHERE id = 2")
+	eventually(t, "primary key update", func() bool {
+		return e.row("2") == nil && e.row("3") != nil
+	})
+
+	e.exec(t, "DELETE FROM "+testTable+" WHERE id = 1")
+	eventually(t, "delete", func() bool {
+		return e.row("1") == nil
+	})
+}
+
+func TestResumeFromCheckpoint(t *testing.T) {
+	e := newTestEnv(t)
+	stop := e.run(t)
+	e.exec(t, "INSERT INTO "+testTable+" VALUES (1, 'first', 1, 'a', NULL, NOW())")
+	eventually(t, "insert", func() bool {
+		return e.row("1") != nil
+	})
+	stop()
+
+	// a full resync replaces the hash and drops the marker
+	key := query.TableKey("query_redis", testTable)
+	e.mr.HSet(key, "marker", "{}")
+	e.exec(t, "INSERT INTO "+testTable+" VALUES (2, 'offline', 1, 'a', NULL, NOW())")
+
+	stop = e.run(t)
+	defer stop()
+	eventually(t, "change made while stopped", func() bool {
+		return e.row("2") != nil
+	})
+	if e.mr.HGet(key, "marker") == "" {
+		t.Fatal("restart resynced the table instead of resuming from the checkpoint")
+	}
+}
+
+func TestPosLostResyncs(t *testing.T) {
+	e := newTestEnv(t)
+	stop := e.run(t)
+	stop()
+
+	var pos position
+	if err := json.Unmarshal([]byte(mustGet(t, e.mr, testPosKey)), &pos); err != nil {
+		t.Fatal(err)
+	}
+	// the middle of the format description event, the source refuses to stream from it
+	pos.Pos = 5
+	b, _ := json.Marshal(pos)
+	if err := e.mr.Set(testPosKey, string(b)); err != nil {
+		t.Fatal(err)
+	}
+	key := query.TableKey("query_redis", testTable)
+	e.mr.HSet(key, "marker", "{}")
+	e.exec(t, "INSERT INTO "+testTable+" VALUES (1, 'missed', 1, 'a', NULL, NOW())")
+
+	stop = e.run(t)
+	defer stop()
+	eventually(t, "full resync", func() bool {
+		return e.mr.HGet(key, "marker") == "" && e.row("1") != nil
+	})
+	eventually(t, "new checkpoint", func() bool {
+		s, _ := e.mr.Get(testPosKey)
+		return s != string(b)
+	})
+}
+
+func TestLeaderStreams(t *testing.T) {
+	e := newTestEnv(t)
+	locker, err := lock.NewRedis(lock.WithRedis(e.rd), lock.WithTtl(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stopLeader := e.start(t, WithLocker(locker), WithServerId(4001))
+	eventually(t, "checkpoint", func() bool {
+		return e.mr.Exists(testPosKey)
+	})
+	stopFollower := e.start(t, WithLocker(locker), WithServerId(4002))
+	defer stopFollower()
+
+	e.exec(t, "INSERT INTO "+testTable+" VALUES (1, 'leader', 1, 'a', NULL, NOW())")
+	eventually(t, "insert by the leader", func() bool {
+		return e.row("1") != nil
+	})
+	if n := e.dumps(t); n != 1 {
+		t.Fatalf("binlog streams = %d, want 1", n)
+	}
+
+	// the follower takes over and resumes from the checkpoint of the leader
+	stopLeader()
+	e.exec(t, "INSERT INTO "+testTable+" VALUES (2, 'follower', 1, 'a', NULL, NOW())")
+	eventually(t, "insert by the follower", func() bool {
+		return e.row("2") != nil
+	})
+	if n := e.dumps(t); n != 1 {
+		t.Fatalf("binlog streams = %d, want 1", n)
+	}
+}
+
+// dumps counts the binlog streams of the test server ids on the source
+func (e *testEnv) dumps(t *testing.T) int {
+	var n int
+	eventually(t, "closed streams", func() bool {
+		err := e.db.QueryRow("SELECT COUNT(*) FROM information_schema.PROCESSLIST WHERE COMMAND LIKE 'Binlog Dump%'").Scan(&n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return n <= 1
+	})
+	return n
+}
+
+func mustGet(t *testing.T, mr *miniredis.Miniredis, key string) string {
+	s, err := mr.Get(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
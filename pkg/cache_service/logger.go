@@ -0,0 +1,54 @@
+package cache_service
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/ppxb/oreo-admin-go/pkg/log"
+)
+
+// handler adapts go-mysql's logs to pkg/log
+type handler struct {
+	ctx   context.Context
+	attrs []interface{}
+}
+
+func newLogger(ctx context.Context) *slog.Logger {
+	return slog.New(handler{ctx: ctx})
+}
+
+func (h handler) Enabled(context.Context, slog.Level) bool {
+	return true
+}
+
+func (h handler) Handle(_ context.Context, r slog.Record) error {
+	args := append([]interface{}{}, h.attrs...)
+	r.Attrs(func(a slog.Attr) bool {
+		args = append(args, a.Key, a.Value.Any())
+		return true
+	})
+	l := log.WithContext(h.ctx).WithComponent("go-mysql")
+	switch {
+	case r.Level >= slog.LevelError:
+		l.Error(r.Message, args...)
+	case r.Level >= slog.LevelWarn:
+		l.Warn(r.Message, args...)
+	case r.Level >= slog.LevelInfo:
+		l.Info(r.Message, args...)
+	default:
+		l.Debug(r.Message, args...)
+	}
+	return nil
+}
+
+func (h handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	args := append([]interface{}{}, h.attrs...)
+	for _, a := range attrs {
+		args = append(args, a.Key, a.Value.Any())
+	}
+	return handler{ctx: h.ctx, attrs: args}
+}
+
+func (h handler) WithGroup(string) slog.Handler {
+	return h
+}
@@ -0,0 +1,130 @@
+package cache_service
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/ppxb/oreo-admin-go/pkg/lock"
+	"github.com/ppxb/oreo-admin-go/pkg/utils"
+)
+
+type Options struct {
+	ctx           context.Context
+	redis         redis.UniversalClient
+	locker        lock.Locker
+	uri           string
+	database      string
+	posKey        string
+	tablePrefix   string
+	excludes      []string
+	serverId      uint32
+	heartbeat     time.Duration
+	retryInterval time.Duration
+	batchSize     int
+}
+
+func WithCtx(ctx context.Context) func(*Options) {
+	return func(options *Options) {
+		if !utils.InterfaceIsNil(ctx) {
+			getOptionsOrSetDefault(options).ctx = ctx
+		}
+	}
+}
+
+func WithRedis(rd redis.UniversalClient) func(*Options) {
+	return func(options *Options) {
+		if rd != nil {
+			getOptionsOrSetDefault(options).redis = rd
+		}
+	}
+}
+
+// WithLocker elects the node streaming the binlog, without it every node streams and writes the checkpoint
+func WithLocker(l lock.Locker) func(*Options) {
+	return func(options *Options) {
+		if !utils.InterfaceIsNil(l) {
+			getOptionsOrSetDefault(options).locker = l
+		}
+	}
+}
+
+// WithUri is the go-sql-driver dsn of the source database, the user needs REPLICATION SLAVE and REPLICATION CLIENT
+func WithUri(s string) func(*Options) {
+	return func(options *Options) {
+		getOptionsOrSetDefault(options).uri = s
+	}
+}
+
+// WithDatabase must be the same as the database of query.Redis
+func WithDatabase(s string) func(*Options) {
+	return func(options *Options) {
+		if s != "" {
+			getOptionsOrSetDefault(options).database = s
+		}
+	}
+}
+
+// WithPosKey is the redis key of the binlog checkpoint
+func WithPosKey(s string) func(*Options) {
+	return func(options *Options) {
+		if s != "" {
+			getOptionsOrSetDefault(options).posKey = s
+		}
+	}
+}
+
+// WithTablePrefix only syncs tables starting with prefix
+func WithTablePrefix(s string) func(*Options) {
+	return func(options *Options) {
+		getOptionsOrSetDefault(options).tablePrefix = s
+	}
+}
+
+func WithExcludeTables(tables ...string) func(*Options) {
+	return func(options *Options) {
+		getOptionsOrSetDefault(options).excludes = append(getOptionsOrSetDefault(options).excludes, tables...)
+	}
+}
+
+// WithServerId must be unique among all replicas of the source
+func WithServerId(id uint32) func(*Options) {
+	return func(options *Options) {
+		if id > 0 {
+			getOptionsOrSetDefault(options).serverId = id
+		}
+	}
+}
+
+// WithHeartbeat is the source heartbeat period, the stream reconnects if nothing arrives within 2 periods
+func WithHeartbeat(d time.Duration) func(*Options) {
+	return func(options *Options) {
+		if d > 0 {
+			getOptionsOrSetDefault(options).heartbeat = d
+		}
+	}
+}
+
+func WithRetryInterval(d time.Duration) func(*Options) {
+	return func(options *Options) {
+		if d > 0 {
+			getOptionsOrSetDefault(options).retryInterval = d
+		}
+	}
+}
+
+func getOptionsOrSetDefault(options *Options) *Options {
+	if options == nil {
+		return &Options{
+			ctx:           context.Background(),
+			database:      "query_redis",
+			posKey:        "mysql_binlog_pos",
+			serverId:      1001,
+			heartbeat:     30 * time.Second,
+			retryInterval: 5 * time.Second,
+			batchSize:     500,
+		}
+	}
+	return options
+}
@@ -0,0 +1,346 @@
+package cache_service
+
+import (
+	"database/sql"
+	"encoding/json"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/ppxb/oreo-admin-go/pkg/log"
+	"github.com/ppxb/oreo-admin-go/pkg/query"
+)
+
+const resyncSuffix = ":resync"
+
+var enumRe = regexp.MustCompile(`'((?:[^']|'')*)'`)
+
+type column struct {
+	name     string
+	dataType string
+	unsigned bool
+	// values of enum/set
+	values []string
+}
+
+type tableMeta struct {
+	name    string
+	columns []column
+	primary []int
+	loc     *time.Location
+}
+
+// field is the hash field of a row, see query.RowField
+func (t *tableMeta) field(row []interface{}) string {
+	values := make([]interface{}, 0, len(t.primary))
+	for _, i := range t.primary {
+		values = append(values, row[i])
+	}
+	return query.RowField(values...)
+}
+
+// encode renders the row JSON read by query.Redis
+func (t *tableMeta) encode(row []interface{}) (string, error) {
+	r := make(map[string]*string, len(t.columns))
+	for i, col := range t.columns {
+		if s, ok := query.FormatValue(row[i]); ok {
+			r[col.name] = &s
+		} else {
+			r[col.name] = nil
+		}
+	}
+	b, err := json.Marshal(r)
+	return string(b), err
+}
+
+// table loads columns from information_schema, nil means the table does not exist
+func (b *Binlog) table(name string) (*tableMeta, error) {
+	if t, ok := b.tables[name]; ok {
+		return t, nil
+	}
+	rows, err := b.db.QueryContext(
+		b.ctx,
+		"SELECT COLUMN_NAME, DATA_TYPE, COLUMN_TYPE, COLUMN_KEY FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ? ORDER BY ORDINAL_POSITION",
+		b.cfg.DBName, name,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	t := &tableMeta{name: name, loc: b.cfg.Loc}
+	for rows.Next() {
+		var col column
+		var columnType, key string
+		if err = rows.Scan(&col.name, &col.dataType, &columnType, &key); err != nil {
+			return nil, err
+		}
+		col.dataType = strings.ToLower(col.dataType)
+		col.unsigned = strings.Contains(strings.ToLower(columnType), "unsigned")
+		if col.dataType == "enum" || col.dataType == "set" {
+			for _, item := range enumRe.FindAllStringSubmatch(columnType, -1) {
+				col.values = append(col.values, strings.ReplaceAll(item[1], "''", "'"))
+			}
+		}
+		if key == "PRI" {
+			t.primary = append(t.primary, len(t.columns))
+		}
+		t.columns = append(t.columns, col)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(t.columns) == 0 {
+		return nil, nil
+	}
+	b.tables[name] = t
+	return t, nil
+}
+
+// resyncAll copies every synced table and returns the position the stream continues from,
+// the position is read first so that changes during the copy are replayed(replaying is idempotent)
+func (b *Binlog) resyncAll() (position, error) {
+	pos, err := b.sourcePos()
+	if err != nil {
+		return pos, err
+	}
+	tables, err := b.listTables()
+	if err != nil {
+		return pos, err
+	}
+
+	start := time.Now()
+	synced := make(map[string]bool, len(tables))
+	for _, name := range tables {
+		if err = b.resyncTable(name); err != nil {
+			return pos, err
+		}
+		synced[query.TableKey(b.ops.database, name)] = true
+	}
+
+	// tables dropped while the checkpoint was lost
+	iter := b.ops.redis.Scan(b.ctx, 0, query.TableKey(b.ops.database, b.ops.tablePrefix)+"*", 100).Iterator()
+	for iter.Next(b.ctx) {
+		if key := iter.Val(); !synced[key] && !strings.HasSuffix(key, resyncSuffix) {
+			if err = b.ops.redis.Del(b.ctx, key).Err(); err != nil {
+				return pos, err
+			}
+		}
+	}
+	if err = iter.Err(); err != nil {
+		return pos, err
+	}
+
+	log.WithContext(b.ctx).WithComponent("binlog").Info("Full resync finished", "tables", len(tables), "elapsed", time.Since(start).Round(time.Millisecond), "pos", pos)
+	return pos, nil
+}
+
+// resyncTable replaces the hash of the table atomically with a copy of the table
+func (b *Binlog) resyncTable(name string) error {
+	delete(b.tables, name)
+	key := query.TableKey(b.ops.database, name)
+	t, err := b.table(name)
+	if err != nil {
+		return err
+	}
+	if t == nil {
+		return b.ops.redis.Del(b.ctx, key).Err()
+	}
+	if len(t.primary) == 0 {
+		log.WithContext(b.ctx).WithComponent("binlog").Warn("Table has no primary key, skip", "table", name)
+		return nil
+	}
+
+	rows, err := b.db.QueryContext(b.ctx, "SELECT * FROM `"+strings.ReplaceAll(name, "`", "``")+"`")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	if len(columns) != len(t.columns) {
+		// the table changed after its columns were read, the next ddl event resyncs it again
+		return errors.Wrapf(errSchemaChanged, "resync %s", name)
+	}
+
+	tmp := key + resyncSuffix
+	if err = b.ops.redis.Del(b.ctx, tmp).Err(); err != nil {
+		return err
+	}
+	total := 0
+	batch := make([]interface{}, 0, 2*b.ops.batchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		err := b.ops.redis.HSet(b.ctx, tmp, batch...).Err()
+		batch = batch[:0]
+		return err
+	}
+	for rows.Next() {
+		row := make([]interface{}, len(columns))
+		dest := make([]interface{}, len(columns))
+		for i := range row {
+			dest[i] = &row[i]
+		}
+		if err = rows.Scan(dest...); err != nil {
+			return err
+		}
+		for i, col := range t.columns {
+			row[i] = normalize(col, row[i])
+		}
+		value, err := t.encode(row)
+		if err != nil {
+			return err
+		}
+		batch = append(batch, t.field(row), value)
+		total++
+		if len(batch) >= 2*b.ops.batchSize {
+			if err = flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	if err = flush(); err != nil {
+		return err
+	}
+
+	if total == 0 {
+		err = b.ops.redis.Del(b.ctx, key).Err()
+	} else {
+		err = b.ops.redis.Rename(b.ctx, tmp, key).Err()
+	}
+	if err != nil {
+		return err
+	}
+	log.WithContext(b.ctx).WithComponent("binlog").Debug("Table resynced", "table", name, "rows", total)
+	return nil
+}
+
+// normalize converts text protocol values to what columnValue returns for the binlog
+func normalize(col column, v interface{}) interface{} {
+	raw, ok := v.([]byte)
+	if !ok {
+		return v
+	}
+	switch col.dataType {
+	case "bit":
+		var n uint64
+		for _, item := range raw {
+			n = n<<8 | uint64(item)
+		}
+		return n
+	case "json":
+		return compactJson(raw)
+	}
+	return raw
+}
+
+func (b *Binlog) listTables() ([]string, error) {
+	rows, err := b.db.QueryContext(
+		b.ctx,
+		"SELECT TABLE_NAME FROM information_schema.TABLES WHERE TABLE_SCHEMA = ? AND TABLE_TYPE = 'BASE TABLE'",
+		b.cfg.DBName,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []string
+	for rows.Next() {
+		var name string
+		if err = rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		if b.include(b.cfg.DBName, name) {
+			list = append(list, name)
+		}
+	}
+	return list, rows.Err()
+}
+
+// sourcePos is the current binlog position of the source
+func (b *Binlog) sourcePos() (position, error) {
+	var pos position
+	// SHOW MASTER STATUS is removed since mysql 8.4
+	var err error
+	for _, stmt := range []string{"SHOW BINARY LOG STATUS", "SHOW MASTER STATUS"} {
+		var values [][]byte
+		if values, err = b.queryFirst(stmt); err != nil {
+			continue
+		}
+		if len(values) < 2 || len(values[0]) == 0 {
+			return pos, errors.New("binary log is not enabled")
+		}
+		pos.Name = string(values[0])
+		p, err := parseUint32(values[1])
+		pos.Pos = p
+		return pos, err
+	}
+	return pos, err
+}
+
+// posExists checks that the checkpoint was not purged
+func (b *Binlog) posExists(pos position) (bool, error) {
+	rows, err := b.db.QueryContext(b.ctx, "SHOW BINARY LOGS")
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return false, err
+	}
+	for rows.Next() {
+		values := make([]sql.RawBytes, len(columns))
+		dest := make([]interface{}, len(columns))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		if err = rows.Scan(dest...); err != nil {
+			return false, err
+		}
+		if string(values[0]) != pos.Name {
+			continue
+		}
+		size, err := parseUint32(values[1])
+		return err == nil && pos.Pos <= size, nil
+	}
+	return false, rows.Err()
+}
+
+func (b *Binlog) queryFirst(stmt string) ([][]byte, error) {
+	rows, err := b.db.QueryContext(b.ctx, stmt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	if !rows.Next() {
+		return nil, rows.Err()
+	}
+	values := make([]sql.RawBytes, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	if err = rows.Scan(dest...); err != nil {
+		return nil, err
+	}
+	res := make([][]byte, len(values))
+	for i, v := range values {
+		res[i] = append([]byte{}, v...)
+	}
+	return res, nil
+}
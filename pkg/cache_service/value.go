@@ -0,0 +1,94 @@
+package cache_service
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// columnValue converts a column decoded by go-mysql to what database/sql returns for it,
+// so that query.FormatValue is identical to the full resync
+func columnValue(col column, v interface{}, loc *time.Location) interface{} {
+	switch val := v.(type) {
+	case int8:
+		return integer(int64(val), uint64(uint8(val)), col)
+	case int16:
+		return integer(int64(val), uint64(uint16(val)), col)
+	case int32:
+		if col.dataType == "mediumint" {
+			return integer(int64(val), uint64(uint32(val))&0xffffff, col)
+		}
+		return integer(int64(val), uint64(uint32(val)), col)
+	case int64:
+		switch col.dataType {
+		case "enum":
+			// index 0 is the empty string of invalid values
+			if val <= 0 || int(val) > len(col.values) {
+				return ""
+			}
+			return col.values[val-1]
+		case "set":
+			var items []string
+			for i, item := range col.values {
+				if val&(1<<uint(i)) != 0 {
+					items = append(items, item)
+				}
+			}
+			return strings.Join(items, ",")
+		case "bit":
+			return uint64(val)
+		}
+		return integer(val, uint64(val), col)
+	case int:
+		// year
+		return int64(val)
+	case time.Time:
+		if col.dataType == "timestamp" {
+			return val
+		}
+		// datetime is decoded in UTC, it is a wall clock of the connection location
+		return time.Date(val.Year(), val.Month(), val.Day(), val.Hour(), val.Minute(), val.Second(), val.Nanosecond(), loc)
+	case string:
+		switch col.dataType {
+		case "date", "datetime", "timestamp":
+			// zero dates are strings
+			if t, err := time.ParseInLocation("2006-01-02", val, loc); err == nil {
+				return t
+			}
+			return time.Time{}
+		case "json":
+			return compactJson([]byte(val))
+		}
+	case []byte:
+		if col.dataType == "json" {
+			return compactJson(val)
+		}
+	}
+	return v
+}
+
+func integer(signed int64, unsigned uint64, col column) interface{} {
+	if col.unsigned {
+		return unsigned
+	}
+	return signed
+}
+
+// compactJson renders a json document with sorted keys, mysql returns keys in its storage order
+// but go-mysql decodes objects into maps
+func compactJson(data []byte) interface{} {
+	var v interface{}
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	if d.Decode(&v) != nil {
+		return data
+	}
+	var buf bytes.Buffer
+	e := json.NewEncoder(&buf)
+	e.SetEscapeHTML(false)
+	if e.Encode(v) != nil {
+		return data
+	}
+	return strings.TrimSuffix(buf.String(), "\n")
+}
@@ -0,0 +1,55 @@
+package cache_service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ppxb/oreo-admin-go/pkg/query"
+)
+
+func TestColumnValue(t *testing.T) {
+	loc := time.FixedZone("UTC+8", 8*3600)
+	tests := []struct {
+		name string
+		col  column
+		v    interface{}
+		want string
+	}{
+		{"signed tinyint", column{dataType: "tinyint"}, int8(-1), "-1"},
+		{"unsigned tinyint", column{dataType: "tinyint", unsigned: true}, int8(-1), "255"},
+		{"unsigned mediumint", column{dataType: "mediumint", unsigned: true}, int32(-1), "16777215"},
+		{"unsigned int", column{dataType: "int", unsigned: true}, int32(-1), "4294967295"},
+		{"unsigned bigint", column{dataType: "bigint", unsigned: true}, int64(-1), "18446744073709551615"},
+		{"enum", column{dataType: "enum", values: []string{"a", "b"}}, int64(2), "b"},
+		{"invalid enum", column{dataType: "enum", values: []string{"a"}}, int64(0), ""},
+		{"set", column{dataType: "set", values: []string{"a", "b", "c"}}, int64(5), "a,c"},
+		{"bit", column{dataType: "bit"}, int64(3), "3"},
+		{"year", column{dataType: "year"}, 2026, "2026"},
+		{"decimal", column{dataType: "decimal"}, "1.50", "1.50"},
+		{"datetime", column{dataType: "datetime"}, time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC), "2026-01-01T19:04:05.000000Z"},
+		{"timestamp", column{dataType: "timestamp"}, time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC), "2026-01-02T03:04:05.000000Z"},
+		{"date", column{dataType: "date"}, "2026-01-02", "2026-01-01T16:00:00.000000Z"},
+		{"zero datetime", column{dataType: "datetime"}, "0000-00-00 00:00:00", time.Time{}.UTC().Format(query.TimeFormat)},
+		{"json", column{dataType: "json"}, `{"z": 1, "a": "<b>"}`, `{"a":"<b>","z":1}`},
+		{"blob", column{dataType: "blob"}, []byte("raw"), "raw"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _ := query.FormatValue(columnValue(tt.col, tt.v, loc))
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeMatchesColumnValue(t *testing.T) {
+	col := column{dataType: "json"}
+	text := []byte(`{"z": 1, "a": [1.0, "x"]}`)
+	binlog := `{"a":[1.0,"x"],"z":1}`
+	a, _ := query.FormatValue(normalize(col, text))
+	b, _ := query.FormatValue(columnValue(col, binlog, time.UTC))
+	if a != b {
+		t.Errorf("resync %q != binlog %q", a, b)
+	}
+}
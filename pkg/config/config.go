@@ -34,26 +34,26 @@ func (c ConfBox) buildPath(filename string) string {
 func (c ConfBox) readFromFileSystem(path string) []byte {
 	data, err := os.ReadFile(path)
 	if err != nil {
-		log.WithContext(c.Ctx).WithError(err).Warn("[CONF BOX] Read file %s from file system failed, will try embed", path)
+		log.WithContext(c.Ctx).WithError(err).WithComponent("config").Warn("Read file from file system failed, will try embed", "path", path)
 		return nil
 	}
 
-	log.WithContext(c.Ctx).Info("[CONF BOX] Read file %s from file system success", path)
+	log.WithContext(c.Ctx).WithComponent("config").Info("Read file from file system success", "path", path)
 	return data
 }
 
 func (c ConfBox) readFromEmbed(path string) []byte {
 	data, err := c.Fs.ReadFile(path)
 	if err != nil {
-		log.WithContext(c.Ctx).WithError(err).Warn("[CONF BOX] Read file %s from embed failed", path)
+		log.WithContext(c.Ctx).WithError(err).WithComponent("config").Warn("Read file from embed failed", "path", path)
 		return nil
 	}
 
 	if len(data) == 0 {
-		log.WithContext(c.Ctx).Warn("[CONF BOX] File %s is empty in embed", path)
+		log.WithContext(c.Ctx).WithComponent("config").Warn("File is empty in embed", "path", path)
 		return nil
 	}
 
-	log.WithContext(c.Ctx).Info("[CONF BOX] Read file %s from embed success", path)
+	log.WithContext(c.Ctx).WithComponent("config").Info("Read file from embed success", "path", path)
 	return data
 }
@@ -7,4 +7,7 @@ const (
 	LogErrorKey         = "Err"
 	LogSkipHelperCtxKey = "LogSkipHelper"
 	LogHiddenSqlCtxKey  = "LogHiddenSql"
+	LogComponentKey     = "component"
+	LogBadKey           = "!BADKEY"
+	LogDroppedKey       = "dropped"
 )
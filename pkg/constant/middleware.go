@@ -4,4 +4,6 @@ const (
 	MiddlewareRequestIdCtxKey = "RequestId"
 	MiddlewareTraceIdCtxKey   = "TraceId"
 	MiddlewareSpanIdCtxKey    = "SpanId"
+	MiddlewareClientIpCtxKey  = "ClientIp"
+	MiddlewareLocationCtxKey  = "Location"
 )
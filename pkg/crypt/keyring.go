@@ -0,0 +1,223 @@
+package crypt
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/ppxb/oreo-admin-go/pkg/utils"
+)
+
+// Algorithm is the name of the scheme in WebCrypto, clients encrypt with RSA-OAEP and SHA-256
+const Algorithm = "RSA-OAEP-256"
+
+var (
+	ErrUnknownKey = errors.New("unknown key id")
+	ErrInvalid    = errors.New("invalid encrypted payload")
+	ErrExpired    = errors.New("encrypted payload is expired")
+	ErrReplayed   = errors.New("encrypted payload is replayed")
+)
+
+// Key is an rsa key pair of the keyring
+type Key struct {
+	Id          string
+	Fingerprint string
+	private     *rsa.PrivateKey
+}
+
+// PublicPem encodes the public key as PKIX, which WebCrypto imports as spki
+func (k *Key) PublicPem() string {
+	der, _ := x509.MarshalPKIXPublicKey(&k.private.PublicKey)
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
+
+// Payload is the plaintext of an encrypted field, Timestamp is in milliseconds(Date.now() of js)
+type Payload struct {
+	Value     string `json:"value"`
+	Nonce     string `json:"nonce"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// Keyring decrypts payloads with the current key or a retired one selected by key id,
+// a nonce is accepted once within the skew so that captured payloads can not be replayed
+type Keyring struct {
+	ops     Options
+	current *Key
+	keys    map[string]*Key
+	lock    sync.RWMutex
+	nonces  nonceStore
+}
+
+// NewKeyring parses the current key pair(PKCS#1 or PKCS#8 pem), publicKey is optional
+// and must match privateKey if set
+func NewKeyring(privateKey, publicKey []byte, options ...func(*Options)) (*Keyring, error) {
+	ops := getOptionsOrSetDefault(nil)
+	for _, f := range options {
+		f(ops)
+	}
+	current, err := newKey(ops.keyId, privateKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse current key")
+	}
+	if len(publicKey) > 0 {
+		pub, err := parsePublicKey(publicKey)
+		if err != nil {
+			return nil, errors.Wrap(err, "parse public key")
+		}
+		if !pub.Equal(&current.private.PublicKey) {
+			return nil, errors.New("public key does not match private key")
+		}
+	}
+	k := &Keyring{
+		ops:     *ops,
+		current: current,
+		keys:    map[string]*Key{current.Id: current},
+	}
+	for _, item := range ops.retired {
+		key, err := newKey(item.Id, item.PrivateKey)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parse retired key %s", item.Id)
+		}
+		if _, ok := k.keys[key.Id]; ok {
+			return nil, errors.Errorf("duplicate key id %s", key.Id)
+		}
+		k.keys[key.Id] = key
+	}
+	if ops.redis != nil {
+		k.nonces = &redisNonces{redis: ops.redis, prefix: ops.prefix}
+	} else {
+		k.nonces = newMemoryNonces()
+	}
+	return k, nil
+}
+
+// UseRedis shares used nonces with other replicas from now on, e.g. redis connected after a degraded start,
+// nonces used before are still checked in memory until they expire
+func (k *Keyring) UseRedis(rd redis.UniversalClient) {
+	if utils.InterfaceIsNil(rd) {
+		return
+	}
+	k.lock.Lock()
+	defer k.lock.Unlock()
+	if _, ok := k.nonces.(*memoryNonces); ok {
+		k.nonces = chainNonces{k.nonces, &redisNonces{redis: rd, prefix: k.ops.prefix}}
+	}
+}
+
+// Current is the key clients should encrypt with
+func (k *Keyring) Current() *Key {
+	return k.current
+}
+
+func (k *Keyring) MaxSkew() time.Duration {
+	return k.ops.maxSkew
+}
+
+// Decrypt opens a base64 ciphertext of a json Payload and returns its value
+func (k *Keyring) Decrypt(ctx context.Context, keyId, ciphertext string) (string, error) {
+	key, ok := k.keys[keyId]
+	if !ok {
+		return "", errors.Wrap(ErrUnknownKey, keyId)
+	}
+	data, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return "", errors.Wrap(ErrInvalid, err.Error())
+	}
+	plain, err := rsa.DecryptOAEP(sha256.New(), rand.Reader, key.private, data, nil)
+	if err != nil {
+		return "", errors.Wrap(ErrInvalid, err.Error())
+	}
+	var p Payload
+	if err = json.Unmarshal(plain, &p); err != nil {
+		return "", errors.Wrap(ErrInvalid, err.Error())
+	}
+	// short nonces collide, long ones waste the store
+	if len(p.Nonce) < 16 || len(p.Nonce) > 64 {
+		return "", errors.Wrap(ErrInvalid, "nonce must have 16 to 64 characters")
+	}
+	skew := time.Since(time.UnixMilli(p.Timestamp))
+	if skew > k.ops.maxSkew || skew < -k.ops.maxSkew {
+		return "", errors.Wrapf(ErrExpired, "skew %s", skew)
+	}
+	k.lock.RLock()
+	nonces := k.nonces
+	k.lock.RUnlock()
+	ok, err = nonces.use(ctx, p.Nonce, 2*k.ops.maxSkew)
+	if err != nil {
+		return "", errors.Wrap(err, "use nonce")
+	}
+	if !ok {
+		return "", errors.Wrap(ErrReplayed, p.Nonce)
+	}
+	return p.Value, nil
+}
+
+func newKey(id string, privateKey []byte) (*Key, error) {
+	private, err := parsePrivateKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalPKIXPublicKey(&private.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(der)
+	fingerprint := hex.EncodeToString(sum[:])
+	if id == "" {
+		id = fingerprint[:16]
+	}
+	return &Key{
+		Id:          id,
+		Fingerprint: fingerprint,
+		private:     private,
+	}, nil
+}
+
+func parsePrivateKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no pem block found")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	private, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.Errorf("%T is not an rsa key", key)
+	}
+	return private, nil
+}
+
+func parsePublicKey(data []byte) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no pem block found")
+	}
+	if key, err := x509.ParsePKCS1PublicKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	public, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.Errorf("%T is not an rsa key", key)
+	}
+	return public, nil
+}
@@ -0,0 +1,70 @@
+package crypt
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// nonceStore remembers used nonces until ttl
+type nonceStore interface {
+	// use returns false if nonce was used before
+	use(ctx context.Context, nonce string, ttl time.Duration) (bool, error)
+}
+
+type redisNonces struct {
+	redis  redis.UniversalClient
+	prefix string
+}
+
+func (r *redisNonces) use(ctx context.Context, nonce string, ttl time.Duration) (bool, error) {
+	return r.redis.SetNX(ctx, r.prefix+":"+nonce, 1, ttl).Result()
+}
+
+// chainNonces rejects a nonce used in any store
+type chainNonces []nonceStore
+
+func (c chainNonces) use(ctx context.Context, nonce string, ttl time.Duration) (bool, error) {
+	for _, s := range c {
+		ok, err := s.use(ctx, nonce, ttl)
+		if !ok || err != nil {
+			return ok, err
+		}
+	}
+	return true, nil
+}
+
+// memoryNonces only guards a single replica
+type memoryNonces struct {
+	lock     sync.Mutex
+	expireAt map[string]time.Time
+	sweepAt  time.Time
+}
+
+func newMemoryNonces() *memoryNonces {
+	return &memoryNonces{
+		expireAt: make(map[string]time.Time),
+	}
+}
+
+func (m *memoryNonces) use(_ context.Context, nonce string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	// drop expired nonces at most once per ttl
+	if now.After(m.sweepAt) {
+		for k, t := range m.expireAt {
+			if now.After(t) {
+				delete(m.expireAt, k)
+			}
+		}
+		m.sweepAt = now.Add(ttl)
+	}
+	if t, ok := m.expireAt[nonce]; ok && now.Before(t) {
+		return false, nil
+	}
+	m.expireAt[nonce] = now.Add(ttl)
+	return true, nil
+}
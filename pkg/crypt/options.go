@@ -0,0 +1,75 @@
+package crypt
+
+import (
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/ppxb/oreo-admin-go/pkg/utils"
+)
+
+type Options struct {
+	redis   redis.UniversalClient
+	prefix  string
+	maxSkew time.Duration
+	keyId   string
+	retired []RetiredKey
+}
+
+// RetiredKey still decrypts payloads of clients holding an old public key, Id defaults to its fingerprint
+type RetiredKey struct {
+	Id         string
+	PrivateKey []byte
+}
+
+// WithRedis shares used nonces between replicas, they are kept in memory without redis
+func WithRedis(rd redis.UniversalClient) func(*Options) {
+	return func(options *Options) {
+		if !utils.InterfaceIsNil(rd) {
+			getOptionsOrSetDefault(options).redis = rd
+		}
+	}
+}
+
+func WithPrefix(s string) func(*Options) {
+	return func(options *Options) {
+		if s != "" {
+			getOptionsOrSetDefault(options).prefix = s
+		}
+	}
+}
+
+// WithMaxSkew is how far the timestamp of a payload may differ from now, nonces are kept twice as long
+func WithMaxSkew(d time.Duration) func(*Options) {
+	return func(options *Options) {
+		if d > 0 {
+			getOptionsOrSetDefault(options).maxSkew = d
+		}
+	}
+}
+
+// WithKeyId names the current key, it defaults to the fingerprint of the public key
+func WithKeyId(s string) func(*Options) {
+	return func(options *Options) {
+		if s != "" {
+			getOptionsOrSetDefault(options).keyId = s
+		}
+	}
+}
+
+func WithRetiredKeys(keys ...RetiredKey) func(*Options) {
+	return func(options *Options) {
+		o := getOptionsOrSetDefault(options)
+		o.retired = append(o.retired, keys...)
+	}
+}
+
+func getOptionsOrSetDefault(options *Options) *Options {
+	if options == nil {
+		return &Options{
+			prefix:  "rsa_nonce",
+			maxSkew: 5 * time.Minute,
+		}
+	}
+	return options
+}
@@ -0,0 +1,70 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/ppxb/oreo-admin-go/pkg/dialect"
+	"github.com/ppxb/oreo-admin-go/pkg/utils"
+)
+
+type Options struct {
+	ctx            context.Context
+	driver         string
+	replicas       []string
+	pool           PoolOptions
+	readYourWrites time.Duration
+}
+
+type PoolOptions struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+}
+
+func WithCtx(ctx context.Context) func(*Options) {
+	return func(options *Options) {
+		if !utils.InterfaceIsNil(ctx) {
+			getOptionsOrSetDefault(options).ctx = ctx
+		}
+	}
+}
+
+func WithDriver(s string) func(*Options) {
+	return func(options *Options) {
+		getOptionsOrSetDefault(options).driver = s
+	}
+}
+
+func WithReplicas(uris ...string) func(*Options) {
+	return func(options *Options) {
+		getOptionsOrSetDefault(options).replicas = append(getOptionsOrSetDefault(options).replicas, uris...)
+	}
+}
+
+func WithPool(pool PoolOptions) func(*Options) {
+	return func(options *Options) {
+		getOptionsOrSetDefault(options).pool = pool
+	}
+}
+
+// WithReadYourWrites keeps reads of a session on the primary for d after a write
+func WithReadYourWrites(d time.Duration) func(*Options) {
+	return func(options *Options) {
+		if d >= 0 {
+			getOptionsOrSetDefault(options).readYourWrites = d
+		}
+	}
+}
+
+func getOptionsOrSetDefault(options *Options) *Options {
+	if options == nil {
+		return &Options{
+			ctx:            context.Background(),
+			driver:         dialect.Mysql,
+			readYourWrites: 3 * time.Second,
+		}
+	}
+	return options
+}
@@ -0,0 +1,128 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+
+	"github.com/ppxb/oreo-admin-go/pkg/dialect"
+	"github.com/ppxb/oreo-admin-go/pkg/log"
+)
+
+const (
+	PrimaryName = "primary"
+	// resolverReadSetting is the statement setting stored by dbresolver.Read
+	resolverReadSetting = "gorm:db_resolver:read"
+)
+
+var pools sync.Map
+
+// Register tunes the primary pool of db and routes reads to replicas, writes stay on the primary
+func Register(db *gorm.DB, options ...func(*Options)) error {
+	ops := getOptionsOrSetDefault(nil)
+	for _, f := range options {
+		f(ops)
+	}
+
+	primary, err := db.DB()
+	if err != nil {
+		return err
+	}
+	setupPool(primary, ops.pool)
+	pools.Store(PrimaryName, primary)
+
+	if len(ops.replicas) == 0 {
+		return nil
+	}
+
+	d, err := dialect.Get(ops.driver)
+	if err != nil {
+		return err
+	}
+
+	replicas := make([]gorm.Dialector, 0, len(ops.replicas))
+	for i, uri := range ops.replicas {
+		replica, err := sql.Open(d.DriverName(), uri)
+		if err != nil {
+			return errors.Wrapf(err, "open replica %d", i)
+		}
+		setupPool(replica, ops.pool)
+		if err = replica.PingContext(ops.ctx); err != nil {
+			return errors.Wrapf(err, "ping replica %d", i)
+		}
+		pools.Store(fmt.Sprintf("replica-%d", i), replica)
+		replicas = append(replicas, d.New(replica))
+	}
+
+	if err = db.Use(dbresolver.Register(dbresolver.Config{
+		Replicas: replicas,
+		Policy:   dbresolver.RandomPolicy{},
+	})); err != nil {
+		return err
+	}
+
+	if err = registerCallbacks(db, ops); err != nil {
+		return err
+	}
+
+	log.WithContext(ops.ctx).WithComponent("database").Info("Read/write splitting enabled", "replicas", len(replicas), "readYourWrites", ops.readYourWrites)
+	return nil
+}
+
+// Primary forces the query to the primary, e.g. reading a row right after writing it in another request
+func Primary(db *gorm.DB) *gorm.DB {
+	return db.Clauses(dbresolver.Write)
+}
+
+// Replica forces the query to a replica, even inside the read your writes window
+func Replica(db *gorm.DB) *gorm.DB {
+	return db.Clauses(dbresolver.Read)
+}
+
+// Stats returns the sql.DB pool stats of primary and replicas
+func Stats() map[string]sql.DBStats {
+	stats := make(map[string]sql.DBStats)
+	pools.Range(func(key, value interface{}) bool {
+		stats[key.(string)] = value.(*sql.DB).Stats()
+		return true
+	})
+	return stats
+}
+
+// StatsString is a compact form of Stats for logs
+func StatsString() string {
+	stats := Stats()
+	names := make([]string, 0, len(stats))
+	for name := range stats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	items := make([]string, 0, len(names))
+	for _, name := range names {
+		s := stats[name]
+		items = append(items, fmt.Sprintf("%s(open: %d, in use: %d, idle: %d, wait: %d/%s)", name, s.OpenConnections, s.InUse, s.Idle, s.WaitCount, s.WaitDuration))
+	}
+	return strings.Join(items, ", ")
+}
+
+func setupPool(db *sql.DB, pool PoolOptions) {
+	if pool.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(pool.MaxOpenConns)
+	}
+	if pool.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(pool.MaxIdleConns)
+	}
+	if pool.ConnMaxLifetime > 0 {
+		db.SetConnMaxLifetime(pool.ConnMaxLifetime)
+	}
+	if pool.ConnMaxIdleTime > 0 {
+		db.SetConnMaxIdleTime(pool.ConnMaxIdleTime)
+	}
+}
@@ -0,0 +1,104 @@
+package database
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+
+	"github.com/ppxb/oreo-admin-go/pkg/tracing"
+)
+
+type sessionCtxKey struct{}
+
+// session remembers the last write of a request, so that the following reads of the
+// same request are not served by a replica which has not caught up yet
+type session struct {
+	lastWrite atomic.Int64
+}
+
+// NewSession attaches a read your writes session to ctx
+func NewSession(ctx context.Context) context.Context {
+	return context.WithValue(tracing.RealCtx(ctx), sessionCtxKey{}, &session{})
+}
+
+// Session is the gin middleware creating a read your writes session per request
+func Session() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Request = c.Request.WithContext(NewSession(c.Request.Context()))
+		c.Next()
+	}
+}
+
+func getSession(ctx context.Context) *session {
+	if ctx == nil {
+		return nil
+	}
+	s, _ := tracing.RealCtx(ctx).Value(sessionCtxKey{}).(*session)
+	return s
+}
+
+func registerCallbacks(db *gorm.DB, ops *Options) error {
+	if ops.readYourWrites <= 0 {
+		return nil
+	}
+
+	// dbresolver runs first(before "*"), ModifyStatement resolves the connection again for the primary
+	route := routeRecentWrite(ops.readYourWrites)
+	cb := db.Callback()
+	if err := cb.Query().Before("gorm:query").Register("oreo:read_your_writes", route); err != nil {
+		return err
+	}
+	if err := cb.Row().Before("gorm:row").Register("oreo:read_your_writes", route); err != nil {
+		return err
+	}
+	if err := cb.Raw().Before("gorm:raw").Register("oreo:read_your_writes", route); err != nil {
+		return err
+	}
+	if err := cb.Create().After("*").Register("oreo:mark_write", markWrite); err != nil {
+		return err
+	}
+	if err := cb.Update().After("*").Register("oreo:mark_write", markWrite); err != nil {
+		return err
+	}
+	if err := cb.Delete().After("*").Register("oreo:mark_write", markWrite); err != nil {
+		return err
+	}
+	return cb.Raw().After("*").Register("oreo:mark_write", markRawWrite)
+}
+
+func routeRecentWrite(window time.Duration) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		if _, ok := db.Statement.Settings.Load(resolverReadSetting); ok {
+			return
+		}
+		s := getSession(db.Statement.Context)
+		if s == nil {
+			return
+		}
+		if last := s.lastWrite.Load(); last > 0 && time.Since(time.Unix(0, last)) < window {
+			dbresolver.Write.ModifyStatement(db.Statement)
+		}
+	}
+}
+
+func markWrite(db *gorm.DB) {
+	if db.Error != nil {
+		return
+	}
+	if s := getSession(db.Statement.Context); s != nil {
+		s.lastWrite.Store(time.Now().UnixNano())
+	}
+}
+
+func markRawWrite(db *gorm.DB) {
+	sql := strings.TrimSpace(db.Statement.SQL.String())
+	if len(sql) >= 6 && strings.EqualFold(sql[:6], "select") {
+		return
+	}
+	markWrite(db)
+}
@@ -0,0 +1,118 @@
+package database
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/ppxb/oreo-admin-go/pkg/dialect"
+)
+
+type sessionItem struct {
+	Id   uint
+	Name string
+}
+
+// openSplit returns a primary with a replica which never catches up, so the rows tell where a read went
+func openSplit(t *testing.T) *gorm.DB {
+	t.Helper()
+	d, err := dialect.Get(dialect.Sqlite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	replicaPath := filepath.Join(dir, "replica.db")
+	replica, err := gorm.Open(d.Open(replicaPath), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = replica.AutoMigrate(&sessionItem{}); err != nil {
+		t.Fatal(err)
+	}
+	if err = replica.Create(&sessionItem{Name: "replica"}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := gorm.Open(d.Open(filepath.Join(dir, "primary.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = db.AutoMigrate(&sessionItem{}); err != nil {
+		t.Fatal(err)
+	}
+	if err = Register(db,
+		WithDriver(dialect.Sqlite),
+		WithReplicas(replicaPath),
+		WithReadYourWrites(time.Minute),
+	); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func readNames(t *testing.T, db *gorm.DB, ctx context.Context) []string {
+	t.Helper()
+	var names []string
+	if err := db.WithContext(ctx).Model(&sessionItem{}).Order("id").Pluck("name", &names).Error; err != nil {
+		t.Fatal(err)
+	}
+	return names
+}
+
+func TestSessionReadsOwnWrites(t *testing.T) {
+	db := openSplit(t)
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(Session())
+	var got map[string][]string
+	r.GET("/read", func(c *gin.Context) {
+		got["read"] = readNames(t, db, c)
+	})
+	r.POST("/write", func(c *gin.Context) {
+		got["before"] = readNames(t, db, c)
+		if err := db.WithContext(c).Create(&sessionItem{Name: "primary"}).Error; err != nil {
+			t.Fatal(err)
+		}
+		got["after"] = readNames(t, db, c)
+	})
+
+	got = make(map[string][]string)
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/write", nil))
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/read", nil))
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		// reads go to the replica until the session writes
+		{"before", "replica"},
+		{"after", "primary"},
+		// another request has its own session
+		{"read", "replica"},
+	}
+	for _, tt := range tests {
+		if names := got[tt.name]; len(names) != 1 || names[0] != tt.want {
+			t.Errorf("%s: read %v, want [%s]", tt.name, names, tt.want)
+		}
+	}
+}
+
+func TestReadWithoutSessionGoesToReplica(t *testing.T) {
+	db := openSplit(t)
+	ctx := context.Background()
+	if err := db.WithContext(ctx).Create(&sessionItem{Name: "primary"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if names := readNames(t, db, ctx); len(names) != 1 || names[0] != "replica" {
+		t.Errorf("read %v, want [replica]", names)
+	}
+	if names := readNames(t, Primary(db), ctx); len(names) != 1 || names[0] != "primary" {
+		t.Errorf("primary read %v, want [primary]", names)
+	}
+}
@@ -0,0 +1,83 @@
+package dialect
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+const (
+	Mysql    = "mysql"
+	Postgres = "postgres"
+	Sqlite   = "sqlite"
+
+	passwordMask = "******"
+)
+
+// Dialect hides the database specific parts of bootstrap and migration
+type Dialect interface {
+	// Name is the value of the driver config(mysql/postgres/sqlite)
+	Name() string
+	// DriverName is the database/sql driver name used by sql.Open
+	DriverName() string
+	// MigrateDialect is the sql-migrate dialect name
+	MigrateDialect() string
+	// DatabaseName parses the database name from uri
+	DatabaseName(uri string) (string, error)
+	// MaskUri hides the password of uri so that it can be logged
+	MaskUri(uri string) string
+	// EnsureDatabase creates the database of uri if not exists
+	EnsureDatabase(ctx context.Context, uri string) error
+	// DatabaseExists reports whether the database of uri exists without creating it
+	DatabaseExists(ctx context.Context, uri string) (bool, error)
+	// ReadOnlyUri returns uri with sessions which can not write, used by status and dry runs
+	ReadOnlyUri(uri string) string
+	// TryLock tries to acquire a session level advisory lock on conn, waiting at most timeout seconds
+	TryLock(ctx context.Context, conn *sql.Conn, name string, timeout int) (bool, error)
+	// Unlock releases the advisory lock held by conn
+	Unlock(ctx context.Context, conn *sql.Conn, name string) error
+	// LockHolder describes the session holding the lock, empty if the lock is free
+	LockHolder(ctx context.Context, conn *sql.Conn, name string) (string, error)
+	// AlterColumnType returns the statement changing the type of column, table and column are quoted
+	AlterColumnType(table, column, dataType string) string
+	// DropIndex returns the statement dropping index of table, table and index are quoted
+	DropIndex(table, index string) string
+	// Open returns a gorm dialector for uri
+	Open(uri string) gorm.Dialector
+	// New returns a gorm dialector on top of an existing connection pool
+	New(conn gorm.ConnPool) gorm.Dialector
+}
+
+var (
+	dialects = make(map[string]Dialect)
+	lock     sync.RWMutex
+)
+
+func init() {
+	Register(mysqlDialect{})
+	Register(postgresDialect{})
+	Register(newSqliteDialect())
+}
+
+func Register(d Dialect) {
+	lock.Lock()
+	defer lock.Unlock()
+	dialects[d.Name()] = d
+}
+
+// Get returns the dialect of name, empty name means mysql
+func Get(name string) (Dialect, error) {
+	if name == "" {
+		name = Mysql
+	}
+	lock.RLock()
+	defer lock.RUnlock()
+	d, ok := dialects[name]
+	if !ok {
+		return nil, errors.Errorf("unsupported database driver: %s", name)
+	}
+	return d, nil
+}
@@ -0,0 +1,155 @@
+package dialect
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	m "github.com/go-sql-driver/mysql"
+	"github.com/pkg/errors"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+type mysqlDialect struct{}
+
+func (mysqlDialect) Name() string {
+	return Mysql
+}
+
+func (mysqlDialect) DriverName() string {
+	return "mysql"
+}
+
+func (mysqlDialect) MigrateDialect() string {
+	return "mysql"
+}
+
+func (mysqlDialect) DatabaseName(uri string) (string, error) {
+	cfg, err := m.ParseDSN(uri)
+	if err != nil {
+		return "", err
+	}
+	return cfg.DBName, nil
+}
+
+func (mysqlDialect) MaskUri(uri string) string {
+	cfg, err := m.ParseDSN(uri)
+	if err != nil {
+		// an unparsable uri may still contain the password
+		return passwordMask
+	}
+	if cfg.Passwd != "" {
+		cfg.Passwd = passwordMask
+	}
+	return cfg.FormatDSN()
+}
+
+func (mysqlDialect) EnsureDatabase(ctx context.Context, uri string) error {
+	cfg, err := m.ParseDSN(uri)
+	if err != nil {
+		return errors.Wrap(err, "invalid mysql uri")
+	}
+
+	dbName := cfg.DBName
+	cfg.DBName = ""
+
+	db, err := sql.Open("mysql", cfg.FormatDSN())
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	_, err = db.ExecContext(ctx, fmt.Sprintf("CREATE DATABASE IF NOT EXISTS `%s`", dbName))
+	return err
+}
+
+func (mysqlDialect) DatabaseExists(ctx context.Context, uri string) (bool, error) {
+	cfg, err := m.ParseDSN(uri)
+	if err != nil {
+		return false, errors.Wrap(err, "invalid mysql uri")
+	}
+
+	dbName := cfg.DBName
+	cfg.DBName = ""
+
+	db, err := sql.Open("mysql", cfg.FormatDSN())
+	if err != nil {
+		return false, err
+	}
+	defer db.Close()
+
+	var n int
+	err = db.QueryRowContext(ctx, "SELECT COUNT(*) FROM information_schema.SCHEMATA WHERE SCHEMA_NAME = ?", dbName).Scan(&n)
+	return n > 0, err
+}
+
+// ReadOnlyUri sets transaction_read_only for every session, the driver sends params as system variables
+func (mysqlDialect) ReadOnlyUri(uri string) string {
+	cfg, err := m.ParseDSN(uri)
+	if err != nil {
+		return uri
+	}
+	if cfg.Params == nil {
+		cfg.Params = make(map[string]string)
+	}
+	cfg.Params["transaction_read_only"] = "1"
+	return cfg.FormatDSN()
+}
+
+func (mysqlDialect) TryLock(ctx context.Context, conn *sql.Conn, name string, timeout int) (bool, error) {
+	var acquired sql.NullBool
+	if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", name, timeout).Scan(&acquired); err != nil {
+		return false, err
+	}
+	return acquired.Valid && acquired.Bool, nil
+}
+
+func (mysqlDialect) Unlock(ctx context.Context, conn *sql.Conn, name string) error {
+	_, err := conn.ExecContext(ctx, "SELECT RELEASE_LOCK(?)", name)
+	return err
+}
+
+// LockHolder resolves the session through IS_USED_LOCK and the processlist
+func (mysqlDialect) LockHolder(ctx context.Context, conn *sql.Conn, name string) (string, error) {
+	var id sql.NullInt64
+	if err := conn.QueryRowContext(ctx, "SELECT IS_USED_LOCK(?)", name).Scan(&id); err != nil {
+		return "", err
+	}
+	if !id.Valid {
+		return "", nil
+	}
+
+	var (
+		user, host, db, command, state string
+		seconds                        int64
+	)
+	err := conn.QueryRowContext(
+		ctx,
+		"SELECT USER, HOST, IFNULL(DB, ''), COMMAND, TIME, IFNULL(STATE, '') FROM information_schema.PROCESSLIST WHERE ID = ?",
+		id.Int64,
+	).Scan(&user, &host, &db, &command, &seconds, &state)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Sprintf("connection %d", id.Int64), nil
+	}
+	if err != nil {
+		return fmt.Sprintf("connection %d", id.Int64), err
+	}
+	return fmt.Sprintf("connection %d(%s@%s, db: %s, command: %s, time: %ds, state: %s)", id.Int64, user, host, db, command, seconds, state), nil
+}
+
+func (mysqlDialect) AlterColumnType(table, column, dataType string) string {
+	return fmt.Sprintf("ALTER TABLE %s MODIFY COLUMN %s %s;", table, column, dataType)
+}
+
+func (mysqlDialect) DropIndex(table, index string) string {
+	return fmt.Sprintf("DROP INDEX %s ON %s;", index, table)
+}
+
+func (mysqlDialect) Open(uri string) gorm.Dialector {
+	return mysql.Open(uri)
+}
+
+func (mysqlDialect) New(conn gorm.ConnPool) gorm.Dialector {
+	return mysql.New(mysql.Config{Conn: conn})
+}
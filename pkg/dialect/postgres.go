@@ -0,0 +1,191 @@
+package dialect
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/pkg/errors"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+const (
+	postgresMaintenanceDB = "postgres"
+	postgresLockInterval  = 200 * time.Millisecond
+)
+
+var (
+	postgresDBNameRe = regexp.MustCompile(`dbname=\S+`)
+	// a keyword/value password may be quoted, e.g. password='p a ss'
+	postgresPasswordRe = regexp.MustCompile(`password=('(?:[^'\\]|\\.)*'|\S+)`)
+)
+
+type postgresDialect struct{}
+
+func (postgresDialect) Name() string {
+	return Postgres
+}
+
+func (postgresDialect) DriverName() string {
+	return "pgx"
+}
+
+func (postgresDialect) MigrateDialect() string {
+	return "postgres"
+}
+
+func (postgresDialect) DatabaseName(uri string) (string, error) {
+	cfg, err := pgconn.ParseConfig(uri)
+	if err != nil {
+		return "", err
+	}
+	return cfg.Database, nil
+}
+
+func (postgresDialect) MaskUri(uri string) string {
+	if strings.HasPrefix(uri, "postgres://") || strings.HasPrefix(uri, "postgresql://") {
+		u, err := url.Parse(uri)
+		if err != nil {
+			return passwordMask
+		}
+		return u.Redacted()
+	}
+	return postgresPasswordRe.ReplaceAllString(uri, "password="+passwordMask)
+}
+
+func (d postgresDialect) EnsureDatabase(ctx context.Context, uri string) error {
+	exists, err := d.DatabaseExists(ctx, uri)
+	if err != nil || exists {
+		return err
+	}
+
+	dbName, _ := d.DatabaseName(uri)
+	db, err := sql.Open(d.DriverName(), withPostgresDatabase(uri, postgresMaintenanceDB))
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	// CREATE DATABASE does not support bind parameters
+	_, err = db.ExecContext(ctx, "CREATE DATABASE "+pgx.Identifier{dbName}.Sanitize())
+	return err
+}
+
+func (d postgresDialect) DatabaseExists(ctx context.Context, uri string) (bool, error) {
+	dbName, err := d.DatabaseName(uri)
+	if err != nil {
+		return false, errors.Wrap(err, "invalid postgres uri")
+	}
+	if dbName == "" || dbName == postgresMaintenanceDB {
+		return true, nil
+	}
+
+	db, err := sql.Open(d.DriverName(), withPostgresDatabase(uri, postgresMaintenanceDB))
+	if err != nil {
+		return false, err
+	}
+	defer db.Close()
+
+	var exists bool
+	err = db.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM pg_database WHERE datname = $1)", dbName).Scan(&exists)
+	return exists, err
+}
+
+// ReadOnlyUri adds the runtime param default_transaction_read_only to a url or keyword/value connection string
+func (postgresDialect) ReadOnlyUri(uri string) string {
+	if strings.HasPrefix(uri, "postgres://") || strings.HasPrefix(uri, "postgresql://") {
+		u, err := url.Parse(uri)
+		if err == nil {
+			q := u.Query()
+			q.Set("default_transaction_read_only", "on")
+			u.RawQuery = q.Encode()
+			return u.String()
+		}
+	}
+	return strings.TrimSpace(uri + " default_transaction_read_only=on")
+}
+
+// TryLock polls pg_try_advisory_lock because pg_advisory_lock has no timeout
+func (postgresDialect) TryLock(ctx context.Context, conn *sql.Conn, name string, timeout int) (bool, error) {
+	deadline := time.Now().Add(time.Duration(timeout) * time.Second)
+	for {
+		var acquired bool
+		if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock(hashtext($1))", name).Scan(&acquired); err != nil {
+			return false, err
+		}
+		if acquired || !time.Now().Before(deadline) {
+			return acquired, nil
+		}
+		select {
+		case <-ctx.Done():
+			return false, ctx.Err()
+		case <-time.After(postgresLockInterval):
+		}
+	}
+}
+
+func (postgresDialect) Unlock(ctx context.Context, conn *sql.Conn, name string) error {
+	_, err := conn.ExecContext(ctx, "SELECT pg_advisory_unlock(hashtext($1))", name)
+	return err
+}
+
+// LockHolder matches the low 32 bits of the lock key which pg_locks exposes as objid
+func (postgresDialect) LockHolder(ctx context.Context, conn *sql.Conn, name string) (string, error) {
+	var (
+		pid                          int64
+		user, addr, db, state, query string
+		seconds                      int64
+	)
+	err := conn.QueryRowContext(ctx, `SELECT a.pid, COALESCE(a.usename, ''), COALESCE(a.client_addr::text, ''), COALESCE(a.datname, ''),
+       COALESCE(a.state, ''), COALESCE(EXTRACT(EPOCH FROM now() - a.state_change)::bigint, 0), COALESCE(a.query, '')
+FROM pg_locks l JOIN pg_stat_activity a ON a.pid = l.pid
+WHERE l.locktype = 'advisory' AND l.granted AND l.objid = (hashtext($1)::bigint & 4294967295)::oid
+LIMIT 1`, name).Scan(&pid, &user, &addr, &db, &state, &seconds, &query)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("pid %d(%s@%s, db: %s, state: %s for %ds, query: %s)", pid, user, addr, db, state, seconds, query), nil
+}
+
+// AlterColumnType may need a USING clause if the values can not be cast implicitly
+func (postgresDialect) AlterColumnType(table, column, dataType string) string {
+	return fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s TYPE %s;", table, column, dataType)
+}
+
+// DropIndex ignores table, postgres index names are unique per schema
+func (postgresDialect) DropIndex(_, index string) string {
+	return fmt.Sprintf("DROP INDEX %s;", index)
+}
+
+func (postgresDialect) Open(uri string) gorm.Dialector {
+	return postgres.Open(uri)
+}
+
+func (postgresDialect) New(conn gorm.ConnPool) gorm.Dialector {
+	return postgres.New(postgres.Config{Conn: conn})
+}
+
+// withPostgresDatabase replaces the database of a url or keyword/value connection string
+func withPostgresDatabase(uri, dbName string) string {
+	if strings.HasPrefix(uri, "postgres://") || strings.HasPrefix(uri, "postgresql://") {
+		u, err := url.Parse(uri)
+		if err == nil {
+			u.Path = "/" + dbName
+			return u.String()
+		}
+	}
+	if postgresDBNameRe.MatchString(uri) {
+		return postgresDBNameRe.ReplaceAllString(uri, "dbname="+dbName)
+	}
+	return strings.TrimSpace(uri + " dbname=" + dbName)
+}
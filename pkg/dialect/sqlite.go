@@ -0,0 +1,138 @@
+package dialect
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+const sqliteLockInterval = 50 * time.Millisecond
+
+// sqliteDialect uses the pure go driver(no cgo), advisory locks only exist inside the current
+// process because sqlite has no server side sessions
+type sqliteDialect struct {
+	locks *sync.Map
+}
+
+func newSqliteDialect() sqliteDialect {
+	return sqliteDialect{
+		locks: &sync.Map{},
+	}
+}
+
+func (sqliteDialect) Name() string {
+	return Sqlite
+}
+
+func (sqliteDialect) DriverName() string {
+	return "sqlite"
+}
+
+func (sqliteDialect) MigrateDialect() string {
+	return "sqlite3"
+}
+
+func (sqliteDialect) DatabaseName(uri string) (string, error) {
+	return sqlitePath(uri), nil
+}
+
+// MaskUri returns uri as is, sqlite has no password
+func (sqliteDialect) MaskUri(uri string) string {
+	return uri
+}
+
+func (sqliteDialect) EnsureDatabase(_ context.Context, uri string) error {
+	p := sqlitePath(uri)
+	if p == "" || p == ":memory:" {
+		return nil
+	}
+	return os.MkdirAll(filepath.Dir(p), 0o755)
+}
+
+// DatabaseExists is always true for in memory databases
+func (sqliteDialect) DatabaseExists(_ context.Context, uri string) (bool, error) {
+	p := sqlitePath(uri)
+	if p == "" || p == ":memory:" {
+		return true, nil
+	}
+	_, err := os.Stat(p)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// ReadOnlyUri converts uri to a file: uri with mode=ro, sqlite would create a missing file otherwise
+func (sqliteDialect) ReadOnlyUri(uri string) string {
+	if !strings.HasPrefix(uri, "file:") {
+		uri = "file:" + uri
+	}
+	if strings.Contains(uri, "?") {
+		return uri + "&mode=ro"
+	}
+	return uri + "?mode=ro"
+}
+
+func (d sqliteDialect) TryLock(ctx context.Context, conn *sql.Conn, name string, timeout int) (bool, error) {
+	deadline := time.Now().Add(time.Duration(timeout) * time.Second)
+	for {
+		if _, loaded := d.locks.LoadOrStore(name, conn); !loaded {
+			return true, nil
+		}
+		if !time.Now().Before(deadline) {
+			return false, nil
+		}
+		select {
+		case <-ctx.Done():
+			return false, ctx.Err()
+		case <-time.After(sqliteLockInterval):
+		}
+	}
+}
+
+func (d sqliteDialect) Unlock(_ context.Context, conn *sql.Conn, name string) error {
+	d.locks.CompareAndDelete(name, conn)
+	return nil
+}
+
+func (d sqliteDialect) LockHolder(_ context.Context, _ *sql.Conn, name string) (string, error) {
+	if _, ok := d.locks.Load(name); ok {
+		return "current process", nil
+	}
+	return "", nil
+}
+
+// AlterColumnType returns a comment, sqlite can only change a column type by rebuilding the table
+func (sqliteDialect) AlterColumnType(table, column, dataType string) string {
+	return fmt.Sprintf("-- TODO: rebuild %s to change %s to %s, sqlite can not alter column types", table, column, dataType)
+}
+
+// DropIndex ignores table, sqlite index names are unique per database
+func (sqliteDialect) DropIndex(_, index string) string {
+	return fmt.Sprintf("DROP INDEX %s;", index)
+}
+
+func (sqliteDialect) Open(uri string) gorm.Dialector {
+	return sqlite.Open(uri)
+}
+
+func (sqliteDialect) New(conn gorm.ConnPool) gorm.Dialector {
+	return &sqlite.Dialector{Conn: conn}
+}
+
+// sqlitePath removes the file: scheme and query options from uri
+func sqlitePath(uri string) string {
+	p := strings.TrimPrefix(uri, "file:")
+	if i := strings.Index(p, "?"); i >= 0 {
+		p = p[:i]
+	}
+	return p
+}
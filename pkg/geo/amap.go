@@ -0,0 +1,81 @@
+package geo
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var errAmapDown = errors.New("amap failed recently, skip it")
+
+type amapResponse struct {
+	Status   string `json:"status"`
+	Info     string `json:"info"`
+	InfoCode string `json:"infocode"`
+	// fields are [] instead of strings for foreign or unknown ips
+	Province json.RawMessage `json:"province"`
+	City     json.RawMessage `json:"city"`
+	AdCode   json.RawMessage `json:"adcode"`
+}
+
+// amap queries the ip api, a failure skips it for the failure ttl
+func (g *Geo) amap(ctx context.Context, ip string) (Location, error) {
+	if time.Now().UnixNano() < g.downUntil.Load() {
+		return Location{}, errAmapDown
+	}
+	l, err := g.amapRequest(ctx, ip)
+	if err != nil {
+		g.downUntil.Store(time.Now().Add(g.ops.failureTtl).UnixNano())
+	}
+	return l, err
+}
+
+func (g *Geo) amapRequest(ctx context.Context, ip string) (Location, error) {
+	ctx, cancel := context.WithTimeout(ctx, g.ops.timeout)
+	defer cancel()
+	query := url.Values{}
+	query.Set("ip", ip)
+	query.Set("key", g.ops.amapKey)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, g.ops.amapUrl+"?"+query.Encode(), nil)
+	if err != nil {
+		return Location{}, err
+	}
+	r, err := g.ops.httpClient.Do(req)
+	if err != nil {
+		// the url of *url.Error contains the key
+		var e *url.Error
+		if errors.As(err, &e) {
+			err = e.Err
+		}
+		return Location{}, errors.Wrap(err, "request amap")
+	}
+	defer r.Body.Close()
+	if r.StatusCode != http.StatusOK {
+		return Location{}, errors.Errorf("amap http status %d", r.StatusCode)
+	}
+	var res amapResponse
+	if err = json.NewDecoder(r.Body).Decode(&res); err != nil {
+		return Location{}, errors.Wrap(err, "decode amap response")
+	}
+	if res.Status != "1" {
+		return Location{}, errors.Errorf("amap error %s: %s", res.InfoCode, res.Info)
+	}
+	return Location{
+		Ip:       ip,
+		Province: rawString(res.Province),
+		City:     rawString(res.City),
+		AdCode:   rawString(res.AdCode),
+	}, nil
+}
+
+func rawString(raw json.RawMessage) string {
+	var s string
+	if json.Unmarshal(raw, &s) != nil {
+		return ""
+	}
+	return s
+}
@@ -0,0 +1,113 @@
+package geo
+
+import (
+	"context"
+	"net/netip"
+	"strings"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+
+	"github.com/ppxb/oreo-admin-go/pkg/cache"
+	"github.com/ppxb/oreo-admin-go/pkg/constant"
+	"github.com/ppxb/oreo-admin-go/pkg/log"
+	"github.com/ppxb/oreo-admin-go/pkg/tracing"
+)
+
+// Location of an ip, fields are empty if unknown(e.g. foreign ips of amap)
+type Location struct {
+	Ip       string `json:"ip"`
+	Province string `json:"province"`
+	City     string `json:"city"`
+	AdCode   string `json:"adCode"`
+	// Internal is set for loopback/private ips, which are not looked up
+	Internal bool `json:"internal"`
+}
+
+func (l Location) String() string {
+	if l.Internal {
+		return "internal"
+	}
+	if l.City == "" || l.City == l.Province {
+		return l.Province
+	}
+	return strings.TrimSpace(l.Province + " " + l.City)
+}
+
+// Geo resolves ips through amap with the offline file as fallback
+type Geo struct {
+	ops    Options
+	ranges []ipRange
+	// downUntil is the unix nano until which amap is skipped
+	downUntil atomic.Int64
+}
+
+func New(options ...func(*Options)) (*Geo, error) {
+	ops := getOptionsOrSetDefault(nil)
+	for _, f := range options {
+		f(ops)
+	}
+	g := &Geo{ops: *ops}
+	if ops.file != "" {
+		ranges, err := loadFile(ops.file)
+		if err != nil {
+			return nil, errors.Wrapf(err, "load geo file %s", ops.file)
+		}
+		g.ranges = ranges
+	}
+	return g, nil
+}
+
+// Lookup returns the location of ip, the error is set if neither amap nor the offline file knows it
+func (g *Geo) Lookup(ctx context.Context, ip string) (Location, error) {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return Location{Ip: ip}, errors.Wrapf(err, "invalid ip %s", ip)
+	}
+	addr = addr.Unmap()
+	ip = addr.String()
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsLinkLocalUnicast() || addr.IsUnspecified() {
+		return Location{Ip: ip, Internal: true}, nil
+	}
+
+	l := Location{Ip: ip}
+	var online error
+	// amap v3 only supports ipv4
+	queried := g.ops.amapKey != "" && addr.Is4()
+	if queried {
+		if g.ops.cache != nil {
+			l, online = cache.GetOrLoad(ctx, g.ops.cache, "geo:"+ip, func(ctx context.Context) (Location, error) {
+				return g.amap(ctx, ip)
+			}, cache.WithTtl(g.ops.ttl))
+		} else {
+			l, online = g.amap(ctx, ip)
+		}
+		if online == nil && l.Province != "" {
+			return l, nil
+		}
+	}
+
+	// amap does not know foreign ips
+	if item, ok := g.offline(addr); ok {
+		if online != nil && !errors.Is(online, errAmapDown) {
+			log.WithContext(ctx).WithError(online).WithComponent("geo").Warn("Amap lookup failed, use offline file", "ip", ip)
+		}
+		return item, nil
+	}
+	if online != nil {
+		return Location{Ip: ip}, online
+	}
+	if !queried {
+		return l, errors.Errorf("location of %s is unknown", ip)
+	}
+	return l, nil
+}
+
+// FromContext returns the location attached by the ClientIp middleware, it is looked up on the first call
+func FromContext(ctx context.Context) (Location, bool) {
+	l, ok := tracing.RealCtx(ctx).Value(constant.MiddlewareLocationCtxKey).(*Lazy)
+	if !ok {
+		return Location{}, false
+	}
+	return l.Location(), true
+}
@@ -0,0 +1,155 @@
+package geo
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ppxb/oreo-admin-go/pkg/cache"
+)
+
+const testKey = "amap-key"
+
+// newAmap is a local amap stub, handle writes the response of each request
+func newAmap(t *testing.T, handle func(w http.ResponseWriter, r *http.Request)) (*httptest.Server, *atomic.Int32) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		handle(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &hits
+}
+
+func amapOk(w http.ResponseWriter, _ *http.Request) {
+	_, _ = w.Write([]byte(`{"status":"1","info":"OK","infocode":"10000","province":"浙江省","city":"杭州市","adcode":"330100"}`))
+}
+
+func writeFile(t *testing.T) string {
+	name := filepath.Join(t.TempDir(), "geo.csv")
+	if err := os.WriteFile(name, []byte("# start,end,province,city,adcode\n8.8.8.0,8.8.8.255,海外,,\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return name
+}
+
+func TestLookup(t *testing.T) {
+	srv, hits := newAmap(t, amapOk)
+	g, err := New(WithAmapKey(testKey), WithAmapUrl(srv.URL), WithCache(cache.New()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		l, err := g.Lookup(ctx, "::ffff:115.236.1.1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if l.Ip != "115.236.1.1" || l.String() != "浙江省 杭州市" || l.AdCode != "330100" {
+			t.Fatalf("location = %+v", l)
+		}
+	}
+	if n := hits.Load(); n != 1 {
+		t.Errorf("amap hits = %d, want 1 with cache", n)
+	}
+
+	l, err := g.Lookup(ctx, "192.168.1.1")
+	if err != nil || !l.Internal {
+		t.Fatalf("private ip = %+v, %v", l, err)
+	}
+	if _, err = g.Lookup(ctx, "not an ip"); err == nil {
+		t.Fatal("invalid ip is looked up")
+	}
+	if n := hits.Load(); n != 1 {
+		t.Errorf("amap hits = %d, internal and invalid ips must not call amap", n)
+	}
+}
+
+func TestLookupFailure(t *testing.T) {
+	tests := []struct {
+		name   string
+		handle func(w http.ResponseWriter, r *http.Request)
+	}{
+		{"timeout", func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(2 * time.Second):
+			}
+		}},
+		{"http status", func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusBadGateway)
+		}},
+		{"amap error", func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write([]byte(`{"status":"0","info":"DAILY_QUERY_OVER_LIMIT","infocode":"10003"}`))
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, hits := newAmap(t, tt.handle)
+			g, err := New(
+				WithAmapKey(testKey),
+				WithAmapUrl(srv.URL),
+				WithCache(cache.New()),
+				WithFile(writeFile(t)),
+				WithTimeout(100*time.Millisecond),
+				WithFailureTtl(200*time.Millisecond),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx := context.Background()
+
+			start := time.Now()
+			if _, err = g.Lookup(ctx, "1.2.3.4"); err == nil {
+				t.Fatal("lookup succeeded without amap and offline data")
+			}
+			if elapsed := time.Since(start); elapsed > time.Second {
+				t.Errorf("lookup took %v", elapsed)
+			}
+			if strings.Contains(err.Error(), testKey) {
+				t.Errorf("error contains the amap key: %v", err)
+			}
+
+			// amap is skipped for other ips until the failure ttl passes, the offline file still answers
+			l, err := g.Lookup(ctx, "8.8.8.8")
+			if err != nil || l.Province != "海外" {
+				t.Fatalf("offline location = %+v, %v", l, err)
+			}
+			if n := hits.Load(); n != 1 {
+				t.Errorf("amap hits = %d during failure ttl, want 1", n)
+			}
+			time.Sleep(250 * time.Millisecond)
+			_, _ = g.Lookup(ctx, "1.2.3.5")
+			if n := hits.Load(); n != 2 {
+				t.Errorf("amap hits = %d after failure ttl, want 2", n)
+			}
+		})
+	}
+}
+
+func TestLazy(t *testing.T) {
+	srv, hits := newAmap(t, amapOk)
+	g, err := New(WithAmapKey(testKey), WithAmapUrl(srv.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := g.Lazy(context.Background(), "115.236.1.1")
+	if n := hits.Load(); n != 0 {
+		t.Fatalf("amap hits = %d before use", n)
+	}
+	for i := 0; i < 3; i++ {
+		if s := l.String(); s != "浙江省 杭州市" {
+			t.Fatalf("location = %q", s)
+		}
+	}
+	if n := hits.Load(); n != 1 {
+		t.Errorf("amap hits = %d, want 1", n)
+	}
+}
@@ -0,0 +1,39 @@
+package geo
+
+import (
+	"context"
+	"sync"
+
+	"github.com/ppxb/oreo-admin-go/pkg/log"
+)
+
+// Lazy is the location of an ip looked up on first use, e.g. by the first log line of a request,
+// so that requests which never use it do not wait for amap
+type Lazy struct {
+	g    *Geo
+	ctx  context.Context
+	ip   string
+	once sync.Once
+	l    Location
+}
+
+// Lazy returns the location of ip to be looked up later, ctx must not carry the Lazy itself
+// since the lookup logs with it
+func (g *Geo) Lazy(ctx context.Context, ip string) *Lazy {
+	return &Lazy{g: g, ctx: context.WithoutCancel(ctx), ip: ip}
+}
+
+func (z *Lazy) Location() Location {
+	z.once.Do(func() {
+		l, err := z.g.Lookup(z.ctx, z.ip)
+		if err != nil {
+			log.WithContext(z.ctx).WithError(err).WithComponent("geo").Debug("Lookup failed", "ip", z.ip)
+		}
+		z.l = l
+	})
+	return z.l
+}
+
+func (z *Lazy) String() string {
+	return z.Location().String()
+}
@@ -0,0 +1,86 @@
+package geo
+
+import (
+	"bufio"
+	"net/netip"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+type ipRange struct {
+	start netip.Addr
+	end   netip.Addr
+	loc   Location
+}
+
+// loadFile reads a csv of `start ip,end ip,province,city,adcode`, ipv4 and ipv6 ranges may be mixed,
+// empty lines and lines starting with # are skipped
+func loadFile(name string) ([]ipRange, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var ranges []ipRange
+	scanner := bufio.NewScanner(f)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Split(text, ",")
+		if len(fields) < 4 {
+			return nil, errors.Errorf("line %d: expect at least 4 fields", line)
+		}
+		for i := range fields {
+			fields[i] = strings.TrimSpace(fields[i])
+		}
+		start, err := netip.ParseAddr(fields[0])
+		if err != nil {
+			return nil, errors.Wrapf(err, "line %d", line)
+		}
+		end, err := netip.ParseAddr(fields[1])
+		if err != nil {
+			return nil, errors.Wrapf(err, "line %d", line)
+		}
+		start, end = start.Unmap(), end.Unmap()
+		if start.Is4() != end.Is4() || end.Less(start) {
+			return nil, errors.Errorf("line %d: invalid range %s-%s", line, start, end)
+		}
+		r := ipRange{start: start, end: end, loc: Location{Province: fields[2], City: fields[3]}}
+		if len(fields) > 4 {
+			r.loc.AdCode = fields[4]
+		}
+		ranges = append(ranges, r)
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+	sort.Slice(ranges, func(i, j int) bool {
+		return ranges[i].start.Less(ranges[j].start)
+	})
+	return ranges, nil
+}
+
+// offline finds the range containing addr, ranges of the file must not overlap
+func (g *Geo) offline(addr netip.Addr) (Location, bool) {
+	i := sort.Search(len(g.ranges), func(i int) bool {
+		return addr.Less(g.ranges[i].start)
+	})
+	if i == 0 {
+		return Location{}, false
+	}
+	r := g.ranges[i-1]
+	if r.end.Less(addr) || r.start.Is4() != addr.Is4() {
+		return Location{}, false
+	}
+	l := r.loc
+	l.Ip = addr.String()
+	return l, true
+}
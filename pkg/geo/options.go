@@ -0,0 +1,110 @@
+package geo
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/ppxb/oreo-admin-go/pkg/cache"
+	"github.com/ppxb/oreo-admin-go/pkg/utils"
+)
+
+type Options struct {
+	ctx        context.Context
+	amapKey    string
+	amapUrl    string
+	cache      *cache.Cache
+	ttl        time.Duration
+	file       string
+	httpClient *http.Client
+	timeout    time.Duration
+	failureTtl time.Duration
+}
+
+func WithCtx(ctx context.Context) func(*Options) {
+	return func(options *Options) {
+		if !utils.InterfaceIsNil(ctx) {
+			getOptionsOrSetDefault(options).ctx = ctx
+		}
+	}
+}
+
+// WithAmapKey enables the amap ip api, only the offline file is used without it
+func WithAmapKey(s string) func(*Options) {
+	return func(options *Options) {
+		getOptionsOrSetDefault(options).amapKey = s
+	}
+}
+
+// WithAmapUrl replaces https://restapi.amap.com/v3/ip, e.g. a local stub
+func WithAmapUrl(s string) func(*Options) {
+	return func(options *Options) {
+		if s != "" {
+			getOptionsOrSetDefault(options).amapUrl = s
+		}
+	}
+}
+
+// WithCache caches results of amap, every request calls amap without it
+func WithCache(c *cache.Cache) func(*Options) {
+	return func(options *Options) {
+		if c != nil {
+			getOptionsOrSetDefault(options).cache = c
+		}
+	}
+}
+
+func WithTtl(ttl time.Duration) func(*Options) {
+	return func(options *Options) {
+		if ttl > 0 {
+			getOptionsOrSetDefault(options).ttl = ttl
+		}
+	}
+}
+
+// WithFile loads the offline database used when amap is unavailable, see loadFile for the format
+func WithFile(s string) func(*Options) {
+	return func(options *Options) {
+		getOptionsOrSetDefault(options).file = s
+	}
+}
+
+func WithHttpClient(c *http.Client) func(*Options) {
+	return func(options *Options) {
+		if c != nil {
+			getOptionsOrSetDefault(options).httpClient = c
+		}
+	}
+}
+
+// WithTimeout limits one amap request, the lookup runs when the location is first used by the request
+func WithTimeout(d time.Duration) func(*Options) {
+	return func(options *Options) {
+		if d > 0 {
+			getOptionsOrSetDefault(options).timeout = d
+		}
+	}
+}
+
+// WithFailureTtl skips amap for a while after it fails, so that an outage costs one timeout per ttl
+func WithFailureTtl(ttl time.Duration) func(*Options) {
+	return func(options *Options) {
+		if ttl > 0 {
+			getOptionsOrSetDefault(options).failureTtl = ttl
+		}
+	}
+}
+
+func getOptionsOrSetDefault(options *Options) *Options {
+	if options == nil {
+		return &Options{
+			ctx:        context.Background(),
+			amapUrl:    "https://restapi.amap.com/v3/ip",
+			ttl:        7 * 24 * time.Hour,
+			httpClient: &http.Client{},
+			timeout:    800 * time.Millisecond,
+			failureTtl: 30 * time.Second,
+		}
+	}
+	return options
+}
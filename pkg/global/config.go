@@ -7,28 +7,35 @@ import (
 )
 
 type Configuration struct {
-	System SystemConfiguration `mapstructure:"system" json:"system"`
-	Tracer TracerConfiguration `mapstructure:"tracer" json:"tracer"`
-	Logs   LogsConfiguration   `mapstructure:"logs" json:"logs"`
-	Mysql  MysqlConfiguration  `mapstructure:"mysql" json:"mysql"`
-	Redis  RedisConfiguration  `mapstructure:"redis" json:"redis"`
-	Jwt    JwtConfiguration    `mapstructure:"jwt" json:"jwt"`
-	Upload UploadConfiguration `mapstructure:"upload" json:"upload"`
-	WeChat WeChatConfiguration `mapstructure:"we-chat" json:"weChat"`
+	System   SystemConfiguration   `mapstructure:"system" json:"system"`
+	Tracer   TracerConfiguration   `mapstructure:"tracer" json:"tracer"`
+	Logs     LogsConfiguration     `mapstructure:"logs" json:"logs"`
+	Metrics  MetricsConfiguration  `mapstructure:"metrics" json:"metrics"`
+	Mysql    MysqlConfiguration    `mapstructure:"mysql" json:"mysql"`
+	Redis    RedisConfiguration    `mapstructure:"redis" json:"redis"`
+	Job      JobConfiguration      `mapstructure:"job" json:"job"`
+	Jwt      JwtConfiguration      `mapstructure:"jwt" json:"jwt"`
+	Password PasswordConfiguration `mapstructure:"password" json:"password"`
+	Upload   UploadConfiguration   `mapstructure:"upload" json:"upload"`
+	WeChat   WeChatConfiguration   `mapstructure:"we-chat" json:"weChat"`
 }
 
 type SystemConfiguration struct {
-	MachineId            uint32 `mapstructure:"machine-id" json:"machine-id"`
-	Base                 string `mapstructure:"-" json:"-"`
-	UrlPrefix            string `mapstructure:"url-prefix" json:"url-prefix"`
-	ApiVersion           string `mapstructure:"api-version" json:"apiVersion"`
-	Port                 int    `mapstructure:"port" json:"port"`
-	PprofPort            int    `mapstructure:"pprof-port" json:"pprofPort"`
-	ConnectTimeout       int    `mapstructure:"connect-timeout" json:"connectTimeout"`
-	IdempotenceTokenName string `mapstructure:"idempotence-token-name" json:"idempotenceTokenName"`
-	CasbinModelPath      string `mapstructure:"casbin-model-path" json:"casbinModelPath"`
-	RateLimitMax         int64  `mapstructure:"rate-limit-max" json:"rateLimitMax"`
-	AmapKey              string `mapstructure:"amap-key" json:"amapKey"`
+	MachineId            uint32   `mapstructure:"machine-id" json:"machine-id"`
+	Base                 string   `mapstructure:"-" json:"-"`
+	UrlPrefix            string   `mapstructure:"url-prefix" json:"url-prefix"`
+	ApiVersion           string   `mapstructure:"api-version" json:"apiVersion"`
+	Port                 int      `mapstructure:"port" json:"port"`
+	PprofPort            int      `mapstructure:"pprof-port" json:"pprofPort"`
+	ConnectTimeout       int      `mapstructure:"connect-timeout" json:"connectTimeout"`
+	DegradedStart        bool     `mapstructure:"degraded-start" json:"degradedStart"`
+	IdempotenceTokenName string   `mapstructure:"idempotence-token-name" json:"idempotenceTokenName"`
+	CasbinModelPath      string   `mapstructure:"casbin-model-path" json:"casbinModelPath"`
+	RateLimitMax         int64    `mapstructure:"rate-limit-max" json:"rateLimitMax"`
+	AmapKey              string   `mapstructure:"amap-key" json:"amapKey"`
+	GeoFile              string   `mapstructure:"geo-file" json:"geoFile"`
+	TrustedProxies       []string `mapstructure:"trusted-proxies" json:"trustedProxies"`
+	AdminToken           string   `mapstructure:"admin-token" json:"adminToken"`
 }
 
 type TracerConfiguration struct {
@@ -38,13 +45,80 @@ type TracerConfiguration struct {
 	Headers  map[string]string `mapstructure:"headers" json:"headers"`
 }
 
+type MetricsConfiguration struct {
+	Enable bool `mapstructure:"enable" json:"enable"`
+	// Path defaults to /metrics
+	Path string `mapstructure:"path" json:"path"`
+	// Pprof serves metrics on system.pprof-port with pprof instead of system.port
+	Pprof bool `mapstructure:"pprof" json:"pprof"`
+}
+
 type LogsConfiguration struct {
-	Category                 string                   `mapstructure:"category" json:"category"`
-	Level                    log.Level                `mapstructure:"level" json:"level"`
-	Json                     bool                     `mapstructure:"json" json:"json"`
-	LineNum                  LogsLineNumConfiguration `mapstructure:"line-num" json:"line-num"`
-	OperationKey             string                   `mapstructure:"operation-key" json:"operationKey"`
-	OperationAllowedToDelete bool                     `mapstructure:"operation-allowed-to-delete" json:"operationAllowedToDelete"`
+	Category                 string                    `mapstructure:"category" json:"category"`
+	Level                    log.Level                 `mapstructure:"level" json:"level"`
+	Json                     bool                      `mapstructure:"json" json:"json"`
+	LineNum                  LogsLineNumConfiguration  `mapstructure:"line-num" json:"line-num"`
+	OperationKey             string                    `mapstructure:"operation-key" json:"operationKey"`
+	OperationAllowedToDelete bool                      `mapstructure:"operation-allowed-to-delete" json:"operationAllowedToDelete"`
+	Sinks                    []LogsSinkConfiguration   `mapstructure:"sinks" json:"sinks"`
+	ComponentLevels          map[string]log.Level      `mapstructure:"component-levels" json:"componentLevels"`
+	Redact                   LogsRedactConfiguration   `mapstructure:"redact" json:"redact"`
+	Sampling                 LogsSamplingConfiguration `mapstructure:"sampling" json:"sampling"`
+	Export                   LogsExportConfiguration   `mapstructure:"export" json:"export"`
+}
+
+type LogsExportConfiguration struct {
+	Enable bool `mapstructure:"enable" json:"enable"`
+	// Protocol is otlp or loki
+	Protocol string `mapstructure:"protocol" json:"protocol"`
+	// Endpoint is the url of the collector, otlp defaults to tracer.endpoint with tracer.headers
+	Endpoint string            `mapstructure:"endpoint" json:"endpoint"`
+	Headers  map[string]string `mapstructure:"headers" json:"headers"`
+	Labels   map[string]string `mapstructure:"labels" json:"labels"`
+	Level    *log.Level        `mapstructure:"level" json:"level"`
+	// BatchSize, BufferSize are numbers of records, FlushInterval is milliseconds
+	BatchSize     int `mapstructure:"batch-size" json:"batchSize"`
+	BufferSize    int `mapstructure:"buffer-size" json:"bufferSize"`
+	FlushInterval int `mapstructure:"flush-interval" json:"flushInterval"`
+	MaxRetry      int `mapstructure:"max-retry" json:"maxRetry"`
+}
+
+type LogsSamplingConfiguration struct {
+	// Tick is milliseconds of a sampling window, default 1000
+	Tick int                           `mapstructure:"tick" json:"tick"`
+	App  LogsSamplingRuleConfiguration `mapstructure:"app" json:"app"`
+	Gorm LogsSamplingRuleConfiguration `mapstructure:"gorm" json:"gorm"`
+}
+
+// LogsSamplingRuleConfiguration logs the first records of a key in a tick, then every thereafter-th one
+type LogsSamplingRuleConfiguration struct {
+	Enable     bool   `mapstructure:"enable" json:"enable"`
+	First      uint64 `mapstructure:"first" json:"first"`
+	Thereafter uint64 `mapstructure:"thereafter" json:"thereafter"`
+}
+
+type LogsRedactConfiguration struct {
+	Enable bool `mapstructure:"enable" json:"enable"`
+	// Fields are masked by name, e.g. password also masks newPassword
+	Fields   []string `mapstructure:"fields" json:"fields"`
+	Patterns []string `mapstructure:"patterns" json:"patterns"`
+	Cards    bool     `mapstructure:"cards" json:"cards"`
+	// Columns are masked in SQL of gorm
+	Columns []string `mapstructure:"columns" json:"columns"`
+	Mask    string   `mapstructure:"mask" json:"mask"`
+}
+
+type LogsSinkConfiguration struct {
+	// Type is stdout, stderr or file
+	Type string `mapstructure:"type" json:"type"`
+	// Level caps records of the sink, e.g. 2 for an error file, Json defaults to logs.json
+	Level      *log.Level `mapstructure:"level" json:"level"`
+	Json       *bool      `mapstructure:"json" json:"json"`
+	Path       string     `mapstructure:"path" json:"path"`
+	MaxSize    int        `mapstructure:"max-size" json:"maxSize"`
+	MaxAge     int        `mapstructure:"max-age" json:"maxAge"`
+	MaxBackups int        `mapstructure:"max-backups" json:"maxBackups"`
+	Compress   bool       `mapstructure:"compress" json:"compress"`
 }
 
 type LogsLineNumConfiguration struct {
@@ -55,12 +129,29 @@ type LogsLineNumConfiguration struct {
 }
 
 type MysqlConfiguration struct {
-	Uri         string       `mapstructure:"uri" json:"uri"`
-	TablePrefix string       `mapstructure:"table-prefix" json:"tablePrefix"`
-	NoSql       bool         `mapstructure:"no-sql" json:"noSql"`
-	Transaction bool         `mapstructure:"transaction" json:"transaction"`
-	InitData    bool         `mapstructure:"init-data" json:"initData"`
-	DSN         mysql.Config `json:"-"`
+	Driver         string                      `mapstructure:"driver" json:"driver"`
+	Uri            string                      `mapstructure:"uri" json:"uri"`
+	TablePrefix    string                      `mapstructure:"table-prefix" json:"tablePrefix"`
+	NoSql          bool                        `mapstructure:"no-sql" json:"noSql"`
+	Transaction    bool                        `mapstructure:"transaction" json:"transaction"`
+	InitData       bool                        `mapstructure:"init-data" json:"initData"`
+	DSN            mysql.Config                `json:"-"`
+	Migration      MysqlMigrationConfiguration `mapstructure:"migration" json:"migration"`
+	Pool           MysqlPoolConfiguration      `mapstructure:"pool" json:"pool"`
+	Replicas       []string                    `mapstructure:"replicas" json:"replicas"`
+	ReadYourWrites int                         `mapstructure:"read-your-writes" json:"readYourWrites"`
+}
+
+type MysqlPoolConfiguration struct {
+	MaxOpenConns    int `mapstructure:"max-open-conns" json:"maxOpenConns"`
+	MaxIdleConns    int `mapstructure:"max-idle-conns" json:"maxIdleConns"`
+	ConnMaxLifetime int `mapstructure:"conn-max-lifetime" json:"connMaxLifetime"`
+	ConnMaxIdleTime int `mapstructure:"conn-max-idle-time" json:"connMaxIdleTime"`
+}
+
+type MysqlMigrationConfiguration struct {
+	LockTimeout  int  `mapstructure:"lock-timeout" json:"lockTimeout"`
+	LockFailFast bool `mapstructure:"lock-fail-fast" json:"lockFailFast"`
 }
 
 type RedisConfiguration struct {
@@ -70,6 +161,14 @@ type RedisConfiguration struct {
 	EnableBinlog bool   `mapstructure:"enable-binlog" json:"enableBinlog"`
 }
 
+type JobConfiguration struct {
+	Enable          bool           `mapstructure:"enable" json:"enable"`
+	Concurrency     int            `mapstructure:"concurrency" json:"concurrency"`
+	Queues          map[string]int `mapstructure:"queues" json:"queues"`
+	MaxRetry        int            `mapstructure:"max-retry" json:"maxRetry"`
+	ShutdownTimeout int            `mapstructure:"shutdown-timeout" json:"shutdownTimeout"`
+}
+
 type JwtConfiguration struct {
 	Realm           string `mapstructure:"realm" json:"realm"`
 	Key             string `mapstructure:"key" json:"key"`
@@ -79,6 +178,61 @@ type JwtConfiguration struct {
 	RSAPrivateKey   string `mapstructure:"rsa-private-key" json:"rsaPrivateKey"`
 	RSAPublicBytes  []byte `mapstructure:"-" json:"-"`
 	RSAPrivateBytes []byte `mapstructure:"-" json:"-"`
+	// RSAKeyId defaults to the first 16 hex characters of the public key fingerprint
+	RSAKeyId string `mapstructure:"rsa-key-id" json:"rsaKeyId"`
+	// RSARetiredKeys still decrypt payloads of clients holding an old public key after rotation
+	RSARetiredKeys []JwtRSAKeyConfiguration `mapstructure:"rsa-retired-keys" json:"rsaRetiredKeys"`
+	// RSARequired rejects plaintext credentials
+	RSARequired bool `mapstructure:"rsa-required" json:"rsaRequired"`
+	// RSAMaxSkew is the seconds a payload timestamp may differ from server time
+	RSAMaxSkew int `mapstructure:"rsa-max-skew" json:"rsaMaxSkew"`
+}
+
+type JwtRSAKeyConfiguration struct {
+	Id              string `mapstructure:"id" json:"id"`
+	RSAPrivateKey   string `mapstructure:"rsa-private-key" json:"rsaPrivateKey"`
+	RSAPrivateBytes []byte `mapstructure:"-" json:"-"`
+}
+
+type PasswordConfiguration struct {
+	MinLength        int  `mapstructure:"min-length" json:"minLength"`
+	MaxLength        int  `mapstructure:"max-length" json:"maxLength"`
+	RequireLower     bool `mapstructure:"require-lower" json:"requireLower"`
+	RequireUpper     bool `mapstructure:"require-upper" json:"requireUpper"`
+	RequireDigit     bool `mapstructure:"require-digit" json:"requireDigit"`
+	RequireSymbol    bool `mapstructure:"require-symbol" json:"requireSymbol"`
+	MaxContinuousNum int  `mapstructure:"max-continuous-num" json:"maxContinuousNum"`
+	AllowChinese     bool `mapstructure:"allow-chinese" json:"allowChinese"`
+	// History rejects the last n passwords, 0 disables it
+	History int `mapstructure:"history" json:"history"`
+	// MaxAge expires passwords after n days, 0 disables it
+	MaxAge   int                           `mapstructure:"max-age" json:"maxAge"`
+	Hash     PasswordHashConfiguration     `mapstructure:"hash" json:"hash"`
+	Attempts PasswordAttemptsConfiguration `mapstructure:"attempts" json:"attempts"`
+}
+
+// PasswordAttemptsConfiguration blocks password guessing on login and password change, 0 disables a limit
+type PasswordAttemptsConfiguration struct {
+	// MaxUser is the failures of one username from anywhere
+	MaxUser int64 `mapstructure:"max-user" json:"maxUser"`
+	// MaxIp is the failures of any username from one ip
+	MaxIp int64 `mapstructure:"max-ip" json:"maxIp"`
+	// Window is minutes, failures are counted and keys blocked within it
+	Window int `mapstructure:"window" json:"window"`
+}
+
+type PasswordHashConfiguration struct {
+	// Algorithm is argon2id(default) or bcrypt, hashes of the other one are re-hashed on login
+	Algorithm  string                      `mapstructure:"algorithm" json:"algorithm"`
+	BcryptCost int                         `mapstructure:"bcrypt-cost" json:"bcryptCost"`
+	Argon2     PasswordArgon2Configuration `mapstructure:"argon2" json:"argon2"`
+}
+
+type PasswordArgon2Configuration struct {
+	// Memory is in KiB
+	Memory      uint32 `mapstructure:"memory" json:"memory"`
+	Iterations  uint32 `mapstructure:"iterations" json:"iterations"`
+	Parallelism uint8  `mapstructure:"parallelism" json:"parallelism"`
 }
 
 type UploadConfiguration struct {
@@ -105,6 +259,7 @@ type WeChatOfficialConfiguration struct {
 	AppId              string                                        `mapstructure:"app-id" json:"appId"`
 	AppSecret          string                                        `mapstructure:"app-secret" json:"appSecret"`
 	Encoding           string                                        `mapstructure:"encoding" json:"encoding"`
+	BaseUrl            string                                        `mapstructure:"base-url" json:"baseUrl"`
 	TplMessageCronTask WeChatOfficialTplMessageCronTaskConfiguration `mapstructure:"tpl-message-cron-task" json:"tplMessageCronTask"`
 }
 
@@ -114,4 +269,6 @@ type WeChatOfficialTplMessageCronTaskConfiguration struct {
 	TemplateId          string `mapstructure:"template-id" json:"templateId"`
 	MiniProgramAppId    string `mapstructure:"mini-program-app-id" json:"miniProgramAppId"`
 	MiniProgramPagePath string `mapstructure:"mini-program-page-path" json:"miniProgramPagePath"`
+	// Data is the keywords of the template, e.g. thing1: xxx
+	Data map[string]string `mapstructure:"data" json:"data"`
 }
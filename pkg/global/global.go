@@ -1,11 +1,20 @@
 package global
 
 import (
+	"sync/atomic"
+
 	"github.com/redis/go-redis/v9"
 	"go.opentelemetry.io/otel/trace"
 	"gorm.io/gorm"
 
+	"github.com/ppxb/oreo-admin-go/pkg/attempt"
+	"github.com/ppxb/oreo-admin-go/pkg/cache"
 	"github.com/ppxb/oreo-admin-go/pkg/config"
+	"github.com/ppxb/oreo-admin-go/pkg/crypt"
+	"github.com/ppxb/oreo-admin-go/pkg/geo"
+	"github.com/ppxb/oreo-admin-go/pkg/job"
+	"github.com/ppxb/oreo-admin-go/pkg/lock"
+	"github.com/ppxb/oreo-admin-go/pkg/wechat"
 )
 
 var (
@@ -14,6 +23,24 @@ var (
 	Conf        Configuration
 	ConfBox     config.ConfBox
 	Tracer      *trace.TracerProvider
-	Mysql       *gorm.DB
 	Redis       redis.UniversalClient
+	Cache       *cache.Cache
+	Attempts    *attempt.Limiter
+	Geo         *geo.Geo
+	Locker      lock.Locker
+	Worker      *job.Worker
+	WeChat      *wechat.Official
+	Keyring     *crypt.Keyring
 )
+
+// mysqlDb is read by requests while the reconnect of a degraded start sets it
+var mysqlDb atomic.Pointer[gorm.DB]
+
+// Mysql is nil until the database is connected
+func Mysql() *gorm.DB {
+	return mysqlDb.Load()
+}
+
+func SetMysql(db *gorm.DB) {
+	mysqlDb.Store(db)
+}
@@ -0,0 +1,175 @@
+package job
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/pkg/errors"
+)
+
+const (
+	StatePending   = "pending"
+	StateActive    = "active"
+	StateScheduled = "scheduled"
+	StateRetry     = "retry"
+	StateArchived  = "archived"
+	StateCompleted = "completed"
+)
+
+var (
+	ErrInvalidState  = errors.New("invalid task state")
+	ErrNotCancelable = errors.New("task is not cancelable")
+)
+
+// Task is a task as shown by the admin api
+type Task struct {
+	Id            string          `json:"id"`
+	Queue         string          `json:"queue"`
+	Type          string          `json:"type"`
+	State         string          `json:"state"`
+	RequestId     string          `json:"requestId"`
+	Payload       json.RawMessage `json:"payload"`
+	MaxRetry      int             `json:"maxRetry"`
+	Retried       int             `json:"retried"`
+	LastErr       string          `json:"lastErr"`
+	LastFailedAt  *time.Time      `json:"lastFailedAt"`
+	NextProcessAt *time.Time      `json:"nextProcessAt"`
+	CompletedAt   *time.Time      `json:"completedAt"`
+}
+
+type Queue struct {
+	Name      string `json:"name"`
+	Priority  int    `json:"priority"`
+	Paused    bool   `json:"paused"`
+	Size      int    `json:"size"`
+	Pending   int    `json:"pending"`
+	Active    int    `json:"active"`
+	Scheduled int    `json:"scheduled"`
+	Retry     int    `json:"retry"`
+	Archived  int    `json:"archived"`
+	Completed int    `json:"completed"`
+	Processed int    `json:"processed"`
+	Failed    int    `json:"failed"`
+}
+
+// Queues returns the queues with their task counts, processed and failed are today's
+func (w *Worker) Queues() ([]Queue, error) {
+	names, err := w.inspector.Queues()
+	if err != nil {
+		return nil, err
+	}
+	list := make([]Queue, 0, len(names))
+	for _, name := range names {
+		info, err := w.inspector.GetQueueInfo(name)
+		if err != nil {
+			return nil, errors.Wrapf(err, "get queue %s", name)
+		}
+		list = append(list, Queue{
+			Name:      info.Queue,
+			Priority:  w.ops.queues[info.Queue],
+			Paused:    info.Paused,
+			Size:      info.Size,
+			Pending:   info.Pending,
+			Active:    info.Active,
+			Scheduled: info.Scheduled,
+			Retry:     info.Retry,
+			Archived:  info.Archived,
+			Completed: info.Completed,
+			Processed: info.Processed,
+			Failed:    info.Failed,
+		})
+	}
+	return list, nil
+}
+
+// ListTasks pages tasks of queue in state, archived tasks are the dead letters
+func (w *Worker) ListTasks(queue, state string, pageNum, pageSize int) ([]Task, error) {
+	opts := []asynq.ListOption{asynq.Page(pageNum), asynq.PageSize(pageSize)}
+	var (
+		infos []*asynq.TaskInfo
+		err   error
+	)
+	switch state {
+	case StatePending:
+		infos, err = w.inspector.ListPendingTasks(queue, opts...)
+	case StateActive:
+		infos, err = w.inspector.ListActiveTasks(queue, opts...)
+	case StateScheduled:
+		infos, err = w.inspector.ListScheduledTasks(queue, opts...)
+	case StateRetry:
+		infos, err = w.inspector.ListRetryTasks(queue, opts...)
+	case StateArchived:
+		infos, err = w.inspector.ListArchivedTasks(queue, opts...)
+	case StateCompleted:
+		infos, err = w.inspector.ListCompletedTasks(queue, opts...)
+	default:
+		return nil, errors.Wrapf(ErrInvalidState, "state: %s", state)
+	}
+	if err != nil {
+		return nil, err
+	}
+	list := make([]Task, 0, len(infos))
+	for _, info := range infos {
+		list = append(list, newTask(info))
+	}
+	return list, nil
+}
+
+func (w *Worker) GetTask(queue, id string) (Task, error) {
+	info, err := w.inspector.GetTaskInfo(queue, id)
+	if err != nil {
+		return Task{}, err
+	}
+	return newTask(info), nil
+}
+
+// RetryTask runs a scheduled, retry or archived task now
+func (w *Worker) RetryTask(queue, id string) error {
+	return w.inspector.RunTask(queue, id)
+}
+
+// CancelTask signals an active task to stop, waiting tasks are archived so that they can still be retried
+func (w *Worker) CancelTask(queue, id string) error {
+	info, err := w.inspector.GetTaskInfo(queue, id)
+	if err != nil {
+		return err
+	}
+	switch info.State {
+	case asynq.TaskStateActive:
+		return w.inspector.CancelProcessing(id)
+	case asynq.TaskStatePending, asynq.TaskStateScheduled, asynq.TaskStateRetry:
+		return w.inspector.ArchiveTask(queue, id)
+	}
+	return errors.Wrapf(ErrNotCancelable, "state: %s", info.State)
+}
+
+func newTask(info *asynq.TaskInfo) Task {
+	t := Task{
+		Id:       info.ID,
+		Queue:    info.Queue,
+		Type:     info.Type,
+		State:    info.State.String(),
+		MaxRetry: info.MaxRetry,
+		Retried:  info.Retried,
+		LastErr:  info.LastErr,
+	}
+	var e envelope
+	if json.Unmarshal(info.Payload, &e) == nil && e.Payload != nil {
+		t.RequestId = e.RequestId
+		t.Payload = e.Payload
+	} else if json.Valid(info.Payload) {
+		t.Payload = info.Payload
+	}
+	t.LastFailedAt = timeOrNil(info.LastFailedAt)
+	t.NextProcessAt = timeOrNil(info.NextProcessAt)
+	t.CompletedAt = timeOrNil(info.CompletedAt)
+	return t
+}
+
+func timeOrNil(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
@@ -0,0 +1,91 @@
+package job
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/hibiken/asynq"
+	"github.com/pkg/errors"
+
+	"github.com/ppxb/oreo-admin-go/pkg/tracing"
+)
+
+type handlerFunc func(ctx context.Context, payload json.RawMessage) error
+
+var (
+	handlers    = make(map[string]handlerFunc)
+	handlerLock sync.RWMutex
+)
+
+// envelope carries the request id of the enqueuer so that logs of the task share it
+type envelope struct {
+	RequestId string          `json:"requestId,omitempty"`
+	Payload   json.RawMessage `json:"payload"`
+}
+
+// Register adds a typed handler of typ, usually called from init()
+func Register[T any](typ string, fn func(ctx context.Context, payload T) error) {
+	handlerLock.Lock()
+	defer handlerLock.Unlock()
+
+	if typ == "" || fn == nil {
+		panic("job type and handler are required")
+	}
+	if _, ok := handlers[typ]; ok {
+		panic(errors.Errorf("job %s is registered twice", typ))
+	}
+	handlers[typ] = func(ctx context.Context, raw json.RawMessage) error {
+		var payload T
+		if err := json.Unmarshal(raw, &payload); err != nil {
+			// a payload that can not be decoded never succeeds
+			return errors.Wrapf(asynq.SkipRetry, "decode payload of %s: %v", typ, err)
+		}
+		return fn(ctx, payload)
+	}
+}
+
+func registeredHandlers() map[string]handlerFunc {
+	handlerLock.RLock()
+	defer handlerLock.RUnlock()
+
+	m := make(map[string]handlerFunc, len(handlers))
+	for k, v := range handlers {
+		m[k] = v
+	}
+	return m
+}
+
+// NewTask encodes payload as a task of typ
+func NewTask[T any](ctx context.Context, typ string, payload T) (*asynq.Task, error) {
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return nil, errors.Wrapf(err, "encode payload of %s", typ)
+	}
+	e := envelope{Payload: raw}
+	e.RequestId, _, _ = tracing.GetId(ctx)
+	b, err := json.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+	return asynq.NewTask(typ, b), nil
+}
+
+// Enqueue creates and enqueues a task of typ, options are asynq options such as asynq.Queue or asynq.ProcessIn,
+// it is sent to QueueDefault unless asynq.Queue is given
+func Enqueue[T any](ctx context.Context, w *Worker, typ string, payload T, opts ...asynq.Option) (*asynq.TaskInfo, error) {
+	task, err := NewTask(ctx, typ, payload)
+	if err != nil {
+		return nil, err
+	}
+	return w.Enqueue(ctx, task, opts...)
+}
+
+// Schedule enqueues a task of typ on the cron spec, e.g. "*/5 * * * *" or "@every 1h"
+func Schedule[T any](w *Worker, spec, typ string, payload T, opts ...asynq.Option) error {
+	task, err := NewTask(context.Background(), typ, payload)
+	if err != nil {
+		return err
+	}
+	return w.Schedule(spec, task, opts...)
+}
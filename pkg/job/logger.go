@@ -0,0 +1,37 @@
+package job
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ppxb/oreo-admin-go/pkg/log"
+)
+
+// logger adapts asynq's logs to pkg/log
+type logger struct {
+	ctx context.Context
+}
+
+func newLogger(ctx context.Context) logger {
+	return logger{ctx: ctx}
+}
+
+func (l logger) Debug(args ...interface{}) {
+	log.WithContext(l.ctx).WithComponent("asynq").Debug(fmt.Sprint(args...))
+}
+
+func (l logger) Info(args ...interface{}) {
+	log.WithContext(l.ctx).WithComponent("asynq").Info(fmt.Sprint(args...))
+}
+
+func (l logger) Warn(args ...interface{}) {
+	log.WithContext(l.ctx).WithComponent("asynq").Warn(fmt.Sprint(args...))
+}
+
+func (l logger) Error(args ...interface{}) {
+	log.WithContext(l.ctx).WithComponent("asynq").Error(fmt.Sprint(args...))
+}
+
+func (l logger) Fatal(args ...interface{}) {
+	log.WithContext(l.ctx).WithComponent("asynq").Fatal(fmt.Sprint(args...))
+}
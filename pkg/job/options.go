@@ -0,0 +1,126 @@
+package job
+
+import (
+	"context"
+	"time"
+
+	"github.com/ppxb/oreo-admin-go/pkg/lock"
+	"github.com/ppxb/oreo-admin-go/pkg/utils"
+)
+
+const (
+	QueueCritical = "critical"
+	QueueDefault  = "default"
+	QueueLow      = "low"
+)
+
+type Options struct {
+	ctx             context.Context
+	uri             string
+	locker          lock.Locker
+	concurrency     int
+	queues          map[string]int
+	maxRetry        int
+	retryDelay      time.Duration
+	maxRetryDelay   time.Duration
+	shutdownTimeout time.Duration
+	location        *time.Location
+}
+
+func WithCtx(ctx context.Context) func(*Options) {
+	return func(options *Options) {
+		if !utils.InterfaceIsNil(ctx) {
+			getOptionsOrSetDefault(options).ctx = ctx
+		}
+	}
+}
+
+// WithUri is the redis uri parsed by asynq.ParseRedisURI like query.ParseRedisURI
+func WithUri(s string) func(*Options) {
+	return func(options *Options) {
+		getOptionsOrSetDefault(options).uri = s
+	}
+}
+
+// WithLocker elects the node running the scheduler, without it every node enqueues periodic tasks
+func WithLocker(l lock.Locker) func(*Options) {
+	return func(options *Options) {
+		if !utils.InterfaceIsNil(l) {
+			getOptionsOrSetDefault(options).locker = l
+		}
+	}
+}
+
+func WithConcurrency(n int) func(*Options) {
+	return func(options *Options) {
+		if n > 0 {
+			getOptionsOrSetDefault(options).concurrency = n
+		}
+	}
+}
+
+// WithQueues maps queue names to priorities, a queue with priority 6 is processed 6 times as often as one with 1
+func WithQueues(queues map[string]int) func(*Options) {
+	return func(options *Options) {
+		if len(queues) > 0 {
+			getOptionsOrSetDefault(options).queues = queues
+		}
+	}
+}
+
+// WithMaxRetry is the default retry count of tasks, exhausted tasks are archived(dead letter)
+func WithMaxRetry(n int) func(*Options) {
+	return func(options *Options) {
+		if n > 0 {
+			getOptionsOrSetDefault(options).maxRetry = n
+		}
+	}
+}
+
+// WithRetryDelay sets the exponential backoff, the n-th retry waits delay*2^n with jitter, at most max
+func WithRetryDelay(delay, max time.Duration) func(*Options) {
+	return func(options *Options) {
+		if delay > 0 && max >= delay {
+			getOptionsOrSetDefault(options).retryDelay = delay
+			getOptionsOrSetDefault(options).maxRetryDelay = max
+		}
+	}
+}
+
+// WithShutdownTimeout is how long running tasks may finish on shutdown before they are requeued
+func WithShutdownTimeout(d time.Duration) func(*Options) {
+	return func(options *Options) {
+		if d > 0 {
+			getOptionsOrSetDefault(options).shutdownTimeout = d
+		}
+	}
+}
+
+// WithLocation is the time zone of cron specs
+func WithLocation(loc *time.Location) func(*Options) {
+	return func(options *Options) {
+		if loc != nil {
+			getOptionsOrSetDefault(options).location = loc
+		}
+	}
+}
+
+func getOptionsOrSetDefault(options *Options) *Options {
+	if options == nil {
+		return &Options{
+			ctx:         context.Background(),
+			concurrency: 10,
+			queues: map[string]int{
+				QueueCritical: 6,
+				QueueDefault:  3,
+				QueueLow:      1,
+			},
+			maxRetry:        10,
+			retryDelay:      10 * time.Second,
+			maxRetryDelay:   time.Hour,
+			shutdownTimeout: 30 * time.Second,
+			location:        time.Local,
+		}
+	}
+	return options
+}
@@ -0,0 +1,250 @@
+package job
+
+import (
+	"context"
+	"encoding/json"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/pkg/errors"
+	"github.com/robfig/cron/v3"
+
+	"github.com/ppxb/oreo-admin-go/pkg/constant"
+	"github.com/ppxb/oreo-admin-go/pkg/log"
+	"github.com/ppxb/oreo-admin-go/pkg/metrics"
+	"github.com/ppxb/oreo-admin-go/pkg/tracing"
+)
+
+const (
+	schedulerLockName  = "job_scheduler"
+	schedulerLockRetry = 5 * time.Second
+)
+
+// Worker processes registered tasks and runs the scheduler of periodic tasks
+type Worker struct {
+	ops       Options
+	redis     asynq.RedisConnOpt
+	server    *asynq.Server
+	client    *asynq.Client
+	inspector *asynq.Inspector
+
+	lock      sync.Mutex
+	entries   []scheduleEntry
+	scheduler *asynq.Scheduler
+	cancel    context.CancelFunc
+	done      chan struct{}
+}
+
+type scheduleEntry struct {
+	spec string
+	task *asynq.Task
+	opts []asynq.Option
+}
+
+func New(options ...func(*Options)) (*Worker, error) {
+	ops := getOptionsOrSetDefault(nil)
+	for _, f := range options {
+		f(ops)
+	}
+	opt, err := asynq.ParseRedisURI(ops.uri)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid redis uri")
+	}
+	w := &Worker{
+		ops:       *ops,
+		redis:     opt,
+		client:    asynq.NewClient(opt),
+		inspector: asynq.NewInspector(opt),
+	}
+	w.server = asynq.NewServer(opt, asynq.Config{
+		Concurrency:     ops.concurrency,
+		Queues:          ops.queues,
+		RetryDelayFunc:  w.retryDelay,
+		ShutdownTimeout: ops.shutdownTimeout,
+		Logger:          newLogger(ops.ctx),
+	})
+	return w, nil
+}
+
+// Start processes tasks in background until Shutdown
+func (w *Worker) Start() error {
+	mux := asynq.NewServeMux()
+	for typ, fn := range registeredHandlers() {
+		mux.HandleFunc(typ, w.wrap(fn))
+	}
+	if err := w.server.Start(mux); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(w.ops.ctx)
+	w.cancel = cancel
+	w.done = make(chan struct{})
+	go w.schedule(ctx)
+	return nil
+}
+
+// Shutdown stops the scheduler and waits for running tasks at most the shutdown timeout
+func (w *Worker) Shutdown() {
+	if w.cancel != nil {
+		w.cancel()
+		<-w.done
+	}
+	w.server.Shutdown()
+	if err := w.client.Close(); err != nil {
+		log.WithContext(w.ops.ctx).WithError(err).WithComponent("job").Warn("Close client failed")
+	}
+	if err := w.inspector.Close(); err != nil {
+		log.WithContext(w.ops.ctx).WithError(err).WithComponent("job").Warn("Close inspector failed")
+	}
+}
+
+func (w *Worker) Enqueue(ctx context.Context, task *asynq.Task, opts ...asynq.Option) (*asynq.TaskInfo, error) {
+	info, err := w.client.EnqueueContext(ctx, task, w.withDefaults(opts)...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "enqueue %s", task.Type())
+	}
+	log.WithContext(ctx).WithComponent("job").Debug("Task enqueued", "type", task.Type(), "taskId", info.ID, "queue", info.Queue)
+	return info, nil
+}
+
+// Schedule adds a periodic task, it is enqueued by the node elected by the locker
+func (w *Worker) Schedule(spec string, task *asynq.Task, opts ...asynq.Option) error {
+	parser := cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+	if _, err := parser.Parse(spec); err != nil {
+		return errors.Wrapf(err, "invalid cron spec %s of %s", spec, task.Type())
+	}
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	item := scheduleEntry{spec: spec, task: task, opts: opts}
+	w.entries = append(w.entries, item)
+	if w.scheduler != nil {
+		return w.register(w.scheduler, item)
+	}
+	return nil
+}
+
+func (w *Worker) register(s *asynq.Scheduler, item scheduleEntry) error {
+	if _, err := s.Register(item.spec, item.task, w.withDefaults(item.opts)...); err != nil {
+		return errors.Wrapf(err, "schedule %s", item.task.Type())
+	}
+	return nil
+}
+
+// withDefaults puts the default max retry first so that an explicit asynq.MaxRetry wins
+func (w *Worker) withDefaults(opts []asynq.Option) []asynq.Option {
+	return append([]asynq.Option{asynq.MaxRetry(w.ops.maxRetry)}, opts...)
+}
+
+// schedule runs the scheduler while this node holds the scheduler lock
+func (w *Worker) schedule(ctx context.Context) {
+	defer close(w.done)
+	if w.ops.locker == nil {
+		w.runScheduler(ctx, nil)
+		return
+	}
+	for {
+		lease, err := w.ops.locker.Lock(ctx, schedulerLockName)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			log.WithContext(ctx).WithError(err).WithComponent("job").Warn("Acquire scheduler lock failed", "retryIn", schedulerLockRetry)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(schedulerLockRetry):
+			}
+			continue
+		}
+		w.runScheduler(ctx, lease.Lost())
+		if err = lease.Unlock(context.Background()); err != nil {
+			log.WithContext(ctx).WithError(err).WithComponent("job").Debug("Release scheduler lock failed")
+		}
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+func (w *Worker) runScheduler(ctx context.Context, lost <-chan struct{}) {
+	s := asynq.NewScheduler(w.redis, &asynq.SchedulerOpts{
+		Location: w.ops.location,
+		Logger:   newLogger(ctx),
+		EnqueueErrorHandler: func(task *asynq.Task, _ []asynq.Option, err error) {
+			log.WithContext(ctx).WithError(err).WithComponent("job").Error("Enqueue periodic task failed", "type", task.Type())
+		},
+	})
+	w.lock.Lock()
+	for _, item := range w.entries {
+		if err := w.register(s, item); err != nil {
+			log.WithContext(ctx).WithError(err).WithComponent("job").Error("Register periodic task failed")
+		}
+	}
+	w.scheduler = s
+	w.lock.Unlock()
+
+	if err := s.Start(); err != nil {
+		log.WithContext(ctx).WithError(err).WithComponent("job").Error("Start scheduler failed")
+	} else {
+		log.WithContext(ctx).WithComponent("job").Info("Scheduler started", "periodicTasks", len(w.entries))
+	}
+	select {
+	case <-ctx.Done():
+	case <-lost:
+		log.WithContext(ctx).WithComponent("job").Warn("Scheduler lock is lost, stop scheduler")
+	}
+
+	w.lock.Lock()
+	w.scheduler = nil
+	w.lock.Unlock()
+	s.Shutdown()
+}
+
+// wrap decodes the envelope and logs the task with the request id of the enqueuer
+func (w *Worker) wrap(fn handlerFunc) asynq.HandlerFunc {
+	return func(ctx context.Context, task *asynq.Task) error {
+		var e envelope
+		if err := json.Unmarshal(task.Payload(), &e); err != nil {
+			return errors.Wrapf(asynq.SkipRetry, "decode task %s: %v", task.Type(), err)
+		}
+		if e.RequestId != "" {
+			ctx = context.WithValue(ctx, constant.MiddlewareRequestIdCtxKey, e.RequestId)
+		}
+		ctx = tracing.NewId(ctx)
+
+		id, _ := asynq.GetTaskID(ctx)
+		retried, _ := asynq.GetRetryCount(ctx)
+		maxRetry, _ := asynq.GetMaxRetry(ctx)
+		start := time.Now()
+		log.WithContext(ctx).WithComponent("job").Debug("Task started", "type", task.Type(), "taskId", id, "retried", retried, "maxRetry", maxRetry)
+
+		err := fn(ctx, e.Payload)
+		elapsed := time.Since(start).Round(time.Millisecond)
+		status := "ok"
+		switch {
+		case err == nil:
+			log.WithContext(ctx).WithComponent("job").Info("Task finished", "type", task.Type(), "taskId", id, "elapsed", elapsed)
+		case errors.Is(err, asynq.SkipRetry) || retried >= maxRetry:
+			status = "archived"
+			log.WithContext(ctx).WithError(err).WithComponent("job").Error("Task failed, archived", "type", task.Type(), "taskId", id, "elapsed", elapsed)
+		default:
+			status = "retry"
+			log.WithContext(ctx).WithError(err).WithComponent("job").Warn("Task failed, retry later", "type", task.Type(), "taskId", id, "elapsed", elapsed, "retried", retried, "maxRetry", maxRetry)
+		}
+		metrics.JobDuration.WithLabelValues(task.Type(), status).Observe(time.Since(start).Seconds())
+		return err
+	}
+}
+
+// retryDelay is an exponential backoff with jitter
+func (w *Worker) retryDelay(n int, _ error, _ *asynq.Task) time.Duration {
+	d := w.ops.maxRetryDelay
+	if n < 32 {
+		if item := w.ops.retryDelay << uint(n); item > 0 && item < d {
+			d = item
+		}
+	}
+	return d/2 + time.Duration(rand.Int64N(int64(d/2)+1))
+}
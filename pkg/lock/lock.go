@@ -0,0 +1,53 @@
+package lock
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrNotAcquired = errors.New("lock is held by another owner")
+	ErrNotHeld     = errors.New("lock is not held anymore")
+)
+
+// Locker acquires named locks across processes, the redis and mysql implementations are interchangeable
+type Locker interface {
+	// TryLock acquires name without waiting, ErrNotAcquired if it is held
+	TryLock(ctx context.Context, name string) (Lease, error)
+	// Lock waits until name is acquired or ctx is done
+	Lock(ctx context.Context, name string) (Lease, error)
+}
+
+// Lease is a held lock
+type Lease interface {
+	// Token is the fencing token, it is greater than the token of every earlier lease of the same name,
+	// storage written under the lock should reject writes with a token lower than the last one seen
+	Token() uint64
+	// Lost is closed when the lock can not be kept anymore, the holder must stop its work
+	Lost() <-chan struct{}
+	// Unlock releases the lock, ErrNotHeld if it was lost meanwhile
+	Unlock(ctx context.Context) error
+}
+
+// wait retries try until the lock is acquired or ctx is done
+func wait(ctx context.Context, interval time.Duration, try func() (Lease, error)) (Lease, error) {
+	for {
+		lease, err := try()
+		if !errors.Is(err, ErrNotAcquired) {
+			return lease, err
+		}
+		// jitter so that waiters do not retry in lockstep
+		d := interval
+		if interval > 0 {
+			d += time.Duration(rand.Int64N(int64(interval)))
+		}
+		select {
+		case <-ctx.Done():
+			return nil, errors.Wrap(ctx.Err(), "wait for lock")
+		case <-time.After(d):
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package lock
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/ppxb/oreo-admin-go/pkg/log"
+)
+
+// lockAttemptSeconds is the GET_LOCK wait of a single attempt, kept short so that ctx is checked often
+const lockAttemptSeconds = 1
+
+type mysqlLocker struct {
+	ops     Options
+	db      *sql.DB
+	lock    sync.Mutex
+	created bool
+}
+
+type mysqlLease struct {
+	ctx   context.Context
+	ops   Options
+	conn  *sql.Conn
+	key   string
+	token uint64
+	lost  chan struct{}
+	stop  chan struct{}
+	done  chan struct{}
+	once  sync.Once
+}
+
+// NewMysql returns a locker based on GET_LOCK, each lease pins a connection because the lock
+// belongs to the session, it is lost when the connection breaks
+func NewMysql(db *sql.DB, options ...func(*Options)) (Locker, error) {
+	ops := getOptionsOrSetDefault(nil)
+	for _, f := range options {
+		f(ops)
+	}
+	if db == nil {
+		return nil, errors.New("mysql db is empty")
+	}
+	return &mysqlLocker{ops: *ops, db: db}, nil
+}
+
+func (l *mysqlLocker) TryLock(ctx context.Context, name string) (Lease, error) {
+	return l.acquire(ctx, name, 0)
+}
+
+func (l *mysqlLocker) Lock(ctx context.Context, name string) (Lease, error) {
+	// GET_LOCK waits itself, so there is no extra retry interval
+	return wait(ctx, 0, func() (Lease, error) {
+		return l.acquire(ctx, name, lockAttemptSeconds)
+	})
+}
+
+func (l *mysqlLocker) acquire(ctx context.Context, name string, timeout int) (Lease, error) {
+	if err := l.createTable(ctx); err != nil {
+		return nil, err
+	}
+	conn, err := l.db.Conn(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "get lock connection")
+	}
+	key := l.ops.prefix + ":" + name
+	var acquired sql.NullBool
+	if err = conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", key, timeout).Scan(&acquired); err != nil {
+		_ = conn.Close()
+		return nil, errors.Wrapf(err, "acquire lock %s", name)
+	}
+	if !acquired.Valid || !acquired.Bool {
+		_ = conn.Close()
+		return nil, errors.Wrapf(ErrNotAcquired, "lock: %s", name)
+	}
+
+	lease := &mysqlLease{
+		ctx:  context.WithoutCancel(ctx),
+		ops:  l.ops,
+		conn: conn,
+		key:  key,
+		lost: make(chan struct{}),
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	if lease.token, err = l.nextToken(ctx, conn, name); err != nil {
+		close(lease.done)
+		_ = lease.Unlock(context.Background())
+		return nil, errors.Wrapf(err, "issue fencing token of lock %s", name)
+	}
+	go lease.keepalive()
+	return lease, nil
+}
+
+// nextToken increments the token of name, only the holder does so that tokens follow acquisitions
+func (l *mysqlLocker) nextToken(ctx context.Context, conn *sql.Conn, name string) (uint64, error) {
+	_, err := conn.ExecContext(
+		ctx,
+		fmt.Sprintf("INSERT INTO `%s` (name, token) VALUES (?, LAST_INSERT_ID(1)) ON DUPLICATE KEY UPDATE token = LAST_INSERT_ID(token + 1)", l.ops.table),
+		name,
+	)
+	if err != nil {
+		return 0, err
+	}
+	var token uint64
+	err = conn.QueryRowContext(ctx, "SELECT LAST_INSERT_ID()").Scan(&token)
+	return token, err
+}
+
+func (l *mysqlLocker) createTable(ctx context.Context) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.created {
+		return nil
+	}
+	_, err := l.db.ExecContext(
+		ctx,
+		fmt.Sprintf("CREATE TABLE IF NOT EXISTS `%s` (name VARCHAR(191) NOT NULL PRIMARY KEY, token BIGINT UNSIGNED NOT NULL)", l.ops.table),
+	)
+	if err != nil {
+		return errors.Wrapf(err, "create lock token table %s", l.ops.table)
+	}
+	l.created = true
+	return nil
+}
+
+func (le *mysqlLease) Token() uint64 {
+	return le.token
+}
+
+func (le *mysqlLease) Lost() <-chan struct{} {
+	return le.lost
+}
+
+func (le *mysqlLease) Unlock(ctx context.Context) error {
+	le.once.Do(func() {
+		close(le.stop)
+	})
+	<-le.done
+	defer le.conn.Close()
+	var released sql.NullInt64
+	if err := le.conn.QueryRowContext(ctx, "SELECT RELEASE_LOCK(?)", le.key).Scan(&released); err != nil {
+		return errors.Wrapf(err, "release lock %s", le.key)
+	}
+	if !released.Valid || released.Int64 != 1 {
+		return errors.Wrapf(ErrNotHeld, "lock: %s", le.key)
+	}
+	return nil
+}
+
+// keepalive pings the pinned connection every ttl/3, the lock is gone with the session
+func (le *mysqlLease) keepalive() {
+	defer close(le.done)
+	interval := le.ops.ttl / 3
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-le.stop:
+			return
+		case <-ticker.C:
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		err := le.conn.PingContext(ctx)
+		cancel()
+		if err != nil {
+			log.WithContext(le.ctx).WithError(err).WithComponent("lock").Warn("Connection of lock is broken, lease is lost", "lock", le.key)
+			close(le.lost)
+			return
+		}
+	}
+}
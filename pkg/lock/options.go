@@ -0,0 +1,74 @@
+package lock
+
+import (
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/ppxb/oreo-admin-go/pkg/utils"
+)
+
+type Options struct {
+	redis         redis.UniversalClient
+	prefix        string
+	table         string
+	ttl           time.Duration
+	retryInterval time.Duration
+}
+
+func WithRedis(rd redis.UniversalClient) func(*Options) {
+	return func(options *Options) {
+		if !utils.InterfaceIsNil(rd) {
+			getOptionsOrSetDefault(options).redis = rd
+		}
+	}
+}
+
+// WithPrefix namespaces lock names, mysql limits prefix + name to 64 characters
+func WithPrefix(s string) func(*Options) {
+	return func(options *Options) {
+		if s != "" {
+			getOptionsOrSetDefault(options).prefix = s
+		}
+	}
+}
+
+// WithTable is the fencing token table of the mysql locker, created if not exists
+func WithTable(s string) func(*Options) {
+	return func(options *Options) {
+		if s != "" {
+			getOptionsOrSetDefault(options).table = s
+		}
+	}
+}
+
+// WithTtl is the lease of the redis locker, it is renewed every ttl/3 while held
+// so that a crashed holder releases the lock after ttl at most
+func WithTtl(ttl time.Duration) func(*Options) {
+	return func(options *Options) {
+		if ttl > 0 {
+			getOptionsOrSetDefault(options).ttl = ttl
+		}
+	}
+}
+
+// WithRetryInterval is how often Lock retries a held lock
+func WithRetryInterval(d time.Duration) func(*Options) {
+	return func(options *Options) {
+		if d > 0 {
+			getOptionsOrSetDefault(options).retryInterval = d
+		}
+	}
+}
+
+func getOptionsOrSetDefault(options *Options) *Options {
+	if options == nil {
+		return &Options{
+			prefix:        "lock",
+			table:         "lock_token",
+			ttl:           30 * time.Second,
+			retryInterval: 100 * time.Millisecond,
+		}
+	}
+	return options
+}
@@ -0,0 +1,150 @@
+package lock
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/ppxb/oreo-admin-go/pkg/log"
+)
+
+var (
+	// the token only increases when the lock is acquired, both keys share a hash tag for redis cluster
+	acquireScript = redis.NewScript(`
+if redis.call('SET', KEYS[1], ARGV[1], 'NX', 'PX', ARGV[2]) then
+	return redis.call('INCR', KEYS[2])
+end
+return 0
+`)
+	renewScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0
+`)
+	releaseScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+)
+
+type redisLocker struct {
+	ops Options
+}
+
+type redisLease struct {
+	ctx   context.Context
+	ops   Options
+	key   string
+	owner string
+	token uint64
+	lost  chan struct{}
+	stop  chan struct{}
+	done  chan struct{}
+	once  sync.Once
+}
+
+// NewRedis returns a locker based on SET NX PX, leases are renewed in background until Unlock
+func NewRedis(options ...func(*Options)) (Locker, error) {
+	ops := getOptionsOrSetDefault(nil)
+	for _, f := range options {
+		f(ops)
+	}
+	if ops.redis == nil {
+		return nil, errors.New("redis client is empty")
+	}
+	return &redisLocker{ops: *ops}, nil
+}
+
+func (l *redisLocker) TryLock(ctx context.Context, name string) (Lease, error) {
+	key := l.ops.prefix + ":{" + name + "}"
+	owner := uuid.NewString()
+	token, err := acquireScript.Run(ctx, l.ops.redis, []string{key, key + ":token"}, owner, l.ops.ttl.Milliseconds()).Uint64()
+	if err != nil {
+		return nil, errors.Wrapf(err, "acquire lock %s", name)
+	}
+	if token == 0 {
+		return nil, errors.Wrapf(ErrNotAcquired, "lock: %s", name)
+	}
+	lease := &redisLease{
+		ctx:   context.WithoutCancel(ctx),
+		ops:   l.ops,
+		key:   key,
+		owner: owner,
+		token: token,
+		lost:  make(chan struct{}),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	go lease.renew()
+	return lease, nil
+}
+
+func (l *redisLocker) Lock(ctx context.Context, name string) (Lease, error) {
+	return wait(ctx, l.ops.retryInterval, func() (Lease, error) {
+		return l.TryLock(ctx, name)
+	})
+}
+
+func (le *redisLease) Token() uint64 {
+	return le.token
+}
+
+func (le *redisLease) Lost() <-chan struct{} {
+	return le.lost
+}
+
+func (le *redisLease) Unlock(ctx context.Context) error {
+	le.once.Do(func() {
+		close(le.stop)
+	})
+	<-le.done
+	n, err := releaseScript.Run(ctx, le.ops.redis, []string{le.key}, le.owner).Int64()
+	if err != nil {
+		return errors.Wrapf(err, "release lock %s", le.key)
+	}
+	if n == 0 {
+		return errors.Wrapf(ErrNotHeld, "lock: %s", le.key)
+	}
+	return nil
+}
+
+// renew extends the lease every ttl/3, errors are retried until the lease would have expired
+func (le *redisLease) renew() {
+	defer close(le.done)
+	interval := le.ops.ttl / 3
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	renewed := time.Now()
+	for {
+		select {
+		case <-le.stop:
+			return
+		case <-ticker.C:
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		n, err := renewScript.Run(ctx, le.ops.redis, []string{le.key}, le.owner, le.ops.ttl.Milliseconds()).Int64()
+		cancel()
+		if err == nil && n == 1 {
+			renewed = time.Now()
+			continue
+		}
+		if err == nil {
+			log.WithContext(le.ctx).WithComponent("lock").Warn("Lock expired before renewal, lease is lost", "lock", le.key)
+			close(le.lost)
+			return
+		}
+		if time.Since(renewed) >= le.ops.ttl {
+			log.WithContext(le.ctx).WithError(err).WithComponent("lock").Warn("Renew lock failed until expiration, lease is lost", "lock", le.key)
+			close(le.lost)
+			return
+		}
+		log.WithContext(le.ctx).WithError(err).WithComponent("lock").Warn("Renew lock failed, retry", "lock", le.key)
+	}
+}
@@ -0,0 +1,199 @@
+package lock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/pkg/errors"
+	"github.com/redis/go-redis/v9"
+)
+
+const testTtl = 300 * time.Millisecond
+
+func newRedisLocker(t *testing.T) (Locker, *miniredis.Miniredis) {
+	mr := miniredis.RunT(t)
+	rd := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rd.Close() })
+	l, err := NewRedis(WithRedis(rd), WithTtl(testTtl), WithRetryInterval(10*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l, mr
+}
+
+func TestRedisTryLockContention(t *testing.T) {
+	l, _ := newRedisLocker(t)
+	ctx := context.Background()
+
+	a, err := l.TryLock(ctx, "job")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = l.TryLock(ctx, "job"); !errors.Is(err, ErrNotAcquired) {
+		t.Fatalf("second TryLock err = %v, want ErrNotAcquired", err)
+	}
+	// names are independent
+	other, err := l.TryLock(ctx, "other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Unlock(ctx)
+
+	if err = a.Unlock(ctx); err != nil {
+		t.Fatal(err)
+	}
+	b, err := l.TryLock(ctx, "job")
+	if err != nil {
+		t.Fatalf("TryLock after Unlock: %v", err)
+	}
+	defer b.Unlock(ctx)
+}
+
+func TestRedisLockWaits(t *testing.T) {
+	l, _ := newRedisLocker(t)
+	ctx := context.Background()
+
+	a, err := l.TryLock(ctx, "job")
+	if err != nil {
+		t.Fatal(err)
+	}
+	acquired := make(chan Lease, 1)
+	go func() {
+		lease, err := l.Lock(ctx, "job")
+		if err != nil {
+			t.Error(err)
+		}
+		acquired <- lease
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("Lock returned while the lock is held")
+	case <-time.After(100 * time.Millisecond):
+	}
+	if err = a.Unlock(ctx); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case b := <-acquired:
+		if b.Token() <= a.Token() {
+			t.Errorf("token %d is not greater than %d", b.Token(), a.Token())
+		}
+		_ = b.Unlock(ctx)
+	case <-time.After(time.Second):
+		t.Fatal("Lock did not acquire the released lock")
+	}
+}
+
+func TestRedisLockCanceled(t *testing.T) {
+	l, _ := newRedisLocker(t)
+	a, err := l.TryLock(context.Background(), "job")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Unlock(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err = l.Lock(ctx, "job"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want deadline exceeded", err)
+	}
+}
+
+func TestRedisTokensIncrease(t *testing.T) {
+	l, _ := newRedisLocker(t)
+	ctx := context.Background()
+	var last uint64
+	for i := 0; i < 5; i++ {
+		lease, err := l.TryLock(ctx, "job")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if lease.Token() <= last {
+			t.Fatalf("token %d after %d", lease.Token(), last)
+		}
+		last = lease.Token()
+		if err = lease.Unlock(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestRedisLeaseRenewed(t *testing.T) {
+	l, mr := newRedisLocker(t)
+	ctx := context.Background()
+	lease, err := l.TryLock(ctx, "job")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// without renewal the key expires after the second step
+	for i := 0; i < 5; i++ {
+		time.Sleep(testTtl / 2)
+		mr.FastForward(testTtl / 2)
+	}
+	if !mr.Exists("lock:{job}") {
+		t.Fatal("lock expired while held")
+	}
+	select {
+	case <-lease.Lost():
+		t.Fatal("lease is lost while renewed")
+	default:
+	}
+	if err = lease.Unlock(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if mr.Exists("lock:{job}") {
+		t.Fatal("lock is not released")
+	}
+}
+
+func TestRedisLeaseLost(t *testing.T) {
+	tests := []struct {
+		name string
+		lose func(mr *miniredis.Miniredis)
+	}{
+		{"deleted", func(mr *miniredis.Miniredis) { mr.Del("lock:{job}") }},
+		{"expired", func(mr *miniredis.Miniredis) { mr.FastForward(testTtl) }},
+		{"taken over", func(mr *miniredis.Miniredis) { _ = mr.Set("lock:{job}", "another owner") }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, mr := newRedisLocker(t)
+			ctx := context.Background()
+			lease, err := l.TryLock(ctx, "job")
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.lose(mr)
+			select {
+			case <-lease.Lost():
+			case <-time.After(time.Second):
+				t.Fatal("Lost is not closed")
+			}
+			if err = lease.Unlock(ctx); !errors.Is(err, ErrNotHeld) {
+				t.Fatalf("Unlock err = %v, want ErrNotHeld", err)
+			}
+		})
+	}
+}
+
+func TestRedisLeaseLostWhenRedisIsDown(t *testing.T) {
+	l, mr := newRedisLocker(t)
+	lease, err := l.TryLock(context.Background(), "job")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mr.Close()
+	start := time.Now()
+	select {
+	case <-lease.Lost():
+	case <-time.After(time.Second):
+		t.Fatal("Lost is not closed")
+	}
+	// renewal errors are retried until the lease would have expired
+	if elapsed := time.Since(start); elapsed < testTtl*2/3 {
+		t.Fatalf("lease is lost after %v, before the ttl", elapsed)
+	}
+}
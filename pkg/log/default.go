@@ -17,28 +17,70 @@ func NewDefaultWrapper() *Wrapper {
 	return DefaultWrapper
 }
 
-func Trace(args ...interface{}) {
-	DefaultWrapper.Trace(args...)
+func Trace(msg string, keyvals ...interface{}) {
+	DefaultWrapper.Trace(msg, keyvals...)
 }
 
-func Debug(args ...interface{}) {
-	DefaultWrapper.Debug(args...)
+func Debug(msg string, keyvals ...interface{}) {
+	DefaultWrapper.Debug(msg, keyvals...)
 }
 
-func Info(args ...interface{}) {
-	DefaultWrapper.Info(args...)
+func Info(msg string, keyvals ...interface{}) {
+	DefaultWrapper.Info(msg, keyvals...)
 }
 
-func Warn(args ...interface{}) {
-	DefaultWrapper.Warn(args...)
+func Warn(msg string, keyvals ...interface{}) {
+	DefaultWrapper.Warn(msg, keyvals...)
 }
 
-func Error(args ...interface{}) {
-	DefaultWrapper.Error(args...)
+func Error(msg string, keyvals ...interface{}) {
+	DefaultWrapper.Error(msg, keyvals...)
 }
 
-func Fatal(args ...interface{}) {
-	DefaultWrapper.Fatal(args...)
+func Fatal(msg string, keyvals ...interface{}) {
+	DefaultWrapper.Fatal(msg, keyvals...)
+}
+
+func Panic(msg string, keyvals ...interface{}) {
+	DefaultWrapper.Panic(msg, keyvals...)
+}
+
+func Tracef(format string, args ...interface{}) {
+	DefaultWrapper.Tracef(format, args...)
+}
+
+func Debugf(format string, args ...interface{}) {
+	DefaultWrapper.Debugf(format, args...)
+}
+
+func Infof(format string, args ...interface{}) {
+	DefaultWrapper.Infof(format, args...)
+}
+
+func Warnf(format string, args ...interface{}) {
+	DefaultWrapper.Warnf(format, args...)
+}
+
+func Errorf(format string, args ...interface{}) {
+	DefaultWrapper.Errorf(format, args...)
+}
+
+func Fatalf(format string, args ...interface{}) {
+	DefaultWrapper.Fatalf(format, args...)
+}
+
+func Panicf(format string, args ...interface{}) {
+	DefaultWrapper.Panicf(format, args...)
+}
+
+// DefaultLevels changes levels of the default logger at runtime
+func DefaultLevels() *Levels {
+	return DefaultWrapper.log.Options().levels
+}
+
+// Sync flushes the default logger, call it before the process exits
+func Sync() error {
+	return DefaultWrapper.log.Sync()
 }
 
 func WithError(err error) *Wrapper {
@@ -51,6 +93,14 @@ func WithField(k string, v interface{}) *Wrapper {
 	})
 }
 
+func With(keyvals ...interface{}) *Wrapper {
+	return DefaultWrapper.With(keyvals...)
+}
+
+func WithComponent(name string) *Wrapper {
+	return DefaultWrapper.WithComponent(name)
+}
+
 func WithFields(fields map[string]interface{}) *Wrapper {
 	return DefaultWrapper.WithFields(fields)
 }
@@ -0,0 +1,41 @@
+package log
+
+import (
+	"os"
+	"sync"
+)
+
+var (
+	exitLock  sync.Mutex
+	exitHooks []func()
+	// osExit is replaced by tests of Fatal
+	osExit = os.Exit
+)
+
+// RegisterExitHook runs hook before Fatal exits the process, hooks run in reverse order of registration
+func RegisterExitHook(hook func()) {
+	exitLock.Lock()
+	defer exitLock.Unlock()
+	exitHooks = append(exitHooks, hook)
+}
+
+// exit runs the exit hooks, flushes the default logger and exits with code 1
+func exit() {
+	exitLock.Lock()
+	hooks := exitHooks
+	exitHooks = nil
+	exitLock.Unlock()
+	for i := len(hooks) - 1; i >= 0; i-- {
+		runExitHook(hooks[i])
+	}
+	_ = Sync()
+	osExit(1)
+}
+
+// runExitHook keeps a panicking hook from skipping the others
+func runExitHook(hook func()) {
+	defer func() {
+		_ = recover()
+	}()
+	hook()
+}
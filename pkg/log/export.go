@@ -0,0 +1,462 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ppxb/oreo-admin-go/pkg/constant"
+)
+
+const (
+	ExportOtlp = "otlp"
+	ExportLoki = "loki"
+)
+
+// ExportOptions configures Exporter, zero values use the defaults
+type ExportOptions struct {
+	// Protocol is otlp(OTLP/HTTP json) or loki(push api)
+	Protocol string
+	// Url receives the batches, e.g. http://127.0.0.1:4318/v1/logs or http://127.0.0.1:3100/loki/api/v1/push
+	Url     string
+	Headers map[string]string
+	// Labels are resource attributes of otlp or stream labels of loki, e.g. service.name
+	Labels map[string]string
+	// BatchSize defaults to 512 records
+	BatchSize int
+	// BufferSize defaults to 10000 records, records are dropped when it is full
+	BufferSize int
+	// FlushInterval defaults to 1 second
+	FlushInterval time.Duration
+	// MaxRetry defaults to 3, a batch is dropped after it
+	MaxRetry int
+	// Timeout of a push, default 5 seconds
+	Timeout time.Duration
+}
+
+// Exporter is the output of a json sink which pushes records to a collector in batches,
+// TraceId/SpanId/RequestId of records are sent so that logs can be found by the trace
+type Exporter struct {
+	ops     ExportOptions
+	client  *http.Client
+	records chan []byte
+	flush   chan chan struct{}
+	done    chan struct{}
+	once    sync.Once
+	wg      sync.WaitGroup
+	dropped atomic.Uint64
+}
+
+// NewExporter starts the push goroutine, Close stops it
+func NewExporter(ops ExportOptions) (*Exporter, error) {
+	if ops.Protocol != ExportOtlp && ops.Protocol != ExportLoki {
+		return nil, fmt.Errorf("invalid log export protocol: %s", ops.Protocol)
+	}
+	if ops.Url == "" {
+		return nil, fmt.Errorf("url of log export is empty")
+	}
+	if ops.BatchSize <= 0 {
+		ops.BatchSize = 512
+	}
+	if ops.BufferSize <= 0 {
+		ops.BufferSize = 10000
+	}
+	if ops.FlushInterval <= 0 {
+		ops.FlushInterval = time.Second
+	}
+	if ops.MaxRetry <= 0 {
+		ops.MaxRetry = 3
+	}
+	if ops.Timeout <= 0 {
+		ops.Timeout = 5 * time.Second
+	}
+	e := &Exporter{
+		ops:     ops,
+		client:  &http.Client{Timeout: ops.Timeout},
+		records: make(chan []byte, ops.BufferSize),
+		flush:   make(chan chan struct{}),
+		done:    make(chan struct{}),
+	}
+	e.wg.Add(1)
+	go e.run()
+	return e, nil
+}
+
+// Write queues a json record without blocking, it is dropped if the buffer is full
+func (e *Exporter) Write(p []byte) (int, error) {
+	b := make([]byte, len(p))
+	copy(b, p)
+	select {
+	case e.records <- b:
+	default:
+		e.dropped.Add(1)
+	}
+	return len(p), nil
+}
+
+// Dropped is the number of records lost by a full buffer or failed pushes
+func (e *Exporter) Dropped() uint64 {
+	return e.dropped.Load()
+}
+
+// Sync pushes the queued records, it is called by log.Sync before exit
+func (e *Exporter) Sync() error {
+	ack := make(chan struct{})
+	select {
+	case e.flush <- ack:
+		<-ack
+	case <-e.done:
+	}
+	return nil
+}
+
+// Close pushes the queued records and stops the exporter
+func (e *Exporter) Close() error {
+	e.once.Do(func() {
+		close(e.done)
+	})
+	e.wg.Wait()
+	return nil
+}
+
+func (e *Exporter) run() {
+	defer e.wg.Done()
+	ticker := time.NewTicker(e.ops.FlushInterval)
+	defer ticker.Stop()
+	batch := make([][]byte, 0, e.ops.BatchSize)
+	push := func() {
+		if len(batch) > 0 {
+			e.push(batch)
+			batch = make([][]byte, 0, e.ops.BatchSize)
+		}
+	}
+	// drain moves queued records into batches
+	drain := func() {
+		for {
+			select {
+			case b := <-e.records:
+				batch = append(batch, b)
+				if len(batch) >= e.ops.BatchSize {
+					push()
+				}
+			default:
+				push()
+				return
+			}
+		}
+	}
+	for {
+		select {
+		case b := <-e.records:
+			batch = append(batch, b)
+			if len(batch) >= e.ops.BatchSize {
+				push()
+			}
+		case <-ticker.C:
+			push()
+		case ack := <-e.flush:
+			drain()
+			close(ack)
+		case <-e.done:
+			drain()
+			return
+		}
+	}
+}
+
+// push sends a batch with exponential backoff, client errors except 429 are not retried
+func (e *Exporter) push(batch [][]byte) {
+	records := make([]exportRecord, 0, len(batch))
+	for _, b := range batch {
+		if r, ok := parseExportRecord(b); ok {
+			records = append(records, r)
+		}
+	}
+	if len(records) == 0 {
+		return
+	}
+	var body []byte
+	var err error
+	if e.ops.Protocol == ExportLoki {
+		body, err = e.lokiBody(records)
+	} else {
+		body, err = e.otlpBody(records)
+	}
+	if err != nil {
+		e.fail(len(records), err)
+		return
+	}
+
+	backoff := 100 * time.Millisecond
+	for i := 0; ; i++ {
+		var retry bool
+		retry, err = e.send(body)
+		if err == nil {
+			return
+		}
+		if !retry || i >= e.ops.MaxRetry {
+			break
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+	e.fail(len(records), err)
+}
+
+func (e *Exporter) send(body []byte) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), e.ops.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.ops.Url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.ops.Headers {
+		req.Header.Set(k, v)
+	}
+	res, err := e.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode/100 == 2 {
+		return false, nil
+	}
+	retry := res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= http.StatusInternalServerError
+	return retry, fmt.Errorf("push logs failed, status: %s", res.Status)
+}
+
+// fail cannot log by the logger itself, which would export the error again
+func (e *Exporter) fail(n int, err error) {
+	e.dropped.Add(uint64(n))
+	_, _ = fmt.Fprintf(os.Stderr, "export %d logs to %s failed: %v\n", n, e.ops.Url, err)
+}
+
+// exportRecord is a json record of zap or logrus
+type exportRecord struct {
+	time   time.Time
+	level  string
+	msg    string
+	line   []byte
+	fields map[string]interface{}
+}
+
+func parseExportRecord(b []byte) (exportRecord, bool) {
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	var m map[string]interface{}
+	if err := d.Decode(&m); err != nil {
+		return exportRecord{}, false
+	}
+	r := exportRecord{
+		time: time.Now(),
+		line: bytes.TrimSpace(b),
+	}
+	// zap uses ts, logrus uses time
+	for _, key := range []string{"ts", "time"} {
+		if s, ok := m[key].(string); ok {
+			if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+				r.time = t
+			}
+			delete(m, key)
+		}
+	}
+	r.level, _ = m["level"].(string)
+	r.level = strings.ToLower(r.level)
+	if r.level == "warning" {
+		r.level = "warn"
+	}
+	r.msg, _ = m["msg"].(string)
+	delete(m, "level")
+	delete(m, "msg")
+	r.fields = m
+	return r, true
+}
+
+type otlpValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpLogRecord struct {
+	TimeUnixNano         string          `json:"timeUnixNano"`
+	ObservedTimeUnixNano string          `json:"observedTimeUnixNano"`
+	SeverityNumber       int             `json:"severityNumber"`
+	SeverityText         string          `json:"severityText"`
+	Body                 otlpValue       `json:"body"`
+	Attributes           []otlpAttribute `json:"attributes,omitempty"`
+	TraceId              string          `json:"traceId,omitempty"`
+	SpanId               string          `json:"spanId,omitempty"`
+}
+
+// otlpBody encodes records as ExportLogsServiceRequest of OTLP/HTTP json
+func (e *Exporter) otlpBody(records []exportRecord) ([]byte, error) {
+	now := strconv.FormatInt(time.Now().UnixNano(), 10)
+	logs := make([]otlpLogRecord, 0, len(records))
+	for _, r := range records {
+		item := otlpLogRecord{
+			TimeUnixNano:         strconv.FormatInt(r.time.UnixNano(), 10),
+			ObservedTimeUnixNano: now,
+			SeverityNumber:       otlpSeverity(r.level),
+			SeverityText:         strings.ToUpper(r.level),
+			Body:                 otlpAnyValue(r.msg),
+		}
+		if s, ok := r.fields[constant.MiddlewareTraceIdCtxKey].(string); ok && isHex(s, 32) {
+			item.TraceId = s
+		}
+		if s, ok := r.fields[constant.MiddlewareSpanIdCtxKey].(string); ok && isHex(s, 16) {
+			item.SpanId = s
+		}
+		item.Attributes = otlpAttributes(r.fields)
+		logs = append(logs, item)
+	}
+	return json.Marshal(map[string]interface{}{
+		"resourceLogs": []interface{}{
+			map[string]interface{}{
+				"resource": map[string]interface{}{
+					"attributes": otlpLabels(e.ops.Labels),
+				},
+				"scopeLogs": []interface{}{
+					map[string]interface{}{
+						"scope": map[string]string{
+							"name": "github.com/ppxb/oreo-admin-go/pkg/log",
+						},
+						"logRecords": logs,
+					},
+				},
+			},
+		},
+	})
+}
+
+func otlpLabels(labels map[string]string) []otlpAttribute {
+	fields := make(map[string]interface{}, len(labels))
+	for k, v := range labels {
+		fields[k] = v
+	}
+	return otlpAttributes(fields)
+}
+
+func otlpAttributes(fields map[string]interface{}) []otlpAttribute {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	attrs := make([]otlpAttribute, 0, len(keys))
+	for _, k := range keys {
+		attrs = append(attrs, otlpAttribute{Key: k, Value: otlpAnyValue(fields[k])})
+	}
+	return attrs
+}
+
+func otlpAnyValue(v interface{}) otlpValue {
+	switch val := v.(type) {
+	case string:
+		return otlpValue{StringValue: &val}
+	case bool:
+		return otlpValue{BoolValue: &val}
+	case json.Number:
+		s := val.String()
+		if _, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return otlpValue{IntValue: &s}
+		}
+		if f, err := val.Float64(); err == nil {
+			return otlpValue{DoubleValue: &f}
+		}
+		return otlpValue{StringValue: &s}
+	default:
+		b, _ := json.Marshal(val)
+		s := string(b)
+		return otlpValue{StringValue: &s}
+	}
+}
+
+// otlpSeverity maps levels to SeverityNumber of the OpenTelemetry log data model
+func otlpSeverity(level string) int {
+	switch level {
+	case "trace":
+		return 1
+	case "debug":
+		return 5
+	case "info":
+		return 9
+	case "warn":
+		return 13
+	case "error":
+		return 17
+	case "fatal":
+		return 21
+	case "panic", "dpanic":
+		return 24
+	default:
+		return 0
+	}
+}
+
+// lokiBody groups records by level into streams of the push api, lines are the json records
+func (e *Exporter) lokiBody(records []exportRecord) ([]byte, error) {
+	type stream struct {
+		Stream map[string]string `json:"stream"`
+		Values [][2]string       `json:"values"`
+	}
+	streams := make([]*stream, 0, 1)
+	index := make(map[string]*stream)
+	for _, r := range records {
+		s, ok := index[r.level]
+		if !ok {
+			labels := make(map[string]string, len(e.ops.Labels)+1)
+			for k, v := range e.ops.Labels {
+				labels[lokiLabel(k)] = v
+			}
+			labels["level"] = r.level
+			s = &stream{Stream: labels}
+			index[r.level] = s
+			streams = append(streams, s)
+		}
+		s.Values = append(s.Values, [2]string{strconv.FormatInt(r.time.UnixNano(), 10), string(r.line)})
+	}
+	return json.Marshal(map[string]interface{}{
+		"streams": streams,
+	})
+}
+
+// lokiLabel replaces characters not allowed in label names, e.g. service.name is service_name
+func lokiLabel(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		if !(c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || i > 0 && c >= '0' && c <= '9') {
+			b[i] = '_'
+		}
+	}
+	return string(b)
+}
+
+func isHex(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F') {
+			return false
+		}
+	}
+	return true
+}
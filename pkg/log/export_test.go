@@ -0,0 +1,255 @@
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// collector is a local otlp/loki endpoint, statuses are the codes of successive pushes(200 after them)
+type collector struct {
+	lock     sync.Mutex
+	statuses []int
+	// batches are the record counts of accepted pushes
+	batches  []int
+	attempts int
+	// received is signaled on each push, release blocks pushes until it is closed
+	received chan struct{}
+	release  chan struct{}
+}
+
+func newCollector(t *testing.T, c *collector) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if c.received != nil {
+			c.received <- struct{}{}
+		}
+		if c.release != nil {
+			<-c.release
+		}
+		body, _ := io.ReadAll(r.Body)
+		c.lock.Lock()
+		defer c.lock.Unlock()
+		c.attempts++
+		if len(c.statuses) > 0 {
+			status := c.statuses[0]
+			c.statuses = c.statuses[1:]
+			if status != http.StatusOK {
+				w.WriteHeader(status)
+				return
+			}
+		}
+		n, err := countRecords(body)
+		if err != nil {
+			t.Errorf("invalid push body %s: %v", body, err)
+		}
+		c.batches = append(c.batches, n)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// countRecords counts log records of an otlp or loki push body
+func countRecords(body []byte) (int, error) {
+	var v struct {
+		ResourceLogs []struct {
+			ScopeLogs []struct {
+				LogRecords []json.RawMessage `json:"logRecords"`
+			} `json:"scopeLogs"`
+		} `json:"resourceLogs"`
+		Streams []struct {
+			Values [][2]string `json:"values"`
+		} `json:"streams"`
+	}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, item := range v.ResourceLogs {
+		for _, scope := range item.ScopeLogs {
+			n += len(scope.LogRecords)
+		}
+	}
+	for _, s := range v.Streams {
+		n += len(s.Values)
+	}
+	return n, nil
+}
+
+func (c *collector) result() ([]int, int) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return append([]int(nil), c.batches...), c.attempts
+}
+
+func writeRecords(t *testing.T, e *Exporter, n int) {
+	for i := 0; i < n; i++ {
+		line := fmt.Sprintf(`{"level":"info","ts":"2024-01-02T03:04:05Z","msg":"msg %d"}`+"\n", i)
+		if _, err := e.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestExporterBatching(t *testing.T) {
+	for _, protocol := range []string{ExportOtlp, ExportLoki} {
+		t.Run(protocol, func(t *testing.T) {
+			c := &collector{}
+			e, err := NewExporter(ExportOptions{
+				Protocol:      protocol,
+				Url:           newCollector(t, c).URL,
+				BatchSize:     3,
+				FlushInterval: time.Hour,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer e.Close()
+			writeRecords(t, e, 7)
+			if err = e.Sync(); err != nil {
+				t.Fatal(err)
+			}
+			batches, _ := c.result()
+			if fmt.Sprint(batches) != "[3 3 1]" {
+				t.Errorf("batches = %v, want [3 3 1]", batches)
+			}
+		})
+	}
+}
+
+func TestExporterFlushInterval(t *testing.T) {
+	c := &collector{received: make(chan struct{}, 1)}
+	e, err := NewExporter(ExportOptions{
+		Protocol:      ExportOtlp,
+		Url:           newCollector(t, c).URL,
+		FlushInterval: 50 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer e.Close()
+	writeRecords(t, e, 2)
+	select {
+	case <-c.received:
+	case <-time.After(time.Second):
+		t.Fatal("records are not pushed after the flush interval")
+	}
+}
+
+func TestExporterRetry(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		attempts int
+		dropped  uint64
+	}{
+		{"ok", nil, 1, 0},
+		{"too many requests", []int{http.StatusTooManyRequests}, 2, 0},
+		{"server error", []int{http.StatusInternalServerError, http.StatusBadGateway}, 3, 0},
+		{"max retry", []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusServiceUnavailable}, 3, 2},
+		{"bad request is dropped", []int{http.StatusBadRequest}, 1, 2},
+		{"unauthorized is dropped", []int{http.StatusUnauthorized}, 1, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &collector{statuses: tt.statuses}
+			e, err := NewExporter(ExportOptions{
+				Protocol:      ExportLoki,
+				Url:           newCollector(t, c).URL,
+				FlushInterval: time.Hour,
+				MaxRetry:      2,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer e.Close()
+			writeRecords(t, e, 2)
+			_ = e.Sync()
+			if _, attempts := c.result(); attempts != tt.attempts {
+				t.Errorf("attempts = %d, want %d", attempts, tt.attempts)
+			}
+			if n := e.Dropped(); n != tt.dropped {
+				t.Errorf("dropped = %d, want %d", n, tt.dropped)
+			}
+		})
+	}
+}
+
+func TestExporterBufferFull(t *testing.T) {
+	c := &collector{received: make(chan struct{}, 10), release: make(chan struct{})}
+	e, err := NewExporter(ExportOptions{
+		Protocol:      ExportOtlp,
+		Url:           newCollector(t, c).URL,
+		BatchSize:     1,
+		BufferSize:    2,
+		FlushInterval: time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the first record blocks the push goroutine, 2 of the next 5 fit into the buffer
+	writeRecords(t, e, 1)
+	select {
+	case <-c.received:
+	case <-time.After(time.Second):
+		t.Fatal("first record is not pushed")
+	}
+	writeRecords(t, e, 5)
+	if n := e.Dropped(); n != 3 {
+		t.Errorf("dropped = %d, want 3", n)
+	}
+	close(c.release)
+	if err = e.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if batches, _ := c.result(); fmt.Sprint(batches) != "[1 1 1]" {
+		t.Errorf("batches = %v, want [1 1 1]", batches)
+	}
+}
+
+func TestExporterClose(t *testing.T) {
+	c := &collector{}
+	e, err := NewExporter(ExportOptions{
+		Protocol:      ExportOtlp,
+		Url:           newCollector(t, c).URL,
+		FlushInterval: time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeRecords(t, e, 2)
+	if err = e.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if batches, _ := c.result(); fmt.Sprint(batches) != "[2]" {
+		t.Errorf("batches = %v, want [2]", batches)
+	}
+	// Sync and Close after Close return at once
+	done := make(chan struct{})
+	go func() {
+		_ = e.Sync()
+		_ = e.Close()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Sync blocks after Close")
+	}
+}
+
+func TestNewExporterInvalid(t *testing.T) {
+	tests := []ExportOptions{
+		{Protocol: "kafka", Url: "http://127.0.0.1"},
+		{Protocol: ExportOtlp},
+	}
+	for _, ops := range tests {
+		if _, err := NewExporter(ops); err == nil {
+			t.Errorf("NewExporter(%+v) succeeded", ops)
+		}
+	}
+}
@@ -50,23 +50,28 @@ func NewGormLogger(config Config) logger.Interface {
 	}
 }
 
-func (l *gormLogger) getLogger(ctx context.Context) Interface {
-	return DefaultWrapper.WithContext(ctx).log.WithFields(DefaultWrapper.WithContext(ctx).fields)
-}
-
-func (l *gormLogger) getLoggerWithLineNum(ctx context.Context) Interface {
+// logf writes a record of the gorm component, which may have its own runtime level
+func (l *gormLogger) logf(ctx context.Context, level Level, format string, args ...interface{}) {
+	w := DefaultWrapper.WithContext(ctx).WithComponent("gorm")
+	if !w.enabled(level, nil) {
+		return
+	}
 	skipHelper := true
 	if v, ok := ctx.Value(constant.LogSkipHelperCtxKey).(bool); ok {
 		skipHelper = v
 	}
+	fields := copyFields(w.fields)
 	lineNum := fileWithLineNum(
 		l.ops,
 		WithSkipGorm(true),
 		WithSkipHelper(skipHelper),
 	)
-	return l.getLogger(ctx).WithFields(map[string]interface{}{
-		constant.LogLineNumKey: lineNum,
-	})
+	fields[constant.LogLineNumKey] = lineNum
+	// a hot loop is limited by its call site
+	if !sample(w.log.Options().gormSampler, level, format+lineNum, fields) {
+		return
+	}
+	w.write(level, fields, fmt.Sprintf(format, args...))
 }
 
 func (l *gormLogger) LogMode(level logger.LogLevel) logger.Interface {
@@ -77,19 +82,19 @@ func (l *gormLogger) LogMode(level logger.LogLevel) logger.Interface {
 
 func (l *gormLogger) Info(ctx context.Context, format string, args ...interface{}) {
 	if l.gorm.LogLevel >= logger.Info {
-		l.getLoggerWithLineNum(ctx).Logf(InfoLevel, format, args...)
+		l.logf(ctx, InfoLevel, format, args...)
 	}
 }
 
 func (l *gormLogger) Warn(ctx context.Context, format string, args ...interface{}) {
 	if l.gorm.LogLevel >= logger.Warn {
-		l.getLoggerWithLineNum(ctx).Logf(WarnLevel, format, args...)
+		l.logf(ctx, WarnLevel, format, args...)
 	}
 }
 
 func (l *gormLogger) Error(ctx context.Context, format string, args ...interface{}) {
 	if l.gorm.LogLevel >= logger.Error {
-		l.getLoggerWithLineNum(ctx).Logf(ErrorLevel, format, args...)
+		l.logf(ctx, ErrorLevel, format, args...)
 	}
 }
 
@@ -108,20 +113,20 @@ func (l *gormLogger) Trace(ctx context.Context, begin time.Time, fc func() (stri
 
 	if hiddenSql, ok := ctx.Value(constant.LogHiddenSqlCtxKey).(bool); ok && hiddenSql {
 		sql = "(sql is hidden)"
+	} else {
+		sql = DefaultWrapper.log.Options().redactor.Sql(sql)
 	}
 
-	log := l.getLoggerWithLineNum(ctx)
-
 	switch {
 	case l.gorm.LogLevel >= logger.Error && err != nil && !errors.Is(err, gorm.ErrRecordNotFound):
 		if l.gorm.SlowThreshold > 0 && elapsed > l.gorm.SlowThreshold {
-			log.Logf(ErrorLevel, l.slowErrStr, err, elapsedF, row, sql)
+			l.logf(ctx, ErrorLevel, l.slowErrStr, err, elapsedF, row, sql)
 		} else {
-			log.Logf(ErrorLevel, l.normalErrStr, err, elapsedF, row, sql)
+			l.logf(ctx, ErrorLevel, l.normalErrStr, err, elapsedF, row, sql)
 		}
 	case l.gorm.LogLevel >= logger.Warn && l.gorm.SlowThreshold > 0 && elapsed > l.gorm.SlowThreshold:
-		log.Logf(WarnLevel, l.slowStr, elapsedF, row, sql)
+		l.logf(ctx, WarnLevel, l.slowStr, elapsedF, row, sql)
 	case l.gorm.LogLevel >= logger.Info:
-		log.Logf(InfoLevel, l.normalStr, elapsedF, row, sql)
+		l.logf(ctx, InfoLevel, l.normalStr, elapsedF, row, sql)
 	}
 }
@@ -32,3 +32,28 @@ func (l Level) LevelToGorm() logger.LogLevel {
 		return logger.Silent
 	}
 }
+
+func (l Level) Valid() bool {
+	return l <= TraceLevel
+}
+
+func (l Level) String() string {
+	switch l {
+	case PanicLevel:
+		return "panic"
+	case FatalLevel:
+		return "fatal"
+	case ErrorLevel:
+		return "error"
+	case WarnLevel:
+		return "warn"
+	case InfoLevel:
+		return "info"
+	case DebugLevel:
+		return "debug"
+	case TraceLevel:
+		return "trace"
+	default:
+		return "unknown"
+	}
+}
@@ -0,0 +1,133 @@
+package log
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Levels decides at runtime which records are logged, it is shared by every copy of a logger
+type Levels struct {
+	base atomic.Uint32
+	// overrides skips the lock when there is no override
+	overrides  atomic.Bool
+	lock       sync.RWMutex
+	components map[string]Level
+	requests   map[string]RequestLevel
+}
+
+// RequestLevel raises the level of one request id until ExpireAt
+type RequestLevel struct {
+	Level    Level     `json:"level"`
+	ExpireAt time.Time `json:"expireAt"`
+}
+
+func newLevels(base Level, components map[string]Level) *Levels {
+	l := &Levels{
+		components: make(map[string]Level, len(components)),
+		requests:   make(map[string]RequestLevel),
+	}
+	l.base.Store(uint32(base))
+	for k, v := range components {
+		l.components[k] = v
+	}
+	l.changed()
+	return l
+}
+
+// Enabled checks the request override first, then the component override and the base level
+func (l *Levels) Enabled(level Level, component, requestId string) bool {
+	if !l.overrides.Load() {
+		return l.Level().Enabled(level)
+	}
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+	if requestId != "" {
+		if r, ok := l.requests[requestId]; ok && time.Now().Before(r.ExpireAt) && r.Level.Enabled(level) {
+			return true
+		}
+	}
+	if component != "" {
+		if c, ok := l.components[component]; ok {
+			return c.Enabled(level)
+		}
+	}
+	return l.Level().Enabled(level)
+}
+
+func (l *Levels) Level() Level {
+	return Level(l.base.Load())
+}
+
+func (l *Levels) SetLevel(level Level) {
+	l.base.Store(uint32(level))
+}
+
+// Components returns a copy of the component overrides
+func (l *Levels) Components() map[string]Level {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+	m := make(map[string]Level, len(l.components))
+	for k, v := range l.components {
+		m[k] = v
+	}
+	return m
+}
+
+func (l *Levels) SetComponentLevel(component string, level Level) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.components[component] = level
+	l.changed()
+}
+
+func (l *Levels) ResetComponentLevel(component string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	delete(l.components, component)
+	l.changed()
+}
+
+// Requests returns the request overrides which are not expired
+func (l *Levels) Requests() map[string]RequestLevel {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.purge()
+	m := make(map[string]RequestLevel, len(l.requests))
+	for k, v := range l.requests {
+		m[k] = v
+	}
+	return m
+}
+
+// SetRequestLevel logs records of requestId at level for d, e.g. debug one user's requests in production
+func (l *Levels) SetRequestLevel(requestId string, level Level, d time.Duration) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.purge()
+	l.requests[requestId] = RequestLevel{Level: level, ExpireAt: time.Now().Add(d)}
+	l.changed()
+}
+
+func (l *Levels) ResetRequestLevel(requestId string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	delete(l.requests, requestId)
+	l.changed()
+}
+
+// changed updates overrides, the caller holds the write lock
+func (l *Levels) changed() {
+	l.overrides.Store(len(l.components)+len(l.requests) > 0)
+}
+
+// purge drops expired request overrides, the caller holds the write lock
+func (l *Levels) purge() {
+	now := time.Now()
+	for k, v := range l.requests {
+		if !now.Before(v.ExpireAt) {
+			delete(l.requests, k)
+		}
+	}
+	l.changed()
+}
@@ -30,6 +30,8 @@ type Interface interface {
 	WithFields(fields map[string]interface{}) Interface
 	Log(level Level, v ...interface{})
 	Logf(level Level, format string, v ...interface{})
+	// Sync flushes the sinks, called before exit
+	Sync() error
 }
 
 type Config struct {
@@ -42,6 +44,7 @@ func New(options ...func(*Options)) (l Interface) {
 	for _, f := range options {
 		f(ops)
 	}
+	ops.levels = newLevels(ops.level, ops.componentLevels)
 	switch ops.category {
 	case constant.LogCategoryZap:
 		l = newZap(ops)
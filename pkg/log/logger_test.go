@@ -0,0 +1,223 @@
+package log
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/ppxb/oreo-admin-go/pkg/constant"
+)
+
+func TestLevelMapping(t *testing.T) {
+	tests := []struct {
+		level  Level
+		name   string
+		zap    zapcore.Level
+		logrus logrus.Level
+	}{
+		{TraceLevel, "trace", zapTraceLevel, logrus.TraceLevel},
+		{DebugLevel, "debug", zapcore.DebugLevel, logrus.DebugLevel},
+		{InfoLevel, "info", zapcore.InfoLevel, logrus.InfoLevel},
+		{WarnLevel, "warn", zapcore.WarnLevel, logrus.WarnLevel},
+		{ErrorLevel, "error", zapcore.ErrorLevel, logrus.ErrorLevel},
+		{FatalLevel, "fatal", zapcore.FatalLevel, logrus.FatalLevel},
+		{PanicLevel, "panic", zapcore.PanicLevel, logrus.PanicLevel},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if s := tt.level.String(); s != tt.name {
+				t.Errorf("String() = %q", s)
+			}
+			if l := loggerToZapLevel(tt.level); l != tt.zap {
+				t.Errorf("zap level = %v, want %v", l, tt.zap)
+			}
+			if l := loggerToLogrusLevel(tt.level); l != tt.logrus {
+				t.Errorf("logrus level = %v, want %v", l, tt.logrus)
+			}
+			if !tt.level.Valid() {
+				t.Error("level is not valid")
+			}
+		})
+	}
+	if Level(7).Valid() {
+		t.Error("Level(7) is valid")
+	}
+}
+
+// stubExit replaces os.Exit and returns the codes it was called with
+func stubExit(t *testing.T) *[]int {
+	codes := &[]int{}
+	osExit = func(code int) {
+		*codes = append(*codes, code)
+	}
+	t.Cleanup(func() {
+		osExit = osExitDefault
+	})
+	return codes
+}
+
+var osExitDefault = osExit
+
+// logEveryLevel writes one record per level, Panic is recovered and Fatal needs stubExit
+func logEveryLevel(t *testing.T, w *Wrapper) {
+	w.Trace("trace msg")
+	w.Debug("debug msg")
+	w.Info("info msg", "k", "v")
+	w.Warn("warn msg")
+	w.Error("error msg")
+	w.Fatal("fatal msg")
+	func() {
+		defer func() {
+			if r := recover(); r != "panic msg" {
+				t.Errorf("recovered %v, want panic msg", r)
+			}
+		}()
+		w.Panic("panic msg")
+	}()
+}
+
+type record struct {
+	Level string `json:"level"`
+	Msg   string `json:"msg"`
+	K     string `json:"k"`
+}
+
+func readJson(t *testing.T, b *bytes.Buffer) []record {
+	var list []record
+	scanner := bufio.NewScanner(b)
+	for scanner.Scan() {
+		var r record
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			t.Fatalf("invalid json line %q: %v", scanner.Text(), err)
+		}
+		list = append(list, r)
+	}
+	return list
+}
+
+func TestSinkOutput(t *testing.T) {
+	tests := []struct {
+		category string
+		// names of trace..panic written by the backend
+		names []string
+	}{
+		{constant.LogCategoryZap, []string{"trace", "debug", "info", "warn", "error", "fatal", "panic"}},
+		{constant.LogCategoryLogrus, []string{"trace", "debug", "info", "warning", "error", "fatal", "panic"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.category, func(t *testing.T) {
+			codes := stubExit(t)
+			var all, errs bytes.Buffer
+			w := NewWrapper(New(
+				WithCategory(tt.category),
+				WithLevel(TraceLevel),
+				WithSinks(
+					Sink{Output: &all, Level: TraceLevel, Json: true},
+					Sink{Output: &errs, Level: ErrorLevel, Json: true},
+				),
+			))
+			hooks := 0
+			RegisterExitHook(func() { hooks++ })
+			logEveryLevel(t, w)
+
+			if len(*codes) != 1 || (*codes)[0] != 1 || hooks != 1 {
+				t.Errorf("exit codes = %v, hooks = %d, want [1] and 1", *codes, hooks)
+			}
+			records := readJson(t, &all)
+			if len(records) != len(tt.names) {
+				t.Fatalf("records = %+v", records)
+			}
+			msgs := []string{"trace msg", "debug msg", "info msg", "warn msg", "error msg", "fatal msg", "panic msg"}
+			for i, r := range records {
+				if r.Level != tt.names[i] || r.Msg != msgs[i] {
+					t.Errorf("record %d = %+v, want %s %s", i, r, tt.names[i], msgs[i])
+				}
+			}
+			if records[2].K != "v" {
+				t.Errorf("info fields = %+v", records[2])
+			}
+			// the error sink only gets error, fatal and panic
+			var levels []string
+			for _, r := range readJson(t, &errs) {
+				levels = append(levels, r.Level)
+			}
+			if got, want := strings.Join(levels, ","), strings.Join(tt.names[4:], ","); got != want {
+				t.Errorf("error sink levels = %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestRuntimeLevel(t *testing.T) {
+	for _, category := range []string{constant.LogCategoryZap, constant.LogCategoryLogrus} {
+		t.Run(category, func(t *testing.T) {
+			stubExit(t)
+			var b bytes.Buffer
+			w := NewWrapper(New(
+				WithCategory(category),
+				WithLevel(WarnLevel),
+				WithSinks(Sink{Output: &b, Level: TraceLevel, Json: true}),
+			))
+			logEveryLevel(t, w)
+			var msgs []string
+			for _, r := range readJson(t, &b) {
+				msgs = append(msgs, r.Msg)
+			}
+			if got := strings.Join(msgs, ","); got != "warn msg,error msg,fatal msg,panic msg" {
+				t.Errorf("messages = %s", got)
+			}
+		})
+	}
+}
+
+func TestTextSink(t *testing.T) {
+	tests := []struct {
+		category string
+		want     string
+	}{
+		{constant.LogCategoryZap, "\tTRACE\ttrace msg"},
+		{constant.LogCategoryLogrus, "level=trace msg=trace msg"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.category, func(t *testing.T) {
+			var b bytes.Buffer
+			w := NewWrapper(New(
+				WithCategory(tt.category),
+				WithLevel(TraceLevel),
+				WithSinks(Sink{Output: &b, Level: TraceLevel}),
+			))
+			w.Trace("trace msg")
+			if !strings.Contains(b.String(), tt.want) {
+				t.Errorf("output %q does not contain %q", b.String(), tt.want)
+			}
+			if strings.Contains(b.String(), "\x1b[") {
+				t.Errorf("buffer output is colored: %q", b.String())
+			}
+		})
+	}
+}
+
+func TestExitHooks(t *testing.T) {
+	codes := stubExit(t)
+	var order []int
+	RegisterExitHook(func() { order = append(order, 1) })
+	RegisterExitHook(func() { panic("broken hook") })
+	RegisterExitHook(func() { order = append(order, 3) })
+	exit()
+	if len(order) != 2 || order[0] != 3 || order[1] != 1 {
+		t.Errorf("hooks ran in order %v, want [3 1]", order)
+	}
+	if len(*codes) != 1 || (*codes)[0] != 1 {
+		t.Errorf("exit codes = %v", *codes)
+	}
+	// hooks run once
+	exit()
+	if len(order) != 2 {
+		t.Errorf("hooks ran again: %v", order)
+	}
+}
@@ -1,29 +1,33 @@
 package log
 
-import "github.com/sirupsen/logrus"
+import (
+	"io"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
 
 type logrusLog struct {
-	log *logrus.Entry
-	ops Options
+	log   *logrus.Entry
+	ops   Options
+	sinks []Sink
 }
 
 func newLogrus(ops *Options) *logrusLog {
+	sinks := ops.sinks()
 	ll := logrus.New()
-	ll.SetLevel(loggerToLogrusLevel(ops.level))
-	if ops.json {
-		ll.SetFormatter(&logrus.JSONFormatter{})
-	} else {
-		ll.SetFormatter(&logrus.TextFormatter{
-			FullTimestamp: true,
-			DisableQuote:  true,
-		})
-	}
-	if ops.output != nil {
-		ll.SetOutput(ops.output)
+	// levels are checked by Levels before records reach logrus
+	ll.SetLevel(logrus.TraceLevel)
+	// records are formatted by the hook of each sink
+	ll.SetOutput(io.Discard)
+	ll.SetFormatter(nopFormatter{})
+	for _, sink := range sinks {
+		ll.AddHook(newLogrusSink(sink))
 	}
 	l := logrusLog{
-		log: logrus.NewEntry(ll),
-		ops: *ops,
+		log:   logrus.NewEntry(ll),
+		ops:   *ops,
+		sinks: sinks,
 	}
 	return &l
 }
@@ -33,21 +37,95 @@ func (l *logrusLog) Options() Options {
 }
 
 func (l *logrusLog) WithFields(fields map[string]interface{}) Interface {
+	data := make(logrus.Fields, len(fields))
+	for k, v := range fields {
+		// same as zap's StringDurationEncoder
+		if d, ok := v.(time.Duration); ok {
+			v = d.String()
+		}
+		data[k] = v
+	}
 	ll := &logrusLog{
-		log: l.log.WithFields(fields),
-		ops: l.ops,
+		log:   l.log.WithFields(data),
+		ops:   l.ops,
+		sinks: l.sinks,
 	}
 	return ll
 }
 
 func (l *logrusLog) Log(level Level, args ...interface{}) {
+	defer recoverLogrusPanic(level)
 	l.log.Log(loggerToLogrusLevel(level), args...)
 }
 
 func (l *logrusLog) Logf(level Level, format string, args ...interface{}) {
+	defer recoverLogrusPanic(level)
 	l.log.Logf(loggerToLogrusLevel(level), format, args...)
 }
 
+// recoverLogrusPanic swallows the panic of logrus after a PanicLevel record is written,
+// Wrapper panics with the message instead so that every backend behaves the same
+func recoverLogrusPanic(level Level) {
+	if level != PanicLevel {
+		return
+	}
+	if err := recover(); err != nil {
+		if _, ok := err.(*logrus.Entry); !ok {
+			panic(err)
+		}
+	}
+}
+
+func (l *logrusLog) Sync() error {
+	return syncSinks(l.sinks)
+}
+
+// logrusSink is a hook writing records enabled by the sink level with its own formatter
+type logrusSink struct {
+	level     logrus.Level
+	formatter logrus.Formatter
+	out       io.Writer
+}
+
+func newLogrusSink(sink Sink) *logrusSink {
+	var formatter logrus.Formatter = &logrus.TextFormatter{
+		FullTimestamp: true,
+		DisableQuote:  true,
+		ForceColors:   colored(sink.Output),
+		DisableColors: !colored(sink.Output),
+	}
+	if sink.Json {
+		formatter = &logrus.JSONFormatter{}
+	}
+	return &logrusSink{
+		level:     loggerToLogrusLevel(sink.Level),
+		formatter: formatter,
+		out:       &lockedWriter{w: sink.Output},
+	}
+}
+
+func (s *logrusSink) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (s *logrusSink) Fire(entry *logrus.Entry) error {
+	if entry.Level > s.level {
+		return nil
+	}
+	b, err := s.formatter.Format(entry)
+	if err != nil {
+		return err
+	}
+	_, err = s.out.Write(b)
+	return err
+}
+
+type nopFormatter struct{}
+
+func (nopFormatter) Format(*logrus.Entry) ([]byte, error) {
+	return nil, nil
+}
+
 func loggerToLogrusLevel(level Level) logrus.Level {
 	switch level {
 	case TraceLevel:
@@ -62,6 +140,8 @@ func loggerToLogrusLevel(level Level) logrus.Level {
 		return logrus.ErrorLevel
 	case FatalLevel:
 		return logrus.FatalLevel
+	case PanicLevel:
+		return logrus.PanicLevel
 	default:
 		return logrus.InfoLevel
 	}
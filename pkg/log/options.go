@@ -29,15 +29,21 @@ func getFileWithLineNumOptionsOrSetDefault(options *FileWithLineNumOptions) *Fil
 }
 
 type Options struct {
-	level          Level
-	output         io.Writer
-	category       string
-	json           bool
-	lineNum        bool
-	lineNumPrefix  string
-	lineNumLevel   int
-	lineNumSource  bool
-	lineNumVersion bool
+	level           Level
+	componentLevels map[string]Level
+	levels          *Levels
+	redactor        *Redactor
+	sampler         *Sampler
+	gormSampler     *Sampler
+	output          io.Writer
+	sinkList        []Sink
+	category        string
+	json            bool
+	lineNum         bool
+	lineNumPrefix   string
+	lineNumLevel    int
+	lineNumSource   bool
+	lineNumVersion  bool
 }
 
 func WithLevel(level Level) func(*Options) {
@@ -46,12 +52,47 @@ func WithLevel(level Level) func(*Options) {
 	}
 }
 
+// WithComponentLevels overrides the level of components, e.g. gorm at Warn
+func WithComponentLevels(levels map[string]Level) func(*Options) {
+	return func(options *Options) {
+		getOptionsOrSetDefault(options).componentLevels = levels
+	}
+}
+
+// WithRedactor masks sensitive fields, messages and SQL values of records
+func WithRedactor(r *Redactor) func(*Options) {
+	return func(options *Options) {
+		getOptionsOrSetDefault(options).redactor = r
+	}
+}
+
+// WithSampler limits records of the same message, see Sampler
+func WithSampler(s *Sampler) func(*Options) {
+	return func(options *Options) {
+		getOptionsOrSetDefault(options).sampler = s
+	}
+}
+
+// WithGormSampler limits records of gorm by call site, apart from WithSampler so that sql cannot use up its budget
+func WithGormSampler(s *Sampler) func(*Options) {
+	return func(options *Options) {
+		getOptionsOrSetDefault(options).gormSampler = s
+	}
+}
+
 func WithOutput(output io.Writer) func(*Options) {
 	return func(options *Options) {
 		getOptionsOrSetDefault(options).output = output
 	}
 }
 
+// WithSinks replaces output, every record is written to each sink enabling its level
+func WithSinks(sinks ...Sink) func(*Options) {
+	return func(options *Options) {
+		getOptionsOrSetDefault(options).sinkList = sinks
+	}
+}
+
 func WithCategory(s string) func(*Options) {
 	return func(options *Options) {
 		getOptionsOrSetDefault(options).category = s
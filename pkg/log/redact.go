@@ -0,0 +1,302 @@
+package log
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+const defaultRedactMask = "******"
+
+// RedactOptions decides what Redactor masks
+type RedactOptions struct {
+	// Fields are names of sensitive fields, matched case-insensitively ignoring '_' and '-',
+	// a field containing one of them is masked, e.g. password masks newPassword and user_password
+	Fields []string
+	// Patterns are regexps masked in messages and string values, e.g. emails
+	Patterns []string
+	// Cards masks runs of 15-19 digits passing the Luhn check, 13 digits are left for millisecond timestamps
+	Cards bool
+	// Columns are SQL columns whose bound values are masked, e.g. password, mobile
+	Columns []string
+	// Mask replaces sensitive values, default ******
+	Mask string
+}
+
+// Redactor masks sensitive data of records, a nil Redactor keeps records as they are
+type Redactor struct {
+	mask     string
+	fields   []string
+	patterns []*regexp.Regexp
+	cards    bool
+	columns  map[string]struct{}
+	// compare masks the value after a comparison of a flagged column, e.g. mobile = '138...'
+	compare *regexp.Regexp
+	// in masks the list after IN of a flagged column
+	in *regexp.Regexp
+}
+
+var (
+	cardRegexp   = regexp.MustCompile(`\b\d(?:[ -]?\d){14,18}\b`)
+	insertRegexp = regexp.MustCompile("(?is)^\\s*(?:INSERT|REPLACE)\\s+(?:IGNORE\\s+)?INTO\\s+[^\\s(]+\\s*\\(([^)]*)\\)\\s*VALUES\\s*")
+	sqlValue     = `'(?:[^'\\]|\\.|'')*'|"(?:[^"\\]|\\.)*"|[^\s,)]+`
+)
+
+// NewRedactor compiles ops, it fails with invalid patterns
+func NewRedactor(ops RedactOptions) (*Redactor, error) {
+	r := &Redactor{
+		mask:    ops.Mask,
+		cards:   ops.Cards,
+		columns: make(map[string]struct{}, len(ops.Columns)),
+	}
+	if r.mask == "" {
+		r.mask = defaultRedactMask
+	}
+	for _, item := range ops.Fields {
+		if item = normalizeRedactKey(item); item != "" {
+			r.fields = append(r.fields, item)
+		}
+	}
+	for _, item := range ops.Patterns {
+		re, err := regexp.Compile(item)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redact pattern %s: %w", item, err)
+		}
+		r.patterns = append(r.patterns, re)
+	}
+	columns := make([]string, 0, len(ops.Columns))
+	for _, item := range ops.Columns {
+		item = strings.ToLower(strings.TrimSpace(item))
+		if item == "" {
+			continue
+		}
+		r.columns[item] = struct{}{}
+		columns = append(columns, regexp.QuoteMeta(item))
+	}
+	if len(columns) > 0 {
+		column := "[`\"]?\\b(?:" + strings.Join(columns, "|") + ")\\b[`\"]?"
+		r.compare = regexp.MustCompile(`(?i)(` + column + `\s*(?:<=>|!=|<>|>=|<=|=|>|<|\s(?:NOT\s+)?LIKE\s)\s*)(` + sqlValue + `)`)
+		r.in = regexp.MustCompile(`(?i)(` + column + `\s+(?:NOT\s+)?IN\s*)\([^)]*\)`)
+	}
+	return r, nil
+}
+
+// Key reports whether values of key are masked
+func (r *Redactor) Key(key string) bool {
+	if r == nil {
+		return false
+	}
+	key = normalizeRedactKey(key)
+	for _, item := range r.fields {
+		if strings.Contains(key, item) {
+			return true
+		}
+	}
+	return false
+}
+
+// Value masks val of a sensitive key, or the patterns in strings, errors and nested values,
+// structs, maps and slices are checked in their JSON form, which replaces them only if something is masked
+func (r *Redactor) Value(key string, val interface{}) interface{} {
+	if r == nil || val == nil {
+		return val
+	}
+	if r.Key(key) {
+		return r.mask
+	}
+	switch v := val.(type) {
+	case string:
+		return r.String(v)
+	case error:
+		if s := r.String(v.Error()); s != v.Error() {
+			return errors.New(s)
+		}
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			m[k] = r.Value(k, item)
+		}
+		return m
+	case []byte:
+		return val
+	}
+	switch reflect.Indirect(reflect.ValueOf(val)).Kind() {
+	case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array:
+		return r.composite(val)
+	}
+	return val
+}
+
+// composite masks a struct, map or slice through its JSON form, e.g. a request with a Password field
+func (r *Redactor) composite(val interface{}) interface{} {
+	b, err := json.Marshal(val)
+	if err != nil {
+		return val
+	}
+	var v interface{}
+	if err = json.Unmarshal(b, &v); err != nil {
+		return val
+	}
+	if masked, changed := r.walk(v); changed {
+		return masked
+	}
+	return val
+}
+
+// walk masks decoded JSON and reports whether anything is masked
+func (r *Redactor) walk(val interface{}) (interface{}, bool) {
+	switch v := val.(type) {
+	case string:
+		s := r.String(v)
+		return s, s != v
+	case map[string]interface{}:
+		changed := false
+		for k, item := range v {
+			if r.Key(k) {
+				v[k] = r.mask
+				changed = true
+				continue
+			}
+			var ok bool
+			if v[k], ok = r.walk(item); ok {
+				changed = true
+			}
+		}
+		return v, changed
+	case []interface{}:
+		changed := false
+		for i, item := range v {
+			var ok bool
+			if v[i], ok = r.walk(item); ok {
+				changed = true
+			}
+		}
+		return v, changed
+	}
+	return val, false
+}
+
+// String masks the patterns and card numbers in s
+func (r *Redactor) String(s string) string {
+	if r == nil || s == "" {
+		return s
+	}
+	for _, re := range r.patterns {
+		s = re.ReplaceAllString(s, r.mask)
+	}
+	if r.cards {
+		s = cardRegexp.ReplaceAllStringFunc(s, func(m string) string {
+			if luhn(m) {
+				return r.mask
+			}
+			return m
+		})
+	}
+	return s
+}
+
+// Sql masks bound values of the flagged columns in inserts, comparisons and IN lists
+func (r *Redactor) Sql(sql string) string {
+	if r == nil || len(r.columns) == 0 {
+		return sql
+	}
+	sql = r.insert(sql)
+	sql = r.compare.ReplaceAllString(sql, "${1}'"+r.mask+"'")
+	return r.in.ReplaceAllString(sql, "${1}('"+r.mask+"')")
+}
+
+// insert masks the values of flagged columns in every tuple of INSERT ... (columns) VALUES (...), (...)
+func (r *Redactor) insert(sql string) string {
+	m := insertRegexp.FindStringSubmatchIndex(sql)
+	if m == nil {
+		return sql
+	}
+	names := strings.Split(sql[m[2]:m[3]], ",")
+	flagged := make(map[int]bool, len(names))
+	for i, name := range names {
+		name = strings.ToLower(strings.Trim(strings.TrimSpace(name), "`\""))
+		if _, ok := r.columns[name]; ok {
+			flagged[i] = true
+		}
+	}
+	if len(flagged) == 0 {
+		return sql
+	}
+
+	var b strings.Builder
+	b.WriteString(sql[:m[1]])
+	rest := sql[m[1]:]
+	depth, index, start := 0, 0, 0
+	var quote byte
+	for i := 0; i < len(rest); i++ {
+		c := rest[i]
+		if quote != 0 {
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+			continue
+		}
+		switch {
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '(':
+			depth++
+			if depth == 1 {
+				b.WriteString(rest[start : i+1])
+				index, start = 0, i+1
+			}
+		case depth == 1 && (c == ',' || c == ')'):
+			value := rest[start:i]
+			if flagged[index] {
+				trimmed := strings.TrimLeft(value, " ")
+				value = value[:len(value)-len(trimmed)] + "'" + r.mask + "'"
+			}
+			b.WriteString(value)
+			b.WriteByte(c)
+			index++
+			start = i + 1
+			if c == ')' {
+				depth--
+			}
+		case c == ')':
+			depth--
+		case depth == 0 && c != ',' && c != ' ' && c != '\n' && c != '\t':
+			// ON DUPLICATE KEY UPDATE, RETURNING...
+			b.WriteString(rest[start:])
+			return b.String()
+		}
+	}
+	b.WriteString(rest[start:])
+	return b.String()
+}
+
+func normalizeRedactKey(key string) string {
+	key = strings.ToLower(key)
+	return strings.NewReplacer("_", "", "-", "", " ", "").Replace(key)
+}
+
+// luhn validates the check digit of a card number, s may contain spaces or dashes
+func luhn(s string) bool {
+	sum, n := 0, 0
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if n%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		n++
+	}
+	return n > 0 && sum%10 == 0
+}
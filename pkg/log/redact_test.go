@@ -0,0 +1,215 @@
+package log
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type loginReq struct {
+	Username string   `json:"username"`
+	Password string   `json:"password"`
+	Email    string   `json:"email"`
+	Tags     []string `json:"tags"`
+	secret   string
+}
+
+type profile struct {
+	Name    string     `json:"name"`
+	Account loginReq   `json:"account"`
+	Logins  []loginReq `json:"logins"`
+}
+
+func newTestRedactor(t *testing.T) *Redactor {
+	r, err := NewRedactor(RedactOptions{
+		Fields:   []string{"password", "id_card"},
+		Patterns: []string{`[\w.]+@[\w.]+`},
+		Cards:    true,
+		Columns:  []string{"mobile", "password"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestRedactorKey(t *testing.T) {
+	r := newTestRedactor(t)
+	tests := []struct {
+		key  string
+		want bool
+	}{
+		{"password", true},
+		{"newPassword", true},
+		{"user_password", true},
+		{"ID-Card", true},
+		{"idCardNo", true},
+		{"username", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := r.Key(tt.key); got != tt.want {
+			t.Errorf("Key(%q) = %v, want %v", tt.key, got, tt.want)
+		}
+	}
+	var nilRedactor *Redactor
+	if nilRedactor.Key("password") {
+		t.Error("nil redactor masks keys")
+	}
+}
+
+func TestRedactorValue(t *testing.T) {
+	r := newTestRedactor(t)
+	req := loginReq{Username: "admin", Password: "123456", Email: "a@b.com", secret: "x"}
+	tests := []struct {
+		name string
+		key  string
+		val  interface{}
+		want interface{}
+	}{
+		{"sensitive key", "password", 123456, defaultRedactMask},
+		{"string pattern", "msg", "mail to a@b.com", "mail to " + defaultRedactMask},
+		{"plain string", "msg", "hello", "hello"},
+		{"int", "count", 3, 3},
+		{"error", "err", errors.New("send to a@b.com failed"), errors.New("send to ****** failed")},
+		{
+			"map",
+			"req",
+			map[string]interface{}{"username": "admin", "userPassword": "123456"},
+			map[string]interface{}{"username": "admin", "userPassword": defaultRedactMask},
+		},
+		{
+			"struct",
+			"req",
+			req,
+			map[string]interface{}{"username": "admin", "password": defaultRedactMask, "email": defaultRedactMask, "tags": nil},
+		},
+		{
+			"pointer",
+			"req",
+			&req,
+			map[string]interface{}{"username": "admin", "password": defaultRedactMask, "email": defaultRedactMask, "tags": nil},
+		},
+		{
+			"nested",
+			"profile",
+			profile{Name: "n", Account: loginReq{Password: "p", Tags: []string{"a@b.com"}}, Logins: []loginReq{{Password: "q"}}},
+			map[string]interface{}{
+				"name": "n",
+				"account": map[string]interface{}{
+					"username": "", "password": defaultRedactMask, "email": "", "tags": []interface{}{defaultRedactMask},
+				},
+				"logins": []interface{}{
+					map[string]interface{}{"username": "", "password": defaultRedactMask, "email": "", "tags": nil},
+				},
+			},
+		},
+		{
+			"string map",
+			"headers",
+			map[string]string{"Authorization-Password": "x", "Accept": "json"},
+			map[string]interface{}{"Authorization-Password": defaultRedactMask, "Accept": "json"},
+		},
+		{"slice", "emails", []string{"a@b.com", "c"}, []interface{}{defaultRedactMask, "c"}},
+		{"bytes", "body", []byte("a@b.com"), []byte("a@b.com")},
+		{"nil", "req", nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := r.Value(tt.key, tt.val); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Value() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedactorValueUnchanged(t *testing.T) {
+	r := newTestRedactor(t)
+	// values without sensitive data keep their type
+	tests := []interface{}{
+		struct{ Name string }{"admin"},
+		&struct{ Name string }{"admin"},
+		[]int{1, 2},
+		map[string]int{"a": 1},
+	}
+	for _, val := range tests {
+		if got := r.Value("val", val); !reflect.DeepEqual(got, val) {
+			t.Errorf("Value(%#v) = %#v", val, got)
+		}
+	}
+}
+
+func TestRedactorString(t *testing.T) {
+	r := newTestRedactor(t)
+	tests := []struct {
+		name string
+		s    string
+		want string
+	}{
+		{"email", "user a@b.com logged in", "user ****** logged in"},
+		{"card", "card 4111 1111 1111 1111 paid", "card ****** paid"},
+		{"card with dashes", "4111-1111-1111-1111", "******"},
+		{"luhn fails", "card 4111111111111112", "card 4111111111111112"},
+		{"millisecond timestamp", "at 1700000000000", "at 1700000000000"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := r.String(tt.s); got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedactorSql(t *testing.T) {
+	r := newTestRedactor(t)
+	tests := []struct {
+		name string
+		sql  string
+		want string
+	}{
+		{
+			"insert",
+			"INSERT INTO `user` (`username`,`mobile`,`password`) VALUES ('a','138','p'),('b','139','q')",
+			"INSERT INTO `user` (`username`,`mobile`,`password`) VALUES ('a','******','******'),('b','******','******')",
+		},
+		{
+			"insert on duplicate",
+			"INSERT INTO user (username, mobile) VALUES ('a', '138') ON DUPLICATE KEY UPDATE mobile='138'",
+			"INSERT INTO user (username, mobile) VALUES ('a', '******') ON DUPLICATE KEY UPDATE mobile='******'",
+		},
+		{
+			"compare",
+			"SELECT * FROM user WHERE `mobile` = '138' AND username = 'a'",
+			"SELECT * FROM user WHERE `mobile` = '******' AND username = 'a'",
+		},
+		{
+			"like",
+			"SELECT * FROM user WHERE mobile LIKE '%138%'",
+			"SELECT * FROM user WHERE mobile LIKE '******'",
+		},
+		{
+			"in",
+			"SELECT * FROM user WHERE mobile IN ('138','139')",
+			"SELECT * FROM user WHERE mobile IN ('******')",
+		},
+		{
+			"other columns",
+			"SELECT * FROM user WHERE username = 'a'",
+			"SELECT * FROM user WHERE username = 'a'",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := r.Sql(tt.sql); got != tt.want {
+				t.Errorf("Sql() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewRedactorInvalidPattern(t *testing.T) {
+	if _, err := NewRedactor(RedactOptions{Patterns: []string{"("}}); err == nil {
+		t.Error("invalid pattern is accepted")
+	}
+}
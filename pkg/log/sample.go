@@ -0,0 +1,88 @@
+package log
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// samplerCounters is the number of counters per level, keys sharing a counter share its budget
+const samplerCounters = 4096
+
+// SampleOptions works like zap's sampler: in every Tick the first First records of a key are logged,
+// then every Thereafter-th one(0 drops the rest)
+type SampleOptions struct {
+	// Tick defaults to 1 second
+	Tick       time.Duration
+	First      uint64
+	Thereafter uint64
+}
+
+// Sampler limits records of the same key, errors and above are always kept,
+// a kept record carries the number of records of its key dropped before it
+type Sampler struct {
+	tick       time.Duration
+	first      uint64
+	thereafter uint64
+	// counters of WarnLevel to TraceLevel
+	counters [TraceLevel - ErrorLevel][samplerCounters]samplerCounter
+}
+
+type samplerCounter struct {
+	resetAt atomic.Int64
+	count   atomic.Uint64
+	dropped atomic.Uint64
+}
+
+func NewSampler(ops SampleOptions) *Sampler {
+	if ops.Tick <= 0 {
+		ops.Tick = time.Second
+	}
+	return &Sampler{
+		tick:       ops.Tick,
+		first:      ops.First,
+		thereafter: ops.Thereafter,
+	}
+}
+
+// Sample reports whether the record of key is kept and how many records of key were dropped since the last kept one
+func (s *Sampler) Sample(level Level, key string) (bool, uint64) {
+	if s == nil || level <= ErrorLevel || level > TraceLevel {
+		return true, 0
+	}
+	c := &s.counters[level-WarnLevel][fnv32a(key)%samplerCounters]
+	n := c.inc(time.Now(), s.tick)
+	if n > s.first && (s.thereafter == 0 || (n-s.first)%s.thereafter != 0) {
+		c.dropped.Add(1)
+		return false, 0
+	}
+	return true, c.dropped.Swap(0)
+}
+
+// inc counts the record in the current tick, the counter restarts when the tick is over
+func (c *samplerCounter) inc(t time.Time, tick time.Duration) uint64 {
+	now := t.UnixNano()
+	resetAt := c.resetAt.Load()
+	if resetAt > now {
+		return c.count.Add(1)
+	}
+	c.count.Store(1)
+	if !c.resetAt.CompareAndSwap(resetAt, now+tick.Nanoseconds()) {
+		// another goroutine restarted the counter
+		return c.count.Add(1)
+	}
+	return 1
+}
+
+// fnv32a hashes key without allocations
+func fnv32a(key string) uint32 {
+	const (
+		offset32 = 2166136261
+		prime32  = 16777619
+	)
+	h := uint32(offset32)
+	for i := 0; i < len(key); i++ {
+		h ^= uint32(key[i])
+		h *= prime32
+	}
+	return h
+}
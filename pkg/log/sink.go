@@ -0,0 +1,89 @@
+package log
+
+import (
+	"io"
+	"os"
+	"sync"
+
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// Sink is an output of the logger, Level caps records of the sink(e.g. ErrorLevel for an error file),
+// TraceLevel writes every record enabled by the runtime levels
+type Sink struct {
+	Output io.Writer
+	Level  Level
+	Json   bool
+}
+
+// RotateOptions limits a log file, zero keeps the default of lumberjack(100MB, no age/backup limit)
+type RotateOptions struct {
+	// MaxSize is megabytes of a file before it is rotated
+	MaxSize int
+	// MaxAge is days to keep rotated files
+	MaxAge     int
+	MaxBackups int
+	Compress   bool
+}
+
+// NewRotateFile returns a writer of filename which is rotated by size, directories are created if missing
+func NewRotateFile(filename string, ops RotateOptions) io.WriteCloser {
+	return &lumberjack.Logger{
+		Filename:   filename,
+		MaxSize:    ops.MaxSize,
+		MaxAge:     ops.MaxAge,
+		MaxBackups: ops.MaxBackups,
+		Compress:   ops.Compress,
+		LocalTime:  true,
+	}
+}
+
+// sinks returns the configured sinks, or one sink of output(stdout by default) with the logger level
+func (ops Options) sinks() []Sink {
+	if len(ops.sinkList) > 0 {
+		return ops.sinkList
+	}
+	output := ops.output
+	if output == nil {
+		output = os.Stdout
+	}
+	return []Sink{{Output: output, Level: TraceLevel, Json: ops.json}}
+}
+
+// colored is true for terminals, files are written without escape codes
+func colored(w io.Writer) bool {
+	return w == os.Stdout || w == os.Stderr
+}
+
+// syncSinks flushes outputs before exit, terminals are skipped(e.g. sync /dev/stdout: invalid argument)
+func syncSinks(sinks []Sink) error {
+	var err error
+	for _, sink := range sinks {
+		var e error
+		switch w := sink.Output.(type) {
+		case *lumberjack.Logger:
+			// lumberjack writes without buffer, closing stops the compression goroutine and reopens on the next write
+			e = w.Close()
+		case interface{ Sync() error }:
+			if !colored(sink.Output) {
+				e = w.Sync()
+			}
+		}
+		if e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// lockedWriter serializes writes of a sink shared by goroutines
+type lockedWriter struct {
+	lock sync.Mutex
+	w    io.Writer
+}
+
+func (l *lockedWriter) Write(p []byte) (int, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.w.Write(p)
+}
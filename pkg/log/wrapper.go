@@ -2,7 +2,7 @@ package log
 
 import (
 	"context"
-	"os"
+	"fmt"
 
 	"github.com/ppxb/oreo-admin-go/pkg/constant"
 	"github.com/ppxb/oreo-admin-go/pkg/tracing"
@@ -20,18 +20,64 @@ func NewWrapper(l Interface) *Wrapper {
 	}
 }
 
-func (w *Wrapper) logWithLevel(level Level, args ...interface{}) {
-	if !w.log.Options().level.Enabled(level) {
+// logWithLevel logs msg with keyvals as alternating keys and values,
+// a key without value is logged under BadKey
+func (w *Wrapper) logWithLevel(level Level, msg string, keyvals ...interface{}) {
+	if !w.enabled(level, keyvals) {
 		return
 	}
 	ns := w.prepareFields()
-	if len(args) > 1 {
-		if format, ok := args[0].(string); ok {
-			w.log.WithFields(ns).Logf(level, format, args[1:]...)
-			return
+	if !sample(w.log.Options().sampler, level, msg, ns) {
+		return
+	}
+	appendKeyvals(ns, keyvals)
+	w.write(level, ns, msg)
+}
+
+func (w *Wrapper) logfWithLevel(level Level, format string, args ...interface{}) {
+	if !w.enabled(level, nil) {
+		return
+	}
+	ns := w.prepareFields()
+	if !sample(w.log.Options().sampler, level, format, ns) {
+		return
+	}
+	w.write(level, ns, fmt.Sprintf(format, args...))
+}
+
+// sample asks s whether the record of key is kept, and adds the count of dropped records to fields
+func sample(s *Sampler, level Level, key string, fields map[string]interface{}) bool {
+	kept, dropped := s.Sample(level, key)
+	if dropped > 0 {
+		fields[constant.LogDroppedKey] = dropped
+	}
+	return kept
+}
+
+// write redacts fields and msg before they reach the backend
+func (w *Wrapper) write(level Level, fields map[string]interface{}, msg string) {
+	if r := w.log.Options().redactor; r != nil {
+		for k, v := range fields {
+			fields[k] = r.Value(k, v)
 		}
+		msg = r.String(msg)
+	}
+	w.log.WithFields(fields).Log(level, msg)
+}
+
+// enabled asks the runtime levels with the component and request id of the record
+func (w *Wrapper) enabled(level Level, keyvals []interface{}) bool {
+	component, _ := w.fields[constant.LogComponentKey].(string)
+	for i := 0; i+1 < len(keyvals); i += 2 {
+		if keyvals[i] == constant.LogComponentKey {
+			component, _ = keyvals[i+1].(string)
+		}
+	}
+	requestId, _ := w.fields[constant.MiddlewareRequestIdCtxKey].(string)
+	if requestId == "" {
+		requestId, _ = w.fields[constant.MiddlewareTraceIdCtxKey].(string)
 	}
-	w.log.WithFields(ns).Log(level, args...)
+	return w.log.Options().levels.Enabled(level, component, requestId)
 }
 
 func (w *Wrapper) prepareFields() map[string]interface{} {
@@ -42,29 +88,81 @@ func (w *Wrapper) prepareFields() map[string]interface{} {
 	return ns
 }
 
-func (w *Wrapper) Trace(args ...interface{}) {
-	w.logWithLevel(TraceLevel, args...)
+func (w *Wrapper) Trace(msg string, keyvals ...interface{}) {
+	w.logWithLevel(TraceLevel, msg, keyvals...)
+}
+
+func (w *Wrapper) Debug(msg string, keyvals ...interface{}) {
+	w.logWithLevel(DebugLevel, msg, keyvals...)
+}
+
+func (w *Wrapper) Info(msg string, keyvals ...interface{}) {
+	w.logWithLevel(InfoLevel, msg, keyvals...)
+}
+
+func (w *Wrapper) Warn(msg string, keyvals ...interface{}) {
+	w.logWithLevel(WarnLevel, msg, keyvals...)
 }
 
-func (w *Wrapper) Debug(args ...interface{}) {
-	w.logWithLevel(DebugLevel, args...)
+func (w *Wrapper) Error(msg string, keyvals ...interface{}) {
+	w.logWithLevel(ErrorLevel, msg, keyvals...)
 }
 
-func (w *Wrapper) Info(args ...interface{}) {
-	w.logWithLevel(InfoLevel, args...)
+// Fatal logs msg, runs the exit hooks, flushes the sinks and exits
+func (w *Wrapper) Fatal(msg string, keyvals ...interface{}) {
+	w.logWithLevel(FatalLevel, msg, keyvals...)
+	exit()
 }
 
-func (w *Wrapper) Warn(args ...interface{}) {
-	w.logWithLevel(WarnLevel, args...)
+// Panic logs msg and panics with it
+func (w *Wrapper) Panic(msg string, keyvals ...interface{}) {
+	w.logWithLevel(PanicLevel, msg, keyvals...)
+	panic(msg)
 }
 
-func (w *Wrapper) Error(args ...interface{}) {
-	w.logWithLevel(ErrorLevel, args...)
+func (w *Wrapper) Tracef(format string, args ...interface{}) {
+	w.logfWithLevel(TraceLevel, format, args...)
 }
 
-func (w *Wrapper) Fatal(args ...interface{}) {
-	w.logWithLevel(FatalLevel, args...)
-	os.Exit(1)
+func (w *Wrapper) Debugf(format string, args ...interface{}) {
+	w.logfWithLevel(DebugLevel, format, args...)
+}
+
+func (w *Wrapper) Infof(format string, args ...interface{}) {
+	w.logfWithLevel(InfoLevel, format, args...)
+}
+
+func (w *Wrapper) Warnf(format string, args ...interface{}) {
+	w.logfWithLevel(WarnLevel, format, args...)
+}
+
+func (w *Wrapper) Errorf(format string, args ...interface{}) {
+	w.logfWithLevel(ErrorLevel, format, args...)
+}
+
+func (w *Wrapper) Fatalf(format string, args ...interface{}) {
+	w.logfWithLevel(FatalLevel, format, args...)
+	exit()
+}
+
+func (w *Wrapper) Panicf(format string, args ...interface{}) {
+	w.logfWithLevel(PanicLevel, format, args...)
+	panic(fmt.Sprintf(format, args...))
+}
+
+// With adds keyvals to every record of the returned wrapper
+func (w *Wrapper) With(keyvals ...interface{}) *Wrapper {
+	ns := copyFields(w.fields)
+	appendKeyvals(ns, keyvals)
+	return &Wrapper{
+		log:    w.log,
+		fields: ns,
+	}
+}
+
+// WithComponent tags records with the module that writes them, e.g. init, job, binlog
+func (w *Wrapper) WithComponent(name string) *Wrapper {
+	return w.With(constant.LogComponentKey, name)
 }
 
 func (w *Wrapper) WithError(err error) *Wrapper {
@@ -99,6 +197,16 @@ func (w *Wrapper) WithContext(ctx context.Context) *Wrapper {
 	} else {
 		ns[constant.MiddlewareRequestIdCtxKey] = requestId
 	}
+	// set by middleware.ClientIp
+	ctx = tracing.RealCtx(ctx)
+	if ip, ok := ctx.Value(constant.MiddlewareClientIpCtxKey).(string); ok && ip != "" {
+		ns[constant.MiddlewareClientIpCtxKey] = ip
+	}
+	if l, ok := ctx.Value(constant.MiddlewareLocationCtxKey).(fmt.Stringer); ok {
+		if s := l.String(); s != "" {
+			ns[constant.MiddlewareLocationCtxKey] = s
+		}
+	}
 	return &Wrapper{
 		log:    w.log,
 		fields: ns,
@@ -112,3 +220,13 @@ func copyFields(src map[string]interface{}) map[string]interface{} {
 	}
 	return dst
 }
+
+func appendKeyvals(fields map[string]interface{}, keyvals []interface{}) {
+	for i := 0; i < len(keyvals); i += 2 {
+		if i == len(keyvals)-1 {
+			fields[constant.LogBadKey] = keyvals[i]
+			break
+		}
+		fields[fmt.Sprint(keyvals[i])] = keyvals[i+1]
+	}
+}
@@ -2,7 +2,7 @@ package log
 
 import (
 	"fmt"
-	"os"
+	"sort"
 	"time"
 
 	"github.com/dromara/carbon/v2"
@@ -11,31 +11,45 @@ import (
 )
 
 type zapLog struct {
-	log *zap.Logger
-	ops Options
+	log   *zap.Logger
+	ops   Options
+	sinks []Sink
 }
 
 func newZap(ops *Options) *zapLog {
+	sinks := ops.sinks()
+	cores := make([]zapcore.Core, 0, len(sinks))
+	for _, sink := range sinks {
+		cores = append(cores, zapcore.NewCore(
+			zapEncoder(sink),
+			zapcore.Lock(zapcore.AddSync(sink.Output)),
+			loggerToZapLevel(sink.Level),
+		))
+	}
+	l := zapLog{
+		log:   zap.New(zapcore.NewTee(cores...)),
+		ops:   *ops,
+		sinks: sinks,
+	}
+	return &l
+}
+
+func zapEncoder(sink Sink) zapcore.Encoder {
 	enConfig := zap.NewProductionEncoderConfig()
-	enConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
+	enConfig.EncodeDuration = zapcore.StringDurationEncoder
 	enConfig.EncodeTime = func(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
 		enc.AppendString(carbon.CreateFromStdTime(t).ToRfc3339String())
 	}
-	encoder := zapcore.NewConsoleEncoder(enConfig)
-	if ops.json {
-		enConfig.EncodeLevel = zapcore.LowercaseLevelEncoder
-		encoder = zapcore.NewJSONEncoder(enConfig)
+	if sink.Json {
+		enConfig.EncodeLevel = zapLevelEncoder(zapcore.LowercaseLevelEncoder, "trace")
+		return zapcore.NewJSONEncoder(enConfig)
 	}
-	core := zapcore.NewCore(
-		encoder,
-		zapcore.NewMultiWriteSyncer(zapcore.AddSync(os.Stdout)),
-		loggerToZapLevel(ops.level),
-	)
-	l := zapLog{
-		log: zap.New(core),
-		ops: *ops,
+	enConfig.EncodeLevel = zapLevelEncoder(zapcore.CapitalLevelEncoder, "TRACE")
+	if colored(sink.Output) {
+		// same color as debug
+		enConfig.EncodeLevel = zapLevelEncoder(zapcore.CapitalColorLevelEncoder, "\x1b[35mTRACE\x1b[0m")
 	}
-	return &l
+	return zapcore.NewConsoleEncoder(enConfig)
 }
 
 func (l *zapLog) Options() Options {
@@ -43,54 +57,58 @@ func (l *zapLog) Options() Options {
 }
 
 func (l *zapLog) WithFields(fields map[string]interface{}) Interface {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	// stable order for console output
+	sort.Strings(keys)
 	data := make([]zap.Field, 0, len(fields))
-	for k, v := range fields {
-		data = append(data, zap.Any(k, v))
+	for _, k := range keys {
+		data = append(data, zap.Any(k, fields[k]))
 	}
 	ll := &zapLog{
-		log: l.log.With(data...),
-		ops: l.ops,
+		log:   l.log.With(data...),
+		ops:   l.ops,
+		sinks: l.sinks,
 	}
 	return ll
 }
 
 func (l *zapLog) Log(level Level, args ...interface{}) {
-	ll := loggerToZapLevel(level)
-	msg := fmt.Sprint(args...)
-	switch ll {
-	case zap.DebugLevel:
-		l.log.Debug(msg)
-	case zap.InfoLevel:
-		l.log.Info(msg)
-	case zap.WarnLevel:
-		l.log.Warn(msg)
-	case zap.ErrorLevel:
-		l.log.Error(msg)
-	case zap.FatalLevel:
-		l.log.Fatal(msg)
-	}
+	l.write(level, fmt.Sprint(args...))
 }
 
 func (l *zapLog) Logf(level Level, format string, args ...interface{}) {
-	ll := loggerToZapLevel(level)
-	msg := fmt.Sprintf(format, args...)
-	switch ll {
-	case zap.DebugLevel:
-		l.log.Debug(msg)
-	case zap.InfoLevel:
-		l.log.Info(msg)
-	case zap.WarnLevel:
-		l.log.Warn(msg)
-	case zap.ErrorLevel:
-		l.log.Error(msg)
-	case zap.FatalLevel:
-		l.log.Fatal(msg)
+	l.write(level, fmt.Sprintf(format, args...))
+}
+
+// write goes to the core directly, zap.Logger would panic or exit by itself on Panic/Fatal,
+// which is left to Wrapper so that every backend behaves the same
+func (l *zapLog) write(level Level, msg string) {
+	ent := zapcore.Entry{
+		Level:   loggerToZapLevel(level),
+		Time:    time.Now(),
+		Message: msg,
+	}
+	if ce := l.log.Core().Check(ent, nil); ce != nil {
+		ce.Write()
 	}
 }
 
+func (l *zapLog) Sync() error {
+	// zap has no buffered writer here, the sinks are flushed directly
+	return syncSinks(l.sinks)
+}
+
+// zapTraceLevel is below zap.DebugLevel since zap has no trace level
+const zapTraceLevel = zapcore.DebugLevel - 1
+
 func loggerToZapLevel(level Level) zapcore.Level {
 	switch level {
-	case TraceLevel, DebugLevel:
+	case TraceLevel:
+		return zapTraceLevel
+	case DebugLevel:
 		return zap.DebugLevel
 	case InfoLevel:
 		return zap.InfoLevel
@@ -100,7 +118,20 @@ func loggerToZapLevel(level Level) zapcore.Level {
 		return zap.ErrorLevel
 	case FatalLevel:
 		return zap.FatalLevel
+	case PanicLevel:
+		return zap.PanicLevel
 	default:
 		return zap.InfoLevel
 	}
 }
+
+// zapLevelEncoder names zapTraceLevel, which zap would encode as Level(-2)
+func zapLevelEncoder(enc zapcore.LevelEncoder, trace string) zapcore.LevelEncoder {
+	return func(l zapcore.Level, pae zapcore.PrimitiveArrayEncoder) {
+		if l == zapTraceLevel {
+			pae.AppendString(trace)
+			return
+		}
+		enc(l, pae)
+	}
+}
@@ -0,0 +1,95 @@
+package metrics
+
+import (
+	"database/sql"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// poolCollector reads sql.DB pool stats of every connection on scrape
+type poolCollector struct {
+	stats        func() map[string]sql.DBStats
+	open         *prometheus.Desc
+	inUse        *prometheus.Desc
+	idle         *prometheus.Desc
+	maxOpen      *prometheus.Desc
+	waitCount    *prometheus.Desc
+	waitDuration *prometheus.Desc
+	closed       *prometheus.Desc
+}
+
+// RegisterPool collects pool stats of stats, which are keyed by the connection name(e.g. primary, replica-0)
+func RegisterPool(stats func() map[string]sql.DBStats) error {
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "db_pool", name), help, []string{"db"}, nil)
+	}
+	return Registry.Register(&poolCollector{
+		stats:        stats,
+		open:         desc("open_connections", "Number of established connections."),
+		inUse:        desc("in_use_connections", "Number of connections in use."),
+		idle:         desc("idle_connections", "Number of idle connections."),
+		maxOpen:      desc("max_open_connections", "Maximum number of open connections."),
+		waitCount:    desc("wait_total", "Number of waits for a connection."),
+		waitDuration: desc("wait_seconds_total", "Time blocked waiting for a connection."),
+		closed:       desc("closed_total", "Number of connections closed by max idle, idle time or lifetime."),
+	})
+}
+
+func (c *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.open
+	ch <- c.inUse
+	ch <- c.idle
+	ch <- c.maxOpen
+	ch <- c.waitCount
+	ch <- c.waitDuration
+	ch <- c.closed
+}
+
+func (c *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	for name, s := range c.stats() {
+		ch <- prometheus.MustNewConstMetric(c.open, prometheus.GaugeValue, float64(s.OpenConnections), name)
+		ch <- prometheus.MustNewConstMetric(c.inUse, prometheus.GaugeValue, float64(s.InUse), name)
+		ch <- prometheus.MustNewConstMetric(c.idle, prometheus.GaugeValue, float64(s.Idle), name)
+		ch <- prometheus.MustNewConstMetric(c.maxOpen, prometheus.GaugeValue, float64(s.MaxOpenConnections), name)
+		ch <- prometheus.MustNewConstMetric(c.waitCount, prometheus.CounterValue, float64(s.WaitCount), name)
+		ch <- prometheus.MustNewConstMetric(c.waitDuration, prometheus.CounterValue, s.WaitDuration.Seconds(), name)
+		ch <- prometheus.MustNewConstMetric(c.closed, prometheus.CounterValue, float64(s.MaxIdleClosed+s.MaxIdleTimeClosed+s.MaxLifetimeClosed), name)
+	}
+}
+
+// queueCollector reads task counts of job queues on scrape
+type queueCollector struct {
+	sizes func() (map[string]map[string]int, error)
+	size  *prometheus.Desc
+	up    *prometheus.Desc
+}
+
+// RegisterQueues collects queue depth of sizes, which returns counts by queue and task state
+func RegisterQueues(sizes func() (map[string]map[string]int, error)) error {
+	return Registry.Register(&queueCollector{
+		sizes: sizes,
+		size: prometheus.NewDesc(prometheus.BuildFQName(namespace, "job", "queue_tasks"),
+			"Number of tasks by queue and state.", []string{"queue", "state"}, nil),
+		up: prometheus.NewDesc(prometheus.BuildFQName(namespace, "job", "queue_up"),
+			"Whether the queues could be inspected.", nil, nil),
+	})
+}
+
+func (c *queueCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.size
+	ch <- c.up
+}
+
+func (c *queueCollector) Collect(ch chan<- prometheus.Metric) {
+	queues, err := c.sizes()
+	if err != nil {
+		ch <- prometheus.MustNewConstMetric(c.up, prometheus.GaugeValue, 0)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(c.up, prometheus.GaugeValue, 1)
+	for queue, states := range queues {
+		for state, n := range states {
+			ch <- prometheus.MustNewConstMetric(c.size, prometheus.GaugeValue, float64(n), queue, state)
+		}
+	}
+}
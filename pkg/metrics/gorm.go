@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+const gormStartKey = "oreo:metrics_start"
+
+// GormPlugin observes DbDuration of every statement, register it by db.Use
+type GormPlugin struct{}
+
+func (GormPlugin) Name() string {
+	return "oreo:metrics"
+}
+
+func (GormPlugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	for _, err := range []error{
+		cb.Create().Before("*").Register("oreo:metrics_before", gormBefore),
+		cb.Create().After("*").Register("oreo:metrics_after", gormAfter("create")),
+		cb.Query().Before("*").Register("oreo:metrics_before", gormBefore),
+		cb.Query().After("*").Register("oreo:metrics_after", gormAfter("query")),
+		cb.Update().Before("*").Register("oreo:metrics_before", gormBefore),
+		cb.Update().After("*").Register("oreo:metrics_after", gormAfter("update")),
+		cb.Delete().Before("*").Register("oreo:metrics_before", gormBefore),
+		cb.Delete().After("*").Register("oreo:metrics_after", gormAfter("delete")),
+		cb.Row().Before("*").Register("oreo:metrics_before", gormBefore),
+		cb.Row().After("*").Register("oreo:metrics_after", gormAfter("row")),
+		cb.Raw().Before("*").Register("oreo:metrics_before", gormBefore),
+		cb.Raw().After("*").Register("oreo:metrics_after", gormAfter("raw")),
+	} {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func gormBefore(db *gorm.DB) {
+	db.InstanceSet(gormStartKey, time.Now())
+}
+
+func gormAfter(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		v, ok := db.InstanceGet(gormStartKey)
+		if !ok {
+			return
+		}
+		start, ok := v.(time.Time)
+		if !ok {
+			return
+		}
+		table := db.Statement.Table
+		if table == "" {
+			table = "unknown"
+		}
+		err := db.Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			err = nil
+		}
+		DbDuration.WithLabelValues(table, operation, Status(err)).Observe(time.Since(start).Seconds())
+	}
+}
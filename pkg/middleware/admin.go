@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ppxb/oreo-admin-go/pkg/resp"
+)
+
+// AdminToken guards admin apis with a static bearer token, they are forbidden if token is empty
+func AdminToken(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token == "" {
+			resp.FailWithCode(c, resp.Forbidden)
+			return
+		}
+		s := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(s), []byte(token)) != 1 {
+			resp.FailWithCode(c, resp.Unauthorized)
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ppxb/oreo-admin-go/pkg/constant"
+	"github.com/ppxb/oreo-admin-go/pkg/geo"
+)
+
+// ClientIp puts the client ip and its location into the request context,
+// forwarded headers are only trusted from proxies set by gin.Engine.SetTrustedProxies,
+// the location is looked up when it is first used(see geo.Lazy) and skipped if g is nil
+func ClientIp(g *geo.Geo) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ip := c.ClientIP()
+		ctx := context.WithValue(c.Request.Context(), constant.MiddlewareClientIpCtxKey, ip)
+		if g != nil {
+			ctx = context.WithValue(ctx, constant.MiddlewareLocationCtxKey, g.Lazy(ctx, ip))
+		}
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
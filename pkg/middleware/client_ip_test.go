@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ppxb/oreo-admin-go/pkg/geo"
+	"github.com/ppxb/oreo-admin-go/pkg/log"
+)
+
+func TestClientIpLooksUpOnUse(t *testing.T) {
+	var hits atomic.Int32
+	amap := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		hits.Add(1)
+		_, _ = w.Write([]byte(`{"status":"1","province":"浙江省","city":"杭州市","adcode":"330100"}`))
+	}))
+	defer amap.Close()
+	g, err := geo.New(geo.WithAmapKey("key"), geo.WithAmapUrl(amap.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(RequestId(), ClientIp(g))
+	r.GET("/metrics", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	r.GET("/login", func(c *gin.Context) {
+		log.WithContext(c.Request.Context()).Info("Login")
+		l, _ := geo.FromContext(c.Request.Context())
+		c.String(http.StatusOK, l.String())
+	})
+
+	tests := []struct {
+		path     string
+		wantHits int32
+		wantBody string
+	}{
+		{"/metrics", 0, ""},
+		{"/login", 1, "浙江省 杭州市"},
+	}
+	for _, tt := range tests {
+		hits.Store(0)
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		req.RemoteAddr = "115.236.1.1:1234"
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusOK || w.Body.String() != tt.wantBody {
+			t.Errorf("%s: %d %q, want %q", tt.path, w.Code, w.Body.String(), tt.wantBody)
+		}
+		// the log line and FromContext share one lookup
+		if n := hits.Load(); n != tt.wantHits {
+			t.Errorf("%s: amap hits = %d, want %d", tt.path, n, tt.wantHits)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+
+	"github.com/ppxb/oreo-admin-go/pkg/crypt"
+	"github.com/ppxb/oreo-admin-go/pkg/log"
+	"github.com/ppxb/oreo-admin-go/pkg/resp"
+)
+
+// KeyIdField is the json field naming the key which encrypted the fields
+const KeyIdField = "keyId"
+
+// Decrypt replaces the encrypted string fields of a json body with their values so that handlers bind plaintext,
+// a body without keyId is passed as is unless required, all fields are skipped if keyring is nil
+func Decrypt(keyring *crypt.Keyring, required bool, fields ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if keyring == nil {
+			c.Next()
+			return
+		}
+		data, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			decryptFailed(c, err)
+			return
+		}
+		var body map[string]json.RawMessage
+		if err = json.Unmarshal(data, &body); err != nil {
+			// leave the error to the binding of the handler
+			c.Request.Body = io.NopCloser(bytes.NewReader(data))
+			c.Next()
+			return
+		}
+		var keyId string
+		if raw, ok := body[KeyIdField]; ok {
+			_ = json.Unmarshal(raw, &keyId)
+		}
+		if keyId == "" {
+			if required {
+				decryptFailed(c, errors.Wrap(crypt.ErrInvalid, "keyId is empty"))
+				return
+			}
+			c.Request.Body = io.NopCloser(bytes.NewReader(data))
+			c.Next()
+			return
+		}
+		for _, field := range fields {
+			raw, ok := body[field]
+			if !ok {
+				continue
+			}
+			var ciphertext string
+			if err = json.Unmarshal(raw, &ciphertext); err != nil {
+				decryptFailed(c, errors.Wrapf(crypt.ErrInvalid, "%s is not a string", field))
+				return
+			}
+			value, err := keyring.Decrypt(c, keyId, ciphertext)
+			if err != nil {
+				decryptFailed(c, errors.WithMessage(err, field))
+				return
+			}
+			body[field], _ = json.Marshal(value)
+		}
+		data, _ = json.Marshal(body)
+		c.Request.Body = io.NopCloser(bytes.NewReader(data))
+		c.Request.ContentLength = int64(len(data))
+		c.Next()
+	}
+}
+
+// decryptFailed only tells the kind of the error, e.g. clients fetch the public key again on an unknown key id
+func decryptFailed(c *gin.Context, err error) {
+	log.WithContext(c).WithError(err).WithComponent("rsa").Warn("Decrypt request failed", "path", c.FullPath())
+	msg := resp.NotOkMsg
+	for _, e := range []error{crypt.ErrUnknownKey, crypt.ErrInvalid, crypt.ErrExpired, crypt.ErrReplayed} {
+		if errors.Is(err, e) {
+			msg = e.Error()
+			break
+		}
+	}
+	resp.FailWithMsg(c, msg)
+	c.Abort()
+}
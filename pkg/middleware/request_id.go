@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ppxb/oreo-admin-go/pkg/constant"
+	"github.com/ppxb/oreo-admin-go/pkg/tracing"
+)
+
+const RequestIdHeader = "X-Request-Id"
+
+// RequestId puts the request id into the request context, an incoming X-Request-Id is kept
+func RequestId() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		if id := c.GetHeader(RequestIdHeader); id != "" {
+			ctx = context.WithValue(ctx, constant.MiddlewareRequestIdCtxKey, id)
+		}
+		ctx = tracing.NewId(ctx)
+		c.Request = c.Request.WithContext(ctx)
+		requestId, _, _ := tracing.GetId(ctx)
+		c.Header(RequestIdHeader, requestId)
+		c.Next()
+	}
+}
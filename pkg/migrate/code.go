@@ -0,0 +1,77 @@
+package migrate
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+	migrate "github.com/rubenv/sql-migrate"
+	"gorm.io/gorm"
+)
+
+// CodeMigration is a migration written in go, for data backfills that can not be expressed in sql.
+// Id follows the sql file naming(e.g. 20251201093000-hash-password) so both kinds are ordered together
+type CodeMigration struct {
+	Id   string
+	Up   func(ctx context.Context, tx *gorm.DB) error
+	Down func(ctx context.Context, tx *gorm.DB) error
+}
+
+var (
+	codeMigrations = make(map[string]CodeMigration)
+	codeLock       sync.RWMutex
+)
+
+// Register adds a go migration to the default registry, usually called from init()
+func Register(m CodeMigration) {
+	codeLock.Lock()
+	defer codeLock.Unlock()
+
+	if m.Id == "" || m.Up == nil {
+		panic("code migration id and up function are required")
+	}
+	if _, ok := codeMigrations[m.Id]; ok {
+		panic(errors.Errorf("code migration %s is registered twice", m.Id))
+	}
+	codeMigrations[m.Id] = m
+}
+
+func registeredCodeMigrations() map[string]CodeMigration {
+	codeLock.RLock()
+	defer codeLock.RUnlock()
+
+	m := make(map[string]CodeMigration, len(codeMigrations))
+	for k, v := range codeMigrations {
+		m[k] = v
+	}
+	return m
+}
+
+// source merges the embedded sql files and the go migrations into one ordered list,
+// go migrations have no queries so sql-migrate only uses them for planning
+type source struct {
+	sql  migrate.MigrationSource
+	code map[string]CodeMigration
+}
+
+func (s source) FindMigrations() ([]*migrate.Migration, error) {
+	migrations, err := s.sql.FindMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	for id := range s.code {
+		for _, item := range migrations {
+			if item.Id == id {
+				return nil, errors.Errorf("migration %s exists in both sql files and go code", id)
+			}
+		}
+		migrations = append(migrations, &migrate.Migration{Id: id})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Less(migrations[j])
+	})
+	return migrations, nil
+}
@@ -0,0 +1,214 @@
+package migrate
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// unregister removes ids added by a test from the default registry
+func unregister(t *testing.T, ids ...string) {
+	t.Cleanup(func() {
+		codeLock.Lock()
+		defer codeLock.Unlock()
+		for _, id := range ids {
+			delete(codeMigrations, id)
+		}
+	})
+}
+
+func TestRegister(t *testing.T) {
+	up := func(context.Context, *gorm.DB) error { return nil }
+	unregister(t, "20260101000010-registered")
+	Register(CodeMigration{Id: "20260101000010-registered", Up: up})
+	if _, ok := registeredCodeMigrations()["20260101000010-registered"]; !ok {
+		t.Fatal("registered migration is missing")
+	}
+	if _, ok := getOptionsOrSetDefault(nil).codes["20260101000010-registered"]; !ok {
+		t.Error("default options miss the registered migration")
+	}
+
+	tests := []struct {
+		name string
+		m    CodeMigration
+	}{
+		{"duplicate id", CodeMigration{Id: "20260101000010-registered", Up: up}},
+		{"empty id", CodeMigration{Up: up}},
+		{"no up", CodeMigration{Id: "20260101000011-no-up"}},
+	}
+	for _, tt := range tests {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: Register did not panic", tt.name)
+				}
+			}()
+			Register(tt.m)
+		}()
+	}
+}
+
+func TestCodeMigrationSqlIdConflict(t *testing.T) {
+	options := sqliteOptions(t, WithCodeMigrations(CodeMigration{
+		Id: "20260101000001-a.sql",
+		Up: func(context.Context, *gorm.DB) error { return nil },
+	}))
+	if _, err := Up(options...); err == nil || !strings.Contains(err.Error(), "exists in both") {
+		t.Fatalf("got %v, want the id conflict", err)
+	}
+}
+
+// hasTables reports which of the tables exist in the migration transaction
+func hasTables(tx *gorm.DB, tables ...string) string {
+	var list []string
+	for _, table := range tables {
+		if tx.Migrator().HasTable(table) {
+			list = append(list, table)
+		}
+	}
+	return strings.Join(list, ",")
+}
+
+func TestCodeMigrationOrder(t *testing.T) {
+	var applied []string
+	code := func(id string) CodeMigration {
+		return CodeMigration{
+			Id: id,
+			Up: func(_ context.Context, tx *gorm.DB) error {
+				applied = append(applied, id+"("+hasTables(tx, "a", "c")+")")
+				return nil
+			},
+			Down: func(_ context.Context, tx *gorm.DB) error {
+				applied = append(applied, "down "+id)
+				return nil
+			},
+		}
+	}
+	options := sqliteOptions(t, WithCodeMigrations(code("20260101000002-b"), code("20260101000004-d")))
+
+	plans, err := Up(options...)
+	if err != nil {
+		t.Fatalf("up: %v", err)
+	}
+	var ids []string
+	for _, item := range plans {
+		ids = append(ids, item.Id)
+	}
+	if got, want := strings.Join(ids, " "), "20260101000001-a.sql 20260101000002-b 20260101000003-c.sql 20260101000004-d"; got != want {
+		t.Errorf("plans = %s, want %s", got, want)
+	}
+	// each go migration sees the sql migrations before it and none after it
+	if got, want := strings.Join(applied, " "), "20260101000002-b(a) 20260101000004-d(a,c)"; got != want {
+		t.Errorf("applied = %s, want %s", got, want)
+	}
+
+	// both kinds share the change table
+	ops := getOptionsOrSetDefault(nil)
+	for _, f := range options {
+		f(ops)
+	}
+	db, err := open(ops)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	rows, err := db.Query("SELECT id FROM " + ops.changeTable + " ORDER BY id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var recorded []string
+	for rows.Next() {
+		var id string
+		if err = rows.Scan(&id); err != nil {
+			t.Fatal(err)
+		}
+		recorded = append(recorded, id)
+	}
+	if got := strings.Join(recorded, " "); got != strings.Join(ids, " ") {
+		t.Errorf("change table = %s, want %s", got, strings.Join(ids, " "))
+	}
+
+	applied = nil
+	if _, err = Down(append(options, WithMax(2))...); err != nil {
+		t.Fatalf("down: %v", err)
+	}
+	if got, want := strings.Join(applied, " "), "down 20260101000004-d"; got != want {
+		t.Errorf("down applied = %s, want %s", got, want)
+	}
+	records, err := Status(options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, record := range records {
+		want := record.Id < "20260101000003"
+		if record.Applied != want {
+			t.Errorf("%s applied = %v, want %v", record.Id, record.Applied, want)
+		}
+	}
+}
+
+func TestCodeMigrationRollback(t *testing.T) {
+	options := sqliteOptions(t, WithCodeMigrations(
+		CodeMigration{
+			Id: "20260101000002-b",
+			Up: func(_ context.Context, tx *gorm.DB) error {
+				if err := tx.Exec("INSERT INTO a (id) VALUES (1)").Error; err != nil {
+					return err
+				}
+				return errors.New("backfill failed")
+			},
+		},
+	))
+	if _, err := Up(options...); err == nil || !strings.Contains(err.Error(), "backfill failed") {
+		t.Fatalf("got %v, want the error of the go migration", err)
+	}
+
+	ops := getOptionsOrSetDefault(nil)
+	for _, f := range options {
+		f(ops)
+	}
+	db, err := open(ops)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var n int
+	if err = db.QueryRow("SELECT COUNT(*) FROM a").Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Errorf("the write of the failed migration is committed, %d rows", n)
+	}
+
+	records, err := Status(options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]bool{
+		"20260101000001-a.sql": true,
+		"20260101000002-b":     false,
+		"20260101000003-c.sql": false,
+	}
+	for _, record := range records {
+		if want[record.Id] != record.Applied {
+			t.Errorf("%s applied = %v, want %v", record.Id, record.Applied, want[record.Id])
+		}
+	}
+}
+
+func TestCodeMigrationIrreversible(t *testing.T) {
+	options := sqliteOptions(t, WithCodeMigrations(CodeMigration{
+		Id: "20260101000004-d",
+		Up: func(context.Context, *gorm.DB) error { return nil },
+	}))
+	if _, err := Up(options...); err != nil {
+		t.Fatalf("up: %v", err)
+	}
+	if _, err := Down(options...); err == nil || !strings.Contains(err.Error(), "irreversible") {
+		t.Fatalf("got %v, want irreversible", err)
+	}
+}
@@ -0,0 +1,288 @@
+package migrate
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/go-gorp/gorp/v3"
+	"github.com/pkg/errors"
+	migrate "github.com/rubenv/sql-migrate"
+
+	"github.com/ppxb/oreo-admin-go/pkg/log"
+)
+
+const fileTemplate = `-- +migrate Up
+-- SQL in section 'Up' is executed when this migration is applied
+
+
+-- +migrate Down
+-- SQL in section 'Down' is executed when this migration is rolled back
+
+`
+
+var (
+	ErrNothingToRedo = errors.New("no applied migration to redo")
+	// ErrDatabaseNotExist is returned by status and dry runs, which never create the database
+	ErrDatabaseNotExist = errors.New("database does not exist")
+	fileNameRe          = regexp.MustCompile("[^a-z0-9]+")
+)
+
+type Record struct {
+	Id        string
+	Applied   bool
+	AppliedAt time.Time
+}
+
+type Plan struct {
+	Id        string
+	Direction string
+	Code      bool
+	Queries   []string
+}
+
+// Status lists every migration known to the source or the change table, in execution order,
+// it opens the existing database read only
+func Status(options ...func(*Options)) ([]Record, error) {
+	ops := getOptionsOrSetDefault(nil)
+	for _, f := range options {
+		f(ops)
+	}
+	ops.readOnly = true
+
+	db, err := openDatabase(ops)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	migrations, err := newSource(ops).FindMigrations()
+	if err != nil {
+		log.WithContext(ops.ctx).WithError(err).WithComponent("database").Error("Find migrations failed")
+		return nil, err
+	}
+
+	records, err := migrationRecords(ops, db)
+	if err != nil {
+		return nil, err
+	}
+
+	appliedAt := make(map[string]time.Time, len(records))
+	for _, record := range records {
+		appliedAt[record.Id] = record.AppliedAt
+	}
+
+	list := make([]Record, 0, len(migrations))
+	for _, migration := range migrations {
+		t, ok := appliedAt[migration.Id]
+		list = append(list, Record{
+			Id:        migration.Id,
+			Applied:   ok,
+			AppliedAt: t,
+		})
+		delete(appliedAt, migration.Id)
+	}
+	// records without a source file are kept so that they are not silently hidden
+	for _, record := range records {
+		if _, ok := appliedAt[record.Id]; ok {
+			list = append(list, Record{
+				Id:        record.Id,
+				Applied:   true,
+				AppliedAt: record.AppliedAt,
+			})
+		}
+	}
+	return list, nil
+}
+
+// Up applies pending migrations, WithMax limits the count(0 means all)
+func Up(options ...func(*Options)) ([]Plan, error) {
+	return execute(migrate.Up, options...)
+}
+
+// Down rolls back applied migrations, WithMax limits the count(default 1)
+func Down(options ...func(*Options)) ([]Plan, error) {
+	return execute(migrate.Down, append([]func(*Options){WithMax(1)}, options...)...)
+}
+
+// Redo rolls back the last applied migration and applies the same one again,
+// pending migrations older than it are left to Up
+func Redo(options ...func(*Options)) ([]Plan, error) {
+	ops := getOptionsOrSetDefault(nil)
+	for _, f := range options {
+		f(ops)
+	}
+	ops.readOnly = ops.dryRun
+
+	db, err := openDatabase(ops)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var plans []Plan
+	err = withAdvisoryLock(ops, db, func() error {
+		m, err := lastApplied(ops, db)
+		if err != nil {
+			return err
+		}
+		down := &migrate.PlannedMigration{Migration: m, Queries: m.Down, DisableTransaction: m.DisableTransactionDown}
+		up := &migrate.PlannedMigration{Migration: m, Queries: m.Up, DisableTransaction: m.DisableTransactionUp}
+		plans = []Plan{newPlan(ops, down, migrate.Down), newPlan(ops, up, migrate.Up)}
+		if ops.dryRun {
+			return nil
+		}
+		dbMap := &gorp.DbMap{Db: db, Dialect: migrate.MigrationDialects[ops.dialect.MigrateDialect()]}
+		if err = applyPlanned(ops, db, dbMap, []*migrate.PlannedMigration{down}, migrate.Down); err != nil {
+			return err
+		}
+		return applyPlanned(ops, db, dbMap, []*migrate.PlannedMigration{up}, migrate.Up)
+	})
+	return plans, err
+}
+
+// lastApplied finds the source of the newest record in the change table
+func lastApplied(ops *Options, db *sql.DB) (*migrate.Migration, error) {
+	records, err := migrationRecords(ops, db)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, ErrNothingToRedo
+	}
+	last := &migrate.Migration{Id: records[0].Id}
+	for _, record := range records[1:] {
+		if m := (&migrate.Migration{Id: record.Id}); last.Less(m) {
+			last = m
+		}
+	}
+
+	migrations, err := newSource(ops).FindMigrations()
+	if err != nil {
+		log.WithContext(ops.ctx).WithError(err).WithComponent("database").Error("Find migrations failed")
+		return nil, err
+	}
+	for _, m := range migrations {
+		if m.Id == last.Id {
+			return m, nil
+		}
+	}
+	return nil, errors.Errorf("applied migration %s is not found in source", last.Id)
+}
+
+// New creates an empty migration file in dir and returns its path
+func New(dir, name string) (string, error) {
+	return newFile(dir, name, fileTemplate)
+}
+
+// NewFromDiff creates a migration file in dir from the skeleton of the diff report
+func NewFromDiff(dir, name string, report *DiffReport) (string, error) {
+	if report.Empty() {
+		return "", errors.New("diff report is empty")
+	}
+	return newFile(dir, name, report.Skeleton())
+}
+
+func newFile(dir, name, content string) (string, error) {
+	name = strings.Trim(fileNameRe.ReplaceAllString(strings.ToLower(name), "-"), "-")
+	if name == "" {
+		return "", errors.New("migration name is empty")
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", errors.Wrapf(err, "create migration dir %s failed", dir)
+	}
+
+	filename := filepath.Join(dir, fmt.Sprintf("%s-%s.sql", time.Now().Format("20060102150405"), name))
+	if err := os.WriteFile(filename, []byte(content), 0o644); err != nil {
+		return "", errors.Wrapf(err, "write migration file %s failed", filename)
+	}
+	return filename, nil
+}
+
+func execute(dir migrate.MigrationDirection, options ...func(*Options)) ([]Plan, error) {
+	ops := getOptionsOrSetDefault(nil)
+	for _, f := range options {
+		f(ops)
+	}
+	ops.readOnly = ops.dryRun
+
+	db, err := openDatabase(ops)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var plans []Plan
+	err = withAdvisoryLock(ops, db, func() error {
+		planned, err := plan(ops, db, dir)
+		if err != nil {
+			return err
+		}
+		for _, item := range planned {
+			plans = append(plans, newPlan(ops, item, dir))
+		}
+		if ops.dryRun || len(planned) == 0 {
+			return nil
+		}
+		return apply(ops, db, dir)
+	})
+	return plans, err
+}
+
+func plan(ops *Options, db *sql.DB, dir migrate.MigrationDirection) ([]*migrate.PlannedMigration, error) {
+	if ops.readOnly {
+		exists, err := hasChangeTable(ops, db)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			return planWithoutRecords(ops, dir)
+		}
+	}
+	planned, _, err := newMigrationSet(ops).PlanMigration(db, ops.dialect.MigrateDialect(), newSource(ops), dir, ops.max)
+	if err != nil {
+		log.WithContext(ops.ctx).WithError(err).WithComponent("database").Error("Plan migration failed")
+		return nil, err
+	}
+	return planned, nil
+}
+
+// planWithoutRecords plans a dry run on a database which was never migrated, everything is pending
+func planWithoutRecords(ops *Options, dir migrate.MigrationDirection) ([]*migrate.PlannedMigration, error) {
+	migrations, err := newSource(ops).FindMigrations()
+	if err != nil {
+		log.WithContext(ops.ctx).WithError(err).WithComponent("database").Error("Find migrations failed")
+		return nil, err
+	}
+	toApply := migrate.ToApply(migrations, "", dir)
+	if ops.max > 0 && ops.max < len(toApply) {
+		toApply = toApply[:ops.max]
+	}
+	planned := make([]*migrate.PlannedMigration, 0, len(toApply))
+	for _, m := range toApply {
+		planned = append(planned, &migrate.PlannedMigration{Migration: m, Queries: m.Up, DisableTransaction: m.DisableTransactionUp})
+	}
+	return planned, nil
+}
+
+func newPlan(ops *Options, planned *migrate.PlannedMigration, dir migrate.MigrationDirection) Plan {
+	_, code := ops.codes[planned.Id]
+	return Plan{
+		Id:        planned.Id,
+		Direction: directionName(dir),
+		Code:      code,
+		Queries:   planned.Queries,
+	}
+}
+
+func directionName(dir migrate.MigrationDirection) string {
+	if dir == migrate.Down {
+		return "down"
+	}
+	return "up"
+}
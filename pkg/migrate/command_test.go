@@ -0,0 +1,213 @@
+package migrate
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gorm.io/gorm"
+
+	"github.com/ppxb/oreo-admin-go/pkg/dialect"
+)
+
+//go:embed testdata/redo
+var redoFs embed.FS
+
+func sqliteOptions(t *testing.T, options ...func(*Options)) []func(*Options) {
+	t.Helper()
+	return append([]func(*Options){
+		WithDriver(dialect.Sqlite),
+		WithUri(filepath.Join(t.TempDir(), "migrate.db")),
+		WithFs(redoFs),
+		WithFsRoot("testdata/redo"),
+		WithCodeMigrations(),
+	}, options...)
+}
+
+func TestRedoReappliesLastApplied(t *testing.T) {
+	options := sqliteOptions(t)
+	if _, err := Up(options...); err != nil {
+		t.Fatalf("up: %v", err)
+	}
+
+	// a pending migration older than the last applied one, e.g. merged from another branch
+	var pendingApplied bool
+	options = append(options, WithCodeMigrations(CodeMigration{
+		Id: "20260101000002-b",
+		Up: func(context.Context, *gorm.DB) error {
+			pendingApplied = true
+			return nil
+		},
+	}))
+
+	dry, err := Redo(append(options, WithDryRun(true))...)
+	if err != nil {
+		t.Fatalf("redo dry run: %v", err)
+	}
+	plans, err := Redo(options...)
+	if err != nil {
+		t.Fatalf("redo: %v", err)
+	}
+	for name, list := range map[string][]Plan{"dry run": dry, "redo": plans} {
+		if len(list) != 2 {
+			t.Fatalf("%s: got %d plans, want 2", name, len(list))
+		}
+		for i, dir := range []string{"down", "up"} {
+			if list[i].Id != "20260101000003-c.sql" || list[i].Direction != dir {
+				t.Errorf("%s: plan %d is %s %s, want %s 20260101000003-c.sql", name, i, list[i].Direction, list[i].Id, dir)
+			}
+		}
+	}
+	if pendingApplied {
+		t.Error("redo applied the pending migration")
+	}
+
+	records, err := Status(options...)
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	want := map[string]bool{
+		"20260101000001-a.sql": true,
+		"20260101000002-b":     false,
+		"20260101000003-c.sql": true,
+	}
+	for _, record := range records {
+		if want[record.Id] != record.Applied {
+			t.Errorf("%s applied = %v, want %v", record.Id, record.Applied, want[record.Id])
+		}
+	}
+}
+
+func TestRedoNothingApplied(t *testing.T) {
+	if _, err := Redo(sqliteOptions(t)...); err != ErrNothingToRedo {
+		t.Fatalf("got %v, want ErrNothingToRedo", err)
+	}
+}
+
+func TestReadOnlyMissingDatabase(t *testing.T) {
+	uri := filepath.Join(t.TempDir(), "missing", "migrate.db")
+	options := sqliteOptions(t, WithUri(uri))
+	tests := []struct {
+		name string
+		run  func() error
+	}{
+		{"status", func() error {
+			_, err := Status(options...)
+			return err
+		}},
+		{"up dry run", func() error {
+			_, err := Up(append(options, WithDryRun(true))...)
+			return err
+		}},
+		{"down dry run", func() error {
+			_, err := Down(append(options, WithDryRun(true))...)
+			return err
+		}},
+		{"redo dry run", func() error {
+			_, err := Redo(append(options, WithDryRun(true))...)
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		if err := tt.run(); !errors.Is(err, ErrDatabaseNotExist) {
+			t.Errorf("%s: got %v, want ErrDatabaseNotExist", tt.name, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Dir(uri)); !os.IsNotExist(err) {
+		t.Errorf("read only commands created the database: %v", err)
+	}
+}
+
+func TestReadOnlyFreshDatabase(t *testing.T) {
+	options := sqliteOptions(t)
+	ops := getOptionsOrSetDefault(nil)
+	for _, f := range options {
+		f(ops)
+	}
+	// an empty file is an empty sqlite database
+	if err := os.WriteFile(ops.uri, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := Status(options...)
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	if len(records) != 2 || records[0].Applied || records[1].Applied {
+		t.Errorf("status = %+v, want 2 pending migrations", records)
+	}
+	plans, err := Up(append(options, WithDryRun(true), WithMax(1))...)
+	if err != nil {
+		t.Fatalf("up dry run: %v", err)
+	}
+	if len(plans) != 1 || plans[0].Id != "20260101000001-a.sql" || len(plans[0].Queries) == 0 {
+		t.Errorf("up dry run = %+v, want 20260101000001-a.sql", plans)
+	}
+	if plans, err = Down(append(options, WithDryRun(true))...); err != nil || len(plans) != 0 {
+		t.Errorf("down dry run = %+v, %v, want nothing", plans, err)
+	}
+
+	db, err := open(ops)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var n int
+	if err = db.QueryRow("SELECT COUNT(*) FROM sqlite_master").Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Errorf("read only commands created %d schema objects", n)
+	}
+
+	ops.readOnly = true
+	ro, err := open(ops)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ro.Close()
+	if _, err = ro.Exec("CREATE TABLE written (id integer)"); err == nil {
+		t.Error("read only connection created a table")
+	}
+}
+
+func TestReadOnlyIgnoresLock(t *testing.T) {
+	options := sqliteOptions(t, WithLockFailFast(true))
+	if _, err := Up(options...); err != nil {
+		t.Fatalf("up: %v", err)
+	}
+	ops := getOptionsOrSetDefault(nil)
+	for _, f := range options {
+		f(ops)
+	}
+	db, err := open(ops)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if ok, err := ops.dialect.TryLock(context.Background(), conn, ops.lockName, 0); !ok || err != nil {
+		t.Fatalf("hold lock: %v, %v", ok, err)
+	}
+	defer ops.dialect.Unlock(context.Background(), conn, ops.lockName)
+
+	if _, err = Status(options...); err != nil {
+		t.Errorf("status: %v", err)
+	}
+	if _, err = Down(append(options, WithDryRun(true))...); err != nil {
+		t.Errorf("down dry run: %v", err)
+	}
+	if _, err = Redo(append(options, WithDryRun(true))...); err != nil {
+		t.Errorf("redo dry run: %v", err)
+	}
+	if _, err = Down(options...); !errors.Is(err, ErrLockHeld) {
+		t.Errorf("down: got %v, want ErrLockHeld", err)
+	}
+}
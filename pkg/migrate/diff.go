@@ -0,0 +1,330 @@
+package migrate
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+
+	"github.com/ppxb/oreo-admin-go/pkg/dialect"
+)
+
+const (
+	DiffMissingTable  = "missing table"
+	DiffMissingColumn = "missing column"
+	DiffExtraColumn   = "extra column"
+	DiffColumnType    = "column type mismatch"
+	DiffMissingIndex  = "missing index"
+	DiffExtraIndex    = "extra index"
+	DiffIndexColumns  = "index mismatch"
+)
+
+var (
+	ErrSchemaDrift = errors.New("schema drift detected")
+
+	models    []interface{}
+	modelLock sync.RWMutex
+
+	intWidthRe = regexp.MustCompile(`^(tinyint|smallint|mediumint|int|integer|bigint)\(\d+\)`)
+)
+
+type Difference struct {
+	Table    string
+	Kind     string
+	Column   string
+	Index    string
+	Expected string
+	Actual   string
+	// Up/Down are the suggested statements for the migration skeleton, may be a comment
+	Up   string
+	Down string
+}
+
+type DiffReport struct {
+	Differences []Difference
+}
+
+// RegisterModel adds gorm models checked by Diff when no model is given
+func RegisterModel(list ...interface{}) {
+	modelLock.Lock()
+	defer modelLock.Unlock()
+	models = append(models, list...)
+}
+
+func registeredModels() []interface{} {
+	modelLock.RLock()
+	defer modelLock.RUnlock()
+	return append([]interface{}{}, models...)
+}
+
+// Diff compares gorm models with the migrated database, db must be opened with the same
+// NamingStrategy as the application so that table/column names match, the skeleton uses the ddl of its driver
+func Diff(db *gorm.DB, list ...interface{}) (*DiffReport, error) {
+	if len(list) == 0 {
+		list = registeredModels()
+	}
+	d, err := dialect.Get(db.Dialector.Name())
+	if err != nil {
+		return nil, err
+	}
+
+	report := &DiffReport{}
+	for _, model := range list {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			return nil, errors.Wrapf(err, "parse model %T", model)
+		}
+		if err := diffTable(db, d, stmt, report); err != nil {
+			return nil, err
+		}
+	}
+	return report, nil
+}
+
+func diffTable(db *gorm.DB, d dialect.Dialect, stmt *gorm.Statement, report *DiffReport) error {
+	m := db.Migrator()
+	table := stmt.Table
+	quoted := db.Statement.Quote(table)
+
+	if !m.HasTable(table) {
+		report.add(Difference{
+			Table: table,
+			Kind:  DiffMissingTable,
+			Up:    fmt.Sprintf("-- TODO: CREATE TABLE %s for model %s", quoted, stmt.Schema.Name),
+			Down:  fmt.Sprintf("-- DROP TABLE %s;", quoted),
+		})
+		return nil
+	}
+
+	columnTypes, err := m.ColumnTypes(table)
+	if err != nil {
+		return errors.Wrapf(err, "read columns of %s", table)
+	}
+	actualColumns := make(map[string]gorm.ColumnType, len(columnTypes))
+	for _, item := range columnTypes {
+		actualColumns[item.Name()] = item
+	}
+
+	for _, field := range stmt.Schema.Fields {
+		if field.DBName == "" || field.IgnoreMigration {
+			continue
+		}
+		expected := db.Dialector.DataTypeOf(field)
+		actual, ok := actualColumns[field.DBName]
+		delete(actualColumns, field.DBName)
+		column := db.Statement.Quote(field.DBName)
+		if !ok {
+			report.add(Difference{
+				Table:    table,
+				Kind:     DiffMissingColumn,
+				Column:   field.DBName,
+				Expected: expected,
+				Up:       fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s;", quoted, column, expected),
+				Down:     fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s;", quoted, column),
+			})
+			continue
+		}
+		actualType, _ := actual.ColumnType()
+		if actualType == "" {
+			actualType = actual.DatabaseTypeName()
+		}
+		if normalizeType(expected) != normalizeType(actualType) {
+			report.add(Difference{
+				Table:    table,
+				Kind:     DiffColumnType,
+				Column:   field.DBName,
+				Expected: expected,
+				Actual:   actualType,
+				Up:       d.AlterColumnType(quoted, column, expected),
+				Down:     d.AlterColumnType(quoted, column, actualType),
+			})
+		}
+	}
+
+	for name, item := range actualColumns {
+		actualType, _ := item.ColumnType()
+		column := db.Statement.Quote(name)
+		report.add(Difference{
+			Table:  table,
+			Kind:   DiffExtraColumn,
+			Column: name,
+			Actual: actualType,
+			// dropping data is never generated automatically
+			Up:   fmt.Sprintf("-- ALTER TABLE %s DROP COLUMN %s;", quoted, column),
+			Down: fmt.Sprintf("-- ALTER TABLE %s ADD COLUMN %s %s;", quoted, column, actualType),
+		})
+	}
+
+	return diffIndexes(db, d, stmt, report)
+}
+
+func diffIndexes(db *gorm.DB, d dialect.Dialect, stmt *gorm.Statement, report *DiffReport) error {
+	table := stmt.Table
+	quoted := db.Statement.Quote(table)
+
+	indexes, err := db.Migrator().GetIndexes(table)
+	if err != nil {
+		return errors.Wrapf(err, "read indexes of %s", table)
+	}
+	actualIndexes := make(map[string]gorm.Index, len(indexes))
+	for _, item := range indexes {
+		if pk, _ := item.PrimaryKey(); pk {
+			continue
+		}
+		actualIndexes[item.Name()] = item
+	}
+
+	for _, index := range stmt.Schema.ParseIndexes() {
+		columns := make([]string, 0, len(index.Fields))
+		for _, field := range index.Fields {
+			columns = append(columns, field.DBName)
+		}
+		expected := indexDesc(index.Class == "UNIQUE", columns)
+		actual, ok := actualIndexes[index.Name]
+		delete(actualIndexes, index.Name)
+		create := fmt.Sprintf("CREATE %sINDEX %s ON %s (%s);", indexClass(index.Class), db.Statement.Quote(index.Name), quoted, quoteColumns(db, columns))
+		drop := d.DropIndex(quoted, db.Statement.Quote(index.Name))
+		if !ok {
+			report.add(Difference{
+				Table:    table,
+				Kind:     DiffMissingIndex,
+				Index:    index.Name,
+				Expected: expected,
+				Up:       create,
+				Down:     drop,
+			})
+			continue
+		}
+		unique, _ := actual.Unique()
+		if got := indexDesc(unique, actual.Columns()); got != expected {
+			report.add(Difference{
+				Table:    table,
+				Kind:     DiffIndexColumns,
+				Index:    index.Name,
+				Expected: expected,
+				Actual:   got,
+				Up:       drop + "\n" + create,
+				Down:     fmt.Sprintf("-- restore index %s as %s", index.Name, got),
+			})
+		}
+	}
+
+	for name, item := range actualIndexes {
+		unique, _ := item.Unique()
+		report.add(Difference{
+			Table:  table,
+			Kind:   DiffExtraIndex,
+			Index:  name,
+			Actual: indexDesc(unique, item.Columns()),
+			Up:     "-- " + d.DropIndex(quoted, db.Statement.Quote(name)),
+			Down:   fmt.Sprintf("-- CREATE INDEX %s ON %s (%s);", db.Statement.Quote(name), quoted, quoteColumns(db, item.Columns())),
+		})
+	}
+	return nil
+}
+
+func (r *DiffReport) add(d Difference) {
+	r.Differences = append(r.Differences, d)
+}
+
+func (r *DiffReport) Empty() bool {
+	return r == nil || len(r.Differences) == 0
+}
+
+func (r *DiffReport) String() string {
+	if r.Empty() {
+		return "no schema drift"
+	}
+	list := r.sorted()
+	lines := make([]string, 0, len(list))
+	for _, d := range list {
+		target := d.Column
+		if d.Index != "" {
+			target = d.Index
+		}
+		line := fmt.Sprintf("%s: %s", d.Table, d.Kind)
+		if target != "" {
+			line += " " + target
+		}
+		if d.Expected != "" || d.Actual != "" {
+			line += fmt.Sprintf(" (model: %s, database: %s)", orDash(d.Expected), orDash(d.Actual))
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Skeleton renders a sql-migrate file fixing the drift, destructive statements are commented out
+func (r *DiffReport) Skeleton() string {
+	var up, down []string
+	for _, d := range r.sorted() {
+		up = append(up, d.Up)
+		down = append([]string{d.Down}, down...)
+	}
+	return fmt.Sprintf(
+		"-- +migrate Up\n-- generated by migrate diff, review before applying\n%s\n\n-- +migrate Down\n%s\n",
+		strings.Join(up, "\n"),
+		strings.Join(down, "\n"),
+	)
+}
+
+func (r *DiffReport) sorted() []Difference {
+	list := append([]Difference{}, r.Differences...)
+	sort.SliceStable(list, func(i, j int) bool {
+		if list[i].Table != list[j].Table {
+			return list[i].Table < list[j].Table
+		}
+		return list[i].Column+list[i].Index < list[j].Column+list[j].Index
+	})
+	return list
+}
+
+// normalizeType removes differences which are only cosmetic between gorm and information_schema
+func normalizeType(s string) string {
+	s = strings.ToLower(strings.TrimSpace(s))
+	for _, item := range []string{"auto_increment", "autoincrement", "primary key"} {
+		s = strings.ReplaceAll(s, item, "")
+	}
+	s = strings.TrimSpace(s)
+	switch s {
+	case "boolean", "bool":
+		return "tinyint(1)"
+	}
+	if !strings.HasPrefix(s, "tinyint(1)") {
+		s = intWidthRe.ReplaceAllString(s, "$1")
+	}
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func indexDesc(unique bool, columns []string) string {
+	if unique {
+		return fmt.Sprintf("unique(%s)", strings.Join(columns, ","))
+	}
+	return fmt.Sprintf("(%s)", strings.Join(columns, ","))
+}
+
+func indexClass(class string) string {
+	if class == "" {
+		return ""
+	}
+	return class + " "
+}
+
+func quoteColumns(db *gorm.DB, columns []string) string {
+	quoted := make([]string, 0, len(columns))
+	for _, column := range columns {
+		quoted = append(quoted, db.Statement.Quote(column))
+	}
+	return strings.Join(quoted, ", ")
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
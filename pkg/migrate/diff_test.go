@@ -0,0 +1,182 @@
+package migrate
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gorm.io/gorm"
+
+	"github.com/ppxb/oreo-admin-go/pkg/dialect"
+)
+
+type diffUser struct {
+	Id    uint   `gorm:"primaryKey"`
+	Name  string `gorm:"index:idx_diff_users_name"`
+	Age   int
+	Email string
+}
+
+func (diffUser) TableName() string {
+	return "diff_users"
+}
+
+type diffRole struct {
+	Id   uint   `gorm:"primaryKey"`
+	Name string `gorm:"uniqueIndex:idx_diff_roles_name"`
+}
+
+func (diffRole) TableName() string {
+	return "diff_roles"
+}
+
+func openDiffDb(t *testing.T, stmts ...string) *gorm.DB {
+	t.Helper()
+	d, err := dialect.Get(dialect.Sqlite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := gorm.Open(d.Open(filepath.Join(t.TempDir(), "diff.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range stmts {
+		if err = db.Exec(stmt).Error; err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+	return db
+}
+
+func TestDiffInSync(t *testing.T) {
+	db := openDiffDb(t)
+	if err := db.AutoMigrate(&diffUser{}, &diffRole{}); err != nil {
+		t.Fatal(err)
+	}
+	report, err := Diff(db, &diffUser{}, &diffRole{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Empty() {
+		t.Fatalf("got drift of migrated models:\n%s", report)
+	}
+}
+
+func TestDiffDrift(t *testing.T) {
+	db := openDiffDb(t,
+		"CREATE TABLE diff_users (id integer PRIMARY KEY AUTOINCREMENT, name text, age text, legacy text)",
+		"CREATE INDEX idx_diff_users_name ON diff_users (name, legacy)",
+		"CREATE INDEX idx_diff_users_legacy ON diff_users (legacy)",
+	)
+	report, err := Diff(db, &diffUser{}, &diffRole{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		kind     string
+		table    string
+		target   string
+		expected string
+		actual   string
+		up       string
+	}{
+		{DiffMissingTable, "diff_roles", "", "", "", "-- TODO: CREATE TABLE `diff_roles`"},
+		{DiffMissingColumn, "diff_users", "email", "text", "", "ALTER TABLE `diff_users` ADD COLUMN `email` text;"},
+		{DiffExtraColumn, "diff_users", "legacy", "", "text", "-- ALTER TABLE `diff_users` DROP COLUMN `legacy`;"},
+		{DiffColumnType, "diff_users", "age", "integer", "text", "-- TODO: rebuild `diff_users` to change `age` to integer"},
+		{DiffIndexColumns, "diff_users", "idx_diff_users_name", "(name)", "(name,legacy)", "DROP INDEX `idx_diff_users_name`;\nCREATE INDEX `idx_diff_users_name` ON `diff_users` (`name`);"},
+		{DiffExtraIndex, "diff_users", "idx_diff_users_legacy", "", "(legacy)", "-- DROP INDEX `idx_diff_users_legacy`;"},
+	}
+	if len(report.Differences) != len(tests) {
+		t.Errorf("got %d differences, want %d:\n%s", len(report.Differences), len(tests), report)
+	}
+	for _, tt := range tests {
+		var found *Difference
+		for i, d := range report.Differences {
+			if d.Kind == tt.kind && d.Table == tt.table && d.Column+d.Index == tt.target {
+				found = &report.Differences[i]
+				break
+			}
+		}
+		if found == nil {
+			t.Errorf("%s %s %s is not reported", tt.table, tt.kind, tt.target)
+			continue
+		}
+		if found.Expected != tt.expected || found.Actual != tt.actual {
+			t.Errorf("%s %s: got model %q database %q, want %q %q", tt.kind, tt.target, found.Expected, found.Actual, tt.expected, tt.actual)
+		}
+		if !strings.HasPrefix(found.Up, tt.up) {
+			t.Errorf("%s %s: got up %q, want prefix %q", tt.kind, tt.target, found.Up, tt.up)
+		}
+	}
+
+	skeleton := report.Skeleton()
+	up, down, ok := strings.Cut(skeleton, "-- +migrate Down")
+	if !ok || !strings.HasPrefix(up, "-- +migrate Up\n") {
+		t.Fatalf("skeleton has no up/down sections:\n%s", skeleton)
+	}
+	if !strings.Contains(up, "ADD COLUMN `email` text;") || !strings.Contains(down, "DROP COLUMN `email`;") {
+		t.Errorf("skeleton misses the missing column:\n%s", skeleton)
+	}
+	// down reverts up in reverse order
+	if strings.Index(down, "`legacy` text;") > strings.Index(down, "DROP COLUMN `email`;") {
+		t.Errorf("down is not reversed:\n%s", skeleton)
+	}
+	if ok := strings.Contains(up, "\nALTER TABLE `diff_users` DROP COLUMN"); ok {
+		t.Errorf("skeleton drops a column without comment:\n%s", skeleton)
+	}
+
+	// the generated up runs on the database it was generated for
+	for _, stmt := range strings.Split(up, "\n") {
+		if stmt = strings.TrimSpace(stmt); stmt == "" || strings.HasPrefix(stmt, "--") {
+			continue
+		}
+		if err = db.Exec(stmt).Error; err != nil {
+			t.Errorf("%s: %v", stmt, err)
+		}
+	}
+}
+
+func TestDiffStatements(t *testing.T) {
+	tests := []struct {
+		driver    string
+		alter     string
+		dropIndex string
+	}{
+		{dialect.Mysql, "ALTER TABLE t MODIFY COLUMN c bigint;", "DROP INDEX i ON t;"},
+		{dialect.Postgres, "ALTER TABLE t ALTER COLUMN c TYPE bigint;", "DROP INDEX i;"},
+		{dialect.Sqlite, "-- TODO: rebuild t to change c to bigint, sqlite can not alter column types", "DROP INDEX i;"},
+	}
+	for _, tt := range tests {
+		d, err := dialect.Get(tt.driver)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := d.AlterColumnType("t", "c", "bigint"); got != tt.alter {
+			t.Errorf("%s alter column = %q, want %q", tt.driver, got, tt.alter)
+		}
+		if got := d.DropIndex("t", "i"); got != tt.dropIndex {
+			t.Errorf("%s drop index = %q, want %q", tt.driver, got, tt.dropIndex)
+		}
+	}
+}
+
+func TestNormalizeType(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"bigint(20) unsigned", "bigint unsigned"},
+		{"BIGINT UNSIGNED AUTO_INCREMENT", "bigint unsigned"},
+		{"tinyint(1)", "tinyint(1)"},
+		{"boolean", "tinyint(1)"},
+		{"varchar(191)", "varchar(191)"},
+		{"integer PRIMARY KEY AUTOINCREMENT", "integer"},
+	}
+	for _, tt := range tests {
+		if got := normalizeType(tt.in); got != tt.want {
+			t.Errorf("normalizeType(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-gorp/gorp/v3"
+	"github.com/pkg/errors"
+	migrate "github.com/rubenv/sql-migrate"
+	"gorm.io/gorm"
+
+	"github.com/ppxb/oreo-admin-go/pkg/log"
+)
+
+type sqlExecutor interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// apply executes the plan itself instead of migrate.Exec, so that sql files and go migrations
+// share one ordering and one change table, and each step records itself in its own transaction
+func apply(ops *Options, db *sql.DB, dir migrate.MigrationDirection) error {
+	planned, dbMap, err := newMigrationSet(ops).PlanMigration(db, ops.dialect.MigrateDialect(), newSource(ops), dir, ops.max)
+	if err != nil {
+		log.WithContext(ops.ctx).WithError(err).WithComponent("database").Error("Plan migration failed")
+		return err
+	}
+	return applyPlanned(ops, db, dbMap, planned, dir)
+}
+
+// applyPlanned executes exactly the given migrations in order
+func applyPlanned(ops *Options, db *sql.DB, dbMap *gorp.DbMap, planned []*migrate.PlannedMigration, dir migrate.MigrationDirection) error {
+	var (
+		gdb *gorm.DB
+		err error
+	)
+	for i, item := range planned {
+		code, ok := ops.codes[item.Id]
+		if ok && gdb == nil {
+			if gdb, err = openGorm(ops, db); err != nil {
+				return err
+			}
+		}
+		if ok {
+			err = applyCode(ops, db, gdb, dbMap, code, dir)
+		} else {
+			err = applySql(ops, db, dbMap, item, dir)
+		}
+		if err != nil {
+			log.WithContext(ops.ctx).WithError(err).WithComponent("database").Error("Migration failed", "id", item.Id, "direction", directionName(dir), "applied", i)
+			return err
+		}
+		log.WithContext(ops.ctx).WithComponent("database").Debug("Migration applied", "id", item.Id, "direction", directionName(dir))
+	}
+
+	log.WithContext(ops.ctx).WithComponent("database").Info("Migration completed", "direction", directionName(dir), "applied", len(planned))
+	return nil
+}
+
+func applySql(ops *Options, db *sql.DB, dbMap *gorp.DbMap, item *migrate.PlannedMigration, dir migrate.MigrationDirection) error {
+	run := func(exec sqlExecutor) error {
+		for _, stmt := range item.Queries {
+			stmt = strings.TrimSuffix(strings.TrimSpace(stmt), ";")
+			if _, err := exec.ExecContext(ops.ctx, stmt); err != nil {
+				return errors.Wrapf(err, "execute migration %s", item.Id)
+			}
+		}
+		return record(ops, exec, dbMap, item.Id, dir)
+	}
+
+	if item.DisableTransaction {
+		return run(db)
+	}
+	return inTx(ops, db, func(tx *sql.Tx) error {
+		return run(tx)
+	})
+}
+
+func applyCode(ops *Options, db *sql.DB, gdb *gorm.DB, dbMap *gorp.DbMap, code CodeMigration, dir migrate.MigrationDirection) error {
+	fn := code.Up
+	if dir == migrate.Down {
+		fn = code.Down
+	}
+	if fn == nil {
+		return errors.Errorf("code migration %s is irreversible", code.Id)
+	}
+
+	return inTx(ops, db, func(tx *sql.Tx) error {
+		session := gdb.Session(&gorm.Session{
+			Context: ops.ctx,
+			NewDB:   true,
+		})
+		session.Statement.ConnPool = tx
+		if err := fn(ops.ctx, session); err != nil {
+			return errors.Wrapf(err, "execute code migration %s", code.Id)
+		}
+		return record(ops, tx, dbMap, code.Id, dir)
+	})
+}
+
+func inTx(ops *Options, db *sql.DB, fn func(tx *sql.Tx) error) error {
+	tx, err := db.BeginTx(ops.ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err = fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// record writes the change table the same way sql-migrate does, so `status` and later runs see both kinds
+func record(ops *Options, exec sqlExecutor, dbMap *gorp.DbMap, id string, dir migrate.MigrationDirection) error {
+	d := dbMap.Dialect
+	table := d.QuotedTableForQuery("", ops.changeTable)
+
+	var err error
+	if dir == migrate.Up {
+		_, err = exec.ExecContext(
+			ops.ctx,
+			fmt.Sprintf("INSERT INTO %s (%s, %s) VALUES (%s, %s)", table, d.QuoteField("id"), d.QuoteField("applied_at"), d.BindVar(0), d.BindVar(1)),
+			id, time.Now(),
+		)
+	} else {
+		_, err = exec.ExecContext(
+			ops.ctx,
+			fmt.Sprintf("DELETE FROM %s WHERE %s = %s", table, d.QuoteField("id"), d.BindVar(0)),
+			id,
+		)
+	}
+	return errors.Wrapf(err, "record migration %s", id)
+}
+
+func openGorm(ops *Options, db *sql.DB) (*gorm.DB, error) {
+	cfg := gorm.Config{}
+	if ops.gormConfig != nil {
+		cfg = *ops.gormConfig
+	}
+	gdb, err := gorm.Open(ops.dialect.New(db), &cfg)
+	if err != nil {
+		log.WithContext(ops.ctx).WithError(err).WithComponent("database").Error("Open gorm for code migration failed")
+		return nil, err
+	}
+	return gdb, nil
+}
@@ -0,0 +1,136 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/ppxb/oreo-admin-go/pkg/log"
+)
+
+// lockAttemptSeconds is the lock wait of a single attempt, kept short so that ctx is checked often
+const lockAttemptSeconds = 1
+
+var (
+	ErrLockTimeout = errors.New("wait for migration advisory lock timeout")
+	ErrLockHeld    = errors.New("migration advisory lock is held by another session")
+)
+
+// withAdvisoryLock pins a single connection because advisory locks are scoped to the session
+func withAdvisoryLock(ops *Options, db *sql.DB, fn func() error) error {
+	// a read only run changes nothing, it must not wait for or block a migration
+	if ops.readOnly {
+		return fn()
+	}
+	conn, err := db.Conn(ops.ctx)
+	if err != nil {
+		log.WithContext(ops.ctx).WithError(err).WithComponent("database").Error("Get lock connection failed")
+		return err
+	}
+	defer conn.Close()
+
+	if err = waitForLock(ops, conn); err != nil {
+		return err
+	}
+
+	defer func() {
+		if err := releaseLock(ops, conn); err != nil {
+			log.WithContext(ops.ctx).WithError(err).WithComponent("database").Error("Release lock failed")
+		}
+	}()
+
+	return fn()
+}
+
+func waitForLock(ops *Options, conn *sql.Conn) error {
+	ctx := ops.ctx
+	if ops.lock.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, ops.lock.timeout)
+		defer cancel()
+	}
+
+	timeout := lockAttemptSeconds
+	if ops.lock.failFast {
+		timeout = 0
+	}
+
+	start := time.Now()
+	var lastReport time.Time
+	for {
+		lockAcquired, err := acquireLock(ctx, ops, conn, timeout)
+		if err != nil {
+			if ctx.Err() != nil {
+				return lockWaitError(ops, conn, start)
+			}
+			return err
+		}
+		if lockAcquired {
+			log.WithContext(ops.ctx).WithComponent("database").Debug("Advisory lock acquired", "waited", time.Since(start).Round(time.Millisecond))
+			return nil
+		}
+
+		if ops.lock.failFast {
+			return errors.Wrapf(ErrLockHeld, "lock: %s, holder: %s", ops.lockName, findLockHolder(ops, conn))
+		}
+
+		if time.Since(lastReport) >= ops.lock.holderInterval {
+			lastReport = time.Now()
+			log.WithContext(ops.ctx).WithComponent("database").Warn("Waiting for advisory lock", "lock", ops.lockName, "waited", time.Since(start).Round(time.Second), "holder", findLockHolder(ops, conn))
+		}
+
+		select {
+		case <-ctx.Done():
+			return lockWaitError(ops, conn, start)
+		default:
+		}
+	}
+}
+
+func lockWaitError(ops *Options, conn *sql.Conn, start time.Time) error {
+	if err := ops.ctx.Err(); err != nil {
+		return errors.Wrapf(err, "wait for advisory lock %s cancelled", ops.lockName)
+	}
+	return errors.Wrapf(ErrLockTimeout, "lock: %s, waited: %s, holder: %s", ops.lockName, time.Since(start).Round(time.Second), findLockHolder(ops, conn))
+}
+
+func acquireLock(ctx context.Context, ops *Options, conn *sql.Conn, timeout int) (bool, error) {
+	lockAcquired, err := ops.dialect.TryLock(ctx, conn, ops.lockName, timeout)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.WithContext(ops.ctx).WithError(err).WithComponent("database").Error("Acquire advisory lock failed")
+		}
+		return false, err
+	}
+	return lockAcquired, nil
+}
+
+func findLockHolder(ops *Options, conn *sql.Conn) string {
+	// the caller's ctx may already be done, diagnostics use their own short deadline
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	holder, err := ops.dialect.LockHolder(ctx, conn, ops.lockName)
+	if err != nil {
+		log.WithContext(ops.ctx).WithError(err).WithComponent("database").Debug("Query advisory lock holder failed")
+	}
+	if holder == "" {
+		return "unknown"
+	}
+	return holder
+}
+
+func releaseLock(ops *Options, conn *sql.Conn) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := ops.dialect.Unlock(ctx, conn, ops.lockName); err != nil {
+		log.WithContext(ops.ctx).WithError(err).WithComponent("database").Error("Release advisory lock failed")
+		return err
+	}
+
+	log.WithContext(ops.ctx).WithComponent("database").Debug("Advisory lock released")
+	return nil
+}
@@ -0,0 +1,198 @@
+package migrate
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/ppxb/oreo-admin-go/pkg/log"
+)
+
+// holdLock takes the migration lock of options on its own connection, the returned func releases it
+func holdLock(t *testing.T, options []func(*Options)) func() {
+	t.Helper()
+	ops := getOptionsOrSetDefault(nil)
+	for _, f := range options {
+		f(ops)
+	}
+	if err := ensureDatabase(ops); err != nil {
+		t.Fatal(err)
+	}
+	db, err := open(ops)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := ops.dialect.TryLock(context.Background(), conn, ops.lockName, 0); !ok || err != nil {
+		t.Fatalf("hold lock: %v, %v", ok, err)
+	}
+	var once sync.Once
+	release := func() {
+		once.Do(func() {
+			_ = ops.dialect.Unlock(context.Background(), conn, ops.lockName)
+			conn.Close()
+			db.Close()
+		})
+	}
+	t.Cleanup(release)
+	return release
+}
+
+// syncBuffer is written by the logger and read by the test
+type syncBuffer struct {
+	lock sync.Mutex
+	buf  bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.buf.String()
+}
+
+// captureLog replaces the default logger until the test ends
+func captureLog(t *testing.T) *syncBuffer {
+	b := &syncBuffer{}
+	old := log.DefaultWrapper
+	log.DefaultWrapper = log.NewWrapper(log.New(
+		log.WithLevel(log.DebugLevel),
+		log.WithSinks(log.Sink{Output: b, Level: log.DebugLevel, Json: true}),
+	))
+	t.Cleanup(func() {
+		log.DefaultWrapper = old
+	})
+	return b
+}
+
+func TestLockHeld(t *testing.T) {
+	tests := []struct {
+		name    string
+		options []func(*Options)
+		// cancel the ctx of Up after this delay, 0 keeps it
+		cancelAfter time.Duration
+		want        error
+		// the error names the holder
+		holder bool
+		// Up returns before this
+		within time.Duration
+	}{
+		{
+			name:    "fail fast",
+			options: []func(*Options){WithLockFailFast(true)},
+			want:    ErrLockHeld,
+			holder:  true,
+			within:  500 * time.Millisecond,
+		},
+		{
+			name:    "timeout",
+			options: []func(*Options){WithLockTimeout(300 * time.Millisecond)},
+			want:    ErrLockTimeout,
+			holder:  true,
+			within:  2 * time.Second,
+		},
+		{
+			name:        "ctx canceled",
+			cancelAfter: 300 * time.Millisecond,
+			want:        context.Canceled,
+			within:      2 * time.Second,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			options := sqliteOptions(t, append(tt.options, WithCtx(ctx), WithLockName("lock_test"))...)
+			holdLock(t, options)
+			if tt.cancelAfter > 0 {
+				time.AfterFunc(tt.cancelAfter, cancel)
+			}
+
+			start := time.Now()
+			_, err := Up(options...)
+			if elapsed := time.Since(start); elapsed > tt.within {
+				t.Errorf("Up returned after %s, want within %s", elapsed, tt.within)
+			}
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("got %v, want %v", err, tt.want)
+			}
+			if tt.holder && !strings.Contains(err.Error(), "holder: current process") {
+				t.Errorf("error %q does not name the holder", err)
+			}
+
+			records, err := Status(options...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, record := range records {
+				if record.Applied {
+					t.Errorf("%s is applied without the lock", record.Id)
+				}
+			}
+		})
+	}
+}
+
+func TestLockWaitLogsHolder(t *testing.T) {
+	out := captureLog(t)
+	options := sqliteOptions(t,
+		WithLockName("lock_test"),
+		WithLockTimeout(2500*time.Millisecond),
+		WithLockHolderInterval(100*time.Millisecond),
+	)
+	holdLock(t, options)
+	if _, err := Up(options...); !errors.Is(err, ErrLockTimeout) {
+		t.Fatalf("got %v, want ErrLockTimeout", err)
+	}
+
+	// an attempt waits lockAttemptSeconds, the holder is logged once per attempt at most
+	var waits int
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		var r struct {
+			Msg    string `json:"msg"`
+			Lock   string `json:"lock"`
+			Holder string `json:"holder"`
+		}
+		if err := json.Unmarshal([]byte(line), &r); err != nil {
+			t.Fatalf("invalid log line %q: %v", line, err)
+		}
+		if r.Msg != "Waiting for advisory lock" {
+			continue
+		}
+		waits++
+		if r.Lock != "lock_test" || r.Holder != "current process" {
+			t.Errorf("wait log = %+v", r)
+		}
+	}
+	if waits < 2 {
+		t.Errorf("holder logged %d times, want at least 2:\n%s", waits, out)
+	}
+}
+
+func TestLockReleasedWhileWaiting(t *testing.T) {
+	options := sqliteOptions(t, WithLockName("lock_test"), WithLockTimeout(5*time.Second))
+	release := holdLock(t, options)
+	time.AfterFunc(200*time.Millisecond, release)
+
+	plans, err := Up(options...)
+	if err != nil {
+		t.Fatalf("up: %v", err)
+	}
+	if len(plans) != 2 {
+		t.Errorf("got %d plans, want 2", len(plans))
+	}
+}
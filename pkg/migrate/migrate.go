@@ -2,10 +2,8 @@ package migrate
 
 import (
 	"database/sql"
-	"fmt"
-
-	m "github.com/go-sql-driver/mysql"
 
+	"github.com/pkg/errors"
 	migrate "github.com/rubenv/sql-migrate"
 
 	"github.com/ppxb/oreo-admin-go/pkg/log"
@@ -21,9 +19,8 @@ func Do(options ...func(*Options)) error {
 		return err
 	}
 
-	db, err := sql.Open(ops.driver, ops.uri)
+	db, err := open(ops)
 	if err != nil {
-		log.WithContext(ops.ctx).WithError(err).Error("[DATABASE] Open %s(%s) failed", ops.driver, ops.uri)
 		return err
 	}
 	defer db.Close()
@@ -33,122 +30,139 @@ func Do(options ...func(*Options)) error {
 	})
 }
 
-func ensureDatabase(ops *Options) error {
-	cfg, err := m.ParseDSN(ops.uri)
-	if err != nil {
-		log.WithContext(ops.ctx).WithError(err).Error("[DATABASE] Invalid database uri")
-		return err
+// openDatabase creates the database if not exists and opens it, a read only run opens the existing one
+func openDatabase(ops *Options) (*sql.DB, error) {
+	if ops.readOnly {
+		return openReadOnly(ops)
 	}
+	if err := ensureDatabase(ops); err != nil {
+		return nil, err
+	}
+	return open(ops)
+}
 
-	dbName := cfg.DBName
-	cfg.DBName = ""
-
-	db, err := sql.Open("mysql", cfg.FormatDSN())
+func open(ops *Options) (*sql.DB, error) {
+	d, err := ops.getDialect()
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer db.Close()
 
-	if _, err := db.Exec(fmt.Sprintf("CREATE DATABASE IF NOT EXISTS `%s`", dbName)); err != nil {
-		log.WithContext(ops.ctx).WithError(err).Error("[DATABASE] Create database failed")
-		return err
+	uri := ops.uri
+	if ops.readOnly {
+		uri = d.ReadOnlyUri(uri)
 	}
-	return nil
-}
-
-func withAdvisoryLock(ops *Options, db *sql.DB, fn func() error) error {
-	if err := waitForLock(ops, db); err != nil {
-		return err
+	db, err := sql.Open(d.DriverName(), uri)
+	if err != nil {
+		log.WithContext(ops.ctx).WithError(err).WithComponent("database").Error("Open database failed", "driver", ops.driver, "uri", d.MaskUri(ops.uri))
+		return nil, err
 	}
-
-	defer func() {
-		if err := releaseLock(ops, db); err != nil {
-			log.WithContext(ops.ctx).WithError(err).Error("[DATABASE] Release lock failed")
-		}
-	}()
-
-	return fn()
+	return db, nil
 }
 
-func waitForLock(ops *Options, db *sql.DB) error {
-	for {
-		lockAcquired, err := acquireLock(ops, db)
-		if err != nil {
-			return err
-		}
-		if lockAcquired {
-			log.WithContext(ops.ctx).Debug("[DATABASE] Advisory lock acquired")
-			return nil
-		}
-		log.WithContext(ops.ctx).Debug("[DATABASE] Waiting for advisory lock...")
+func openReadOnly(ops *Options) (*sql.DB, error) {
+	d, err := ops.getDialect()
+	if err != nil {
+		return nil, err
 	}
-}
-
-func acquireLock(ops *Options, db *sql.DB) (bool, error) {
-	var lockAcquired bool
-	query := fmt.Sprintf("SELECT GET_LOCK('%s', 5)", ops.lockName)
 
-	if err := db.QueryRow(query).Scan(&lockAcquired); err != nil {
-		log.WithContext(ops.ctx).WithError(err).Error("[DATABASE] Acquire advisory lock failed")
-		return false, err
+	exists, err := d.DatabaseExists(ops.ctx, ops.uri)
+	if err != nil {
+		log.WithContext(ops.ctx).WithError(err).WithComponent("database").Error("Find database failed")
+		return nil, err
 	}
-	return lockAcquired, nil
+	if !exists {
+		name, _ := d.DatabaseName(ops.uri)
+		return nil, errors.Wrapf(ErrDatabaseNotExist, "database: %s", name)
+	}
+	return open(ops)
 }
 
-func releaseLock(ops *Options, db *sql.DB) error {
-	query := fmt.Sprintf("SELECT RELEASE_LOCK('%s')", ops.lockName)
-	if _, err := db.Exec(query); err != nil {
-		log.WithContext(ops.ctx).WithError(err).Error("[DATABASE] Release advisory lock failed")
+func ensureDatabase(ops *Options) error {
+	d, err := ops.getDialect()
+	if err != nil {
 		return err
 	}
 
-	log.WithContext(ops.ctx).Debug("[DATABASE] Advisory lock released")
+	if err = d.EnsureDatabase(ops.ctx, ops.uri); err != nil {
+		log.WithContext(ops.ctx).WithError(err).WithComponent("database").Error("Create database failed")
+		return err
+	}
 	return nil
 }
 
 func executeMigration(ops *Options, db *sql.DB) error {
 	if ops.before != nil {
 		if err := ops.before(ops.ctx); err != nil {
-			log.WithContext(ops.ctx).WithError(err).Error("[DATABASE] Before callback failed")
+			log.WithContext(ops.ctx).WithError(err).WithComponent("database").Error("Before callback failed")
 			return err
 		}
 	}
 
-	migrate.SetTable(ops.changeTable)
-	source := &migrate.EmbedFileSystemMigrationSource{
-		FileSystem: ops.fs,
-		Root:       ops.fsRoot,
-	}
+	source := newSource(ops)
 
 	if err := logMigrationStatus(ops, db, source); err != nil {
 		return err
 	}
 
-	if _, err := migrate.Exec(db, ops.driver, source, migrate.Up); err != nil {
-		log.WithContext(ops.ctx).WithError(err).Error("[DATABASE] Migration failed")
-		return err
+	return apply(ops, db, migrate.Up)
+}
+
+func newSource(ops *Options) migrate.MigrationSource {
+	return source{
+		sql: &migrate.EmbedFileSystemMigrationSource{
+			FileSystem: ops.fs,
+			Root:       ops.fsRoot,
+		},
+		code: ops.codes,
 	}
+}
 
-	log.WithContext(ops.ctx).Info("[DATABASE] migration completed successfully")
-	return nil
+func newMigrationSet(ops *Options) migrate.MigrationSet {
+	return migrate.MigrationSet{
+		TableName:          ops.changeTable,
+		DisableCreateTable: ops.readOnly,
+	}
+}
+
+// migrationRecords reads the change table, a read only run finds no records instead of creating the table
+func migrationRecords(ops *Options, db *sql.DB) ([]*migrate.MigrationRecord, error) {
+	if ops.readOnly {
+		exists, err := hasChangeTable(ops, db)
+		if err != nil || !exists {
+			return nil, err
+		}
+	}
+	records, err := newMigrationSet(ops).GetMigrationRecords(db, ops.dialect.MigrateDialect())
+	if err != nil {
+		log.WithContext(ops.ctx).WithError(err).WithComponent("database").Error("Find migration records failed")
+		return nil, err
+	}
+	return records, nil
+}
+
+func hasChangeTable(ops *Options, db *sql.DB) (bool, error) {
+	gdb, err := openGorm(ops, db)
+	if err != nil {
+		return false, err
+	}
+	return gdb.Migrator().HasTable(ops.changeTable), nil
 }
 
-func logMigrationStatus(ops *Options, db *sql.DB, source *migrate.EmbedFileSystemMigrationSource) error {
+func logMigrationStatus(ops *Options, db *sql.DB, source migrate.MigrationSource) error {
 	migrations, err := source.FindMigrations()
 	if err != nil {
-		log.WithContext(ops.ctx).WithError(err).Error("[DATABASE] Find migrations failed")
+		log.WithContext(ops.ctx).WithError(err).WithComponent("database").Error("Find migrations failed")
 		return err
 	}
 
-	records, err := migrate.GetMigrationRecords(db, ops.driver)
+	records, err := migrationRecords(ops, db)
 	if err != nil {
-		log.WithContext(ops.ctx).WithError(err).Error("[DATABASE] Find migration records failed")
 		return err
 	}
 
 	pending, applied := categorizeMigrations(migrations, records)
 
-	log.WithContext(ops.ctx).Debug("[DATABASE] Migration status: %d pending, %d applied", len(pending), len(applied))
+	log.WithContext(ops.ctx).WithComponent("database").Debug("Migration status", "pending", len(pending), "applied", len(applied))
 
 	return nil
 }
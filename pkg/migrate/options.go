@@ -3,19 +3,37 @@ package migrate
 import (
 	"context"
 	"embed"
+	"time"
 
+	"gorm.io/gorm"
+
+	"github.com/ppxb/oreo-admin-go/pkg/dialect"
 	"github.com/ppxb/oreo-admin-go/pkg/utils"
 )
 
 type Options struct {
 	ctx         context.Context
 	driver      string
+	dialect     dialect.Dialect
 	uri         string
 	lockName    string
 	before      func(ctx context.Context) error
 	changeTable string
 	fs          embed.FS
 	fsRoot      string
+	max         int
+	dryRun      bool
+	// readOnly is set by status and dry runs, they neither create anything nor take the lock
+	readOnly   bool
+	lock       LockOptions
+	codes      map[string]CodeMigration
+	gormConfig *gorm.Config
+}
+
+type LockOptions struct {
+	timeout        time.Duration
+	failFast       bool
+	holderInterval time.Duration
 }
 
 func WithCtx(ctx context.Context) func(*Options) {
@@ -26,6 +44,8 @@ func WithCtx(ctx context.Context) func(*Options) {
 	}
 }
 
+// WithDriver chooses the dialect(mysql/postgres/sqlite), the advisory lock of sqlite only exists inside
+// the current process, so it does not keep two processes migrating the same file apart
 func WithDriver(s string) func(*Options) {
 	return func(options *Options) {
 		getOptionsOrSetDefault(options).driver = s
@@ -70,14 +90,88 @@ func WithFsRoot(s string) func(*Options) {
 	}
 }
 
+func WithMax(n int) func(*Options) {
+	return func(options *Options) {
+		if n > 0 {
+			getOptionsOrSetDefault(options).max = n
+		}
+	}
+}
+
+func WithDryRun(flag bool) func(*Options) {
+	return func(options *Options) {
+		getOptionsOrSetDefault(options).dryRun = flag
+	}
+}
+
+// WithCodeMigrations replaces the registered go migrations
+func WithCodeMigrations(list ...CodeMigration) func(*Options) {
+	return func(options *Options) {
+		codes := make(map[string]CodeMigration, len(list))
+		for _, item := range list {
+			codes[item.Id] = item
+		}
+		getOptionsOrSetDefault(options).codes = codes
+	}
+}
+
+// WithGormConfig is used to open the *gorm.DB passed to go migrations(naming strategy, logger...)
+func WithGormConfig(cfg *gorm.Config) func(*Options) {
+	return func(options *Options) {
+		getOptionsOrSetDefault(options).gormConfig = cfg
+	}
+}
+
+// WithLockTimeout limits the overall time spent waiting for the advisory lock, 0 means wait until ctx is done
+func WithLockTimeout(d time.Duration) func(*Options) {
+	return func(options *Options) {
+		if d >= 0 {
+			getOptionsOrSetDefault(options).lock.timeout = d
+		}
+	}
+}
+
+// WithLockFailFast returns ErrLockHeld immediately if another session holds the advisory lock
+func WithLockFailFast(flag bool) func(*Options) {
+	return func(options *Options) {
+		getOptionsOrSetDefault(options).lock.failFast = flag
+	}
+}
+
+// WithLockHolderInterval controls how often the lock holder is logged while waiting
+func WithLockHolderInterval(d time.Duration) func(*Options) {
+	return func(options *Options) {
+		if d > 0 {
+			getOptionsOrSetDefault(options).lock.holderInterval = d
+		}
+	}
+}
+
 func getOptionsOrSetDefault(options *Options) *Options {
 	if options == nil {
 		return &Options{
-			driver:      "mysql",
+			ctx:         context.Background(),
+			driver:      dialect.Mysql,
 			uri:         "root:123456@tcp(127.0.0.1:3306)/oreo_admin_go?charset=utf8mb4&collation=utf8mb4_general_ci&parseTime=True&loc=UTC&timeout=10000ms",
 			lockName:    "MigrationLock",
 			changeTable: "schema_migrations",
+			lock: LockOptions{
+				timeout:        10 * time.Minute,
+				holderInterval: 15 * time.Second,
+			},
+			codes: registeredCodeMigrations(),
 		}
 	}
 	return options
 }
+
+func (ops *Options) getDialect() (dialect.Dialect, error) {
+	if ops.dialect == nil {
+		d, err := dialect.Get(ops.driver)
+		if err != nil {
+			return nil, err
+		}
+		ops.dialect = d
+	}
+	return ops.dialect, nil
+}
@@ -0,0 +1,5 @@
+-- +migrate Up
+CREATE TABLE a (id INTEGER PRIMARY KEY);
+
+-- +migrate Down
+DROP TABLE a;
@@ -0,0 +1,5 @@
+-- +migrate Up
+CREATE TABLE c (id INTEGER PRIMARY KEY);
+
+-- +migrate Down
+DROP TABLE c;
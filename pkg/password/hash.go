@@ -0,0 +1,138 @@
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	Bcrypt   = "bcrypt"
+	Argon2id = "argon2id"
+)
+
+var ErrInvalidHash = errors.New("invalid password hash")
+
+// Argon2Params are the cost of argon2id, Memory is KiB
+type Argon2Params struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// Hasher hashes passwords with Algorithm, hashes of other algorithms or parameters are still verified
+type Hasher struct {
+	Algorithm  string
+	BcryptCost int
+	Argon2     Argon2Params
+}
+
+// DefaultHasher is replaced by the configuration on start
+var DefaultHasher = Hasher{
+	Algorithm:  Argon2id,
+	BcryptCost: bcrypt.DefaultCost,
+	Argon2: Argon2Params{
+		Memory:      64 * 1024,
+		Iterations:  3,
+		Parallelism: 2,
+		SaltLength:  16,
+		KeyLength:   32,
+	},
+}
+
+// Hash returns bcrypt's modular crypt format or argon2id's PHC string, e.g. $argon2id$v=19$m=65536,t=3,p=2$salt$key
+func (h Hasher) Hash(s string) (string, error) {
+	if h.Algorithm == Bcrypt {
+		b, err := bcrypt.GenerateFromPassword([]byte(s), h.BcryptCost)
+		return string(b), err
+	}
+	p := h.Argon2
+	salt := make([]byte, p.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(s), salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+	return fmt.Sprintf(
+		"$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, p.Memory, p.Iterations, p.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// Verify compares s with hash of any supported algorithm
+func (h Hasher) Verify(hash, s string) (bool, error) {
+	if strings.HasPrefix(hash, "$argon2id$") {
+		p, salt, key, err := parseArgon2(hash)
+		if err != nil {
+			return false, err
+		}
+		other := argon2.IDKey([]byte(s), salt, p.Iterations, p.Memory, p.Parallelism, uint32(len(key)))
+		return subtle.ConstantTimeCompare(key, other) == 1, nil
+	}
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(s))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.Wrap(ErrInvalidHash, err.Error())
+	}
+	return true, nil
+}
+
+// NeedsRehash reports whether hash was made by another algorithm or parameters, it is re-hashed after a successful login
+func (h Hasher) NeedsRehash(hash string) bool {
+	if strings.HasPrefix(hash, "$argon2id$") {
+		if h.Algorithm != Argon2id {
+			return true
+		}
+		p, salt, key, err := parseArgon2(hash)
+		if err != nil {
+			return true
+		}
+		return p.Memory != h.Argon2.Memory || p.Iterations != h.Argon2.Iterations || p.Parallelism != h.Argon2.Parallelism ||
+			uint32(len(salt)) != h.Argon2.SaltLength || uint32(len(key)) != h.Argon2.KeyLength
+	}
+	if h.Algorithm != Bcrypt {
+		return true
+	}
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || cost != h.BcryptCost
+}
+
+func parseArgon2(hash string) (p Argon2Params, salt, key []byte, err error) {
+	// "", argon2id, v=19, m=..,t=..,p=.., salt, key
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		err = ErrInvalidHash
+		return
+	}
+	var version int
+	if _, err = fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		err = ErrInvalidHash
+		return
+	}
+	if _, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Iterations, &p.Parallelism); err != nil {
+		err = ErrInvalidHash
+		return
+	}
+	if salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		err = ErrInvalidHash
+		return
+	}
+	if key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil || len(key) == 0 {
+		err = ErrInvalidHash
+		return
+	}
+	p.SaltLength = uint32(len(salt))
+	p.KeyLength = uint32(len(key))
+	return
+}
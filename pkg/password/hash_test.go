@@ -0,0 +1,95 @@
+package password
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestHasherVerify(t *testing.T) {
+	argon := testHasher(Argon2id)
+	bc := testHasher(Bcrypt)
+	argonHash, err := argon.Hash("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bcryptHash, err := bc.Hash("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(argonHash, "$argon2id$v=19$m=1024,t=1,p=1$") || !strings.HasPrefix(bcryptHash, "$2a$") {
+		t.Fatalf("hashes = %s, %s", argonHash, bcryptHash)
+	}
+
+	tests := []struct {
+		name    string
+		hash    string
+		s       string
+		want    bool
+		wantErr bool
+	}{
+		{"argon2id", argonHash, "secret", true, false},
+		{"argon2id mismatch", argonHash, "Secret", false, false},
+		{"bcrypt", bcryptHash, "secret", true, false},
+		{"bcrypt mismatch", bcryptHash, "secret ", false, false},
+		{"truncated argon2id", argonHash[:strings.LastIndex(argonHash, "$")], "secret", false, true},
+		{"argon2id version", strings.Replace(argonHash, "v=19", "v=16", 1), "secret", false, true},
+		{"argon2id salt", strings.Replace(argonHash, "p=1$", "p=1$!", 1), "secret", false, true},
+		{"unknown", "md5:5ebe2294ecd0e0f08eab7690d2a6ee69", "secret", false, true},
+		{"empty", "", "secret", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the hasher algorithm does not matter for verifying
+			for _, h := range []Hasher{argon, bc} {
+				ok, err := h.Verify(tt.hash, tt.s)
+				if ok != tt.want || (err != nil) != tt.wantErr {
+					t.Fatalf("Verify() = %v, %v, want %v, wantErr %v", ok, err, tt.want, tt.wantErr)
+				}
+				if err != nil && !errors.Is(err, ErrInvalidHash) {
+					t.Errorf("err = %v, want ErrInvalidHash", err)
+				}
+			}
+		})
+	}
+}
+
+func TestHasherNeedsRehash(t *testing.T) {
+	argon := testHasher(Argon2id)
+	bc := testHasher(Bcrypt)
+	argonHash, _ := argon.Hash("secret")
+	bcryptHash, _ := bc.Hash("secret")
+
+	stronger := testHasher(Argon2id)
+	stronger.Argon2.Iterations = 2
+	longerKey := testHasher(Argon2id)
+	longerKey.Argon2.KeyLength = 64
+	costlier := testHasher(Bcrypt)
+	costlier.BcryptCost = bcrypt.MinCost + 1
+
+	tests := []struct {
+		name string
+		h    Hasher
+		hash string
+		want bool
+	}{
+		{"same argon2id", argon, argonHash, false},
+		{"argon2id iterations", stronger, argonHash, true},
+		{"argon2id key length", longerKey, argonHash, true},
+		{"argon2id to bcrypt", bc, argonHash, true},
+		{"same bcrypt", bc, bcryptHash, false},
+		{"bcrypt cost", costlier, bcryptHash, true},
+		{"bcrypt to argon2id", argon, bcryptHash, true},
+		{"invalid argon2id", argon, "$argon2id$v=19$m=1024", true},
+		{"invalid bcrypt", bc, "$2a$", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.h.NeedsRehash(tt.hash); got != tt.want {
+				t.Errorf("NeedsRehash() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
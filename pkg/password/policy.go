@@ -0,0 +1,135 @@
+package password
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ppxb/oreo-admin-go/pkg/utils"
+)
+
+const (
+	RuleMinLength     = "min_length"
+	RuleMaxLength     = "max_length"
+	RuleLower         = "lower"
+	RuleUpper         = "upper"
+	RuleDigit         = "digit"
+	RuleSymbol        = "symbol"
+	RuleContinuousNum = "continuous_num"
+	RuleChinese       = "chinese"
+	RuleHistory       = "history"
+)
+
+// Policy decides which passwords are accepted, zero values disable the rules
+type Policy struct {
+	MinLength    int
+	MaxLength    int
+	RequireLower bool
+	RequireUpper bool
+	RequireDigit bool
+	// RequireSymbol needs a printable ascii character which is not a letter or digit
+	RequireSymbol bool
+	// MaxContinuousNum is the longest ascending or descending digit run, e.g. 3 rejects 1234 and 4321
+	MaxContinuousNum int
+	AllowChinese     bool
+	// History rejects the last History passwords
+	History int
+	// MaxAge expires a password after it is changed
+	MaxAge time.Duration
+}
+
+// DefaultPolicy is used by the password rule of requests, replaced by the configuration on start
+var DefaultPolicy = Policy{
+	MinLength:        8,
+	MaxLength:        32,
+	RequireLower:     true,
+	RequireDigit:     true,
+	MaxContinuousNum: 3,
+}
+
+// Violation is a broken rule, Param is the limit of the rule(e.g. 8 of min_length)
+type Violation struct {
+	Rule  string `json:"rule"`
+	Param int    `json:"param"`
+}
+
+// PolicyError lists every broken rule so that users can fix them at once
+type PolicyError struct {
+	Violations []Violation
+}
+
+func (e *PolicyError) Error() string {
+	rules := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		rules = append(rules, fmt.Sprintf("%s(%d)", v.Rule, v.Param))
+	}
+	return "password violates policy: " + strings.Join(rules, ", ")
+}
+
+// Check returns a *PolicyError if s breaks any rule
+func (p Policy) Check(s string) error {
+	var vs []Violation
+	n := len([]rune(s))
+	if p.MinLength > 0 && n < p.MinLength {
+		vs = append(vs, Violation{Rule: RuleMinLength, Param: p.MinLength})
+	}
+	if p.MaxLength > 0 && n > p.MaxLength {
+		vs = append(vs, Violation{Rule: RuleMaxLength, Param: p.MaxLength})
+	}
+	var lower, upper, digit, symbol bool
+	for _, r := range s {
+		switch {
+		case r >= 'a' && r <= 'z':
+			lower = true
+		case r >= 'A' && r <= 'Z':
+			upper = true
+		case r >= '0' && r <= '9':
+			digit = true
+		case r > ' ' && r < 0x7f:
+			symbol = true
+		}
+	}
+	if p.RequireLower && !lower {
+		vs = append(vs, Violation{Rule: RuleLower})
+	}
+	if p.RequireUpper && !upper {
+		vs = append(vs, Violation{Rule: RuleUpper})
+	}
+	if p.RequireDigit && !digit {
+		vs = append(vs, Violation{Rule: RuleDigit})
+	}
+	if p.RequireSymbol && !symbol {
+		vs = append(vs, Violation{Rule: RuleSymbol})
+	}
+	if p.MaxContinuousNum > 0 && utils.StrContainsContinuousNum(s) > p.MaxContinuousNum {
+		vs = append(vs, Violation{Rule: RuleContinuousNum, Param: p.MaxContinuousNum})
+	}
+	if !p.AllowChinese && utils.StrContainsChinese(s) {
+		vs = append(vs, Violation{Rule: RuleChinese})
+	}
+	if len(vs) > 0 {
+		return &PolicyError{Violations: vs}
+	}
+	return nil
+}
+
+// CheckHistory rejects s if it matches one of the hashes of previous passwords, newest first
+func (p Policy) CheckHistory(h Hasher, s string, hashes []string) error {
+	if p.History <= 0 {
+		return nil
+	}
+	if len(hashes) > p.History {
+		hashes = hashes[:p.History]
+	}
+	for _, item := range hashes {
+		if ok, _ := h.Verify(item, s); ok {
+			return &PolicyError{Violations: []Violation{{Rule: RuleHistory, Param: p.History}}}
+		}
+	}
+	return nil
+}
+
+// Expired reports whether a password changed at changedAt must be changed again
+func (p Policy) Expired(changedAt time.Time) bool {
+	return p.MaxAge > 0 && time.Since(changedAt) > p.MaxAge
+}
@@ -0,0 +1,127 @@
+package password
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestPolicyCheck(t *testing.T) {
+	strict := Policy{
+		MinLength:        8,
+		MaxLength:        16,
+		RequireLower:     true,
+		RequireUpper:     true,
+		RequireDigit:     true,
+		RequireSymbol:    true,
+		MaxContinuousNum: 3,
+	}
+	tests := []struct {
+		name   string
+		policy Policy
+		s      string
+		// rules are the broken rules in order, none if empty
+		rules []string
+	}{
+		{"ok", strict, "Abc!9573x", nil},
+		{"too short", strict, "Ab!1", []string{RuleMinLength}},
+		{"too long", strict, "Abcdefgh!1357913579", []string{RuleMaxLength}},
+		{"runes are counted", Policy{MaxLength: 4}, "密码密码", []string{RuleChinese}},
+		{"missing classes", strict, "abcdefgh", []string{RuleUpper, RuleDigit, RuleSymbol}},
+		{"ascending digits", strict, "Abc!1234x", []string{RuleContinuousNum}},
+		{"descending digits", strict, "Abc!4321x", []string{RuleContinuousNum}},
+		{"three digits are allowed", strict, "Abc!123x9", nil},
+		{"digits split by letters", strict, "Aa!1b2c3d4", nil},
+		{"runs split by letters", strict, "Ab!123x456", nil},
+		{"direction changes", strict, "Ab!12321x", nil},
+		{"chinese", Policy{}, "密码abc", []string{RuleChinese}},
+		{"chinese allowed", Policy{AllowChinese: true}, "密码abc", nil},
+		{"zero policy", Policy{}, "", nil},
+		{"every rule", strict, "1234", []string{RuleMinLength, RuleLower, RuleUpper, RuleSymbol, RuleContinuousNum}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.Check(tt.s)
+			var rules []string
+			var e *PolicyError
+			if errors.As(err, &e) {
+				for _, v := range e.Violations {
+					rules = append(rules, v.Rule)
+				}
+			} else if err != nil {
+				t.Fatalf("err = %v, want *PolicyError", err)
+			}
+			if !reflect.DeepEqual(rules, tt.rules) {
+				t.Errorf("Check(%q) = %v, want %v", tt.s, rules, tt.rules)
+			}
+		})
+	}
+}
+
+func TestPolicyCheckHistory(t *testing.T) {
+	h := testHasher(Bcrypt)
+	// newest first
+	var hashes []string
+	for _, s := range []string{"third", "second", "first"} {
+		hash, err := h.Hash(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		hashes = append(hashes, hash)
+	}
+	tests := []struct {
+		name    string
+		history int
+		s       string
+		wantErr bool
+	}{
+		{"disabled", 0, "third", false},
+		{"latest", 1, "third", true},
+		{"within history", 2, "second", true},
+		{"older than history", 2, "first", false},
+		{"new password", 3, "fourth", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Policy{History: tt.history}.CheckHistory(h, tt.s, hashes)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			var e *PolicyError
+			if err != nil && (!errors.As(err, &e) || e.Violations[0] != Violation{Rule: RuleHistory, Param: tt.history}) {
+				t.Errorf("err = %#v", err)
+			}
+		})
+	}
+}
+
+func TestPolicyExpired(t *testing.T) {
+	p := Policy{MaxAge: time.Hour}
+	if p.Expired(time.Now().Add(-time.Minute)) {
+		t.Error("a new password is expired")
+	}
+	if !p.Expired(time.Now().Add(-2 * time.Hour)) {
+		t.Error("an old password is not expired")
+	}
+	if (Policy{}).Expired(time.Time{}) {
+		t.Error("passwords expire without MaxAge")
+	}
+}
+
+// testHasher uses the lowest costs to keep tests fast
+func testHasher(algorithm string) Hasher {
+	return Hasher{
+		Algorithm:  algorithm,
+		BcryptCost: bcrypt.MinCost,
+		Argon2: Argon2Params{
+			Memory:      1024,
+			Iterations:  1,
+			Parallelism: 1,
+			SaltLength:  16,
+			KeyLength:   32,
+		},
+	}
+}
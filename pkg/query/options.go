@@ -5,6 +5,8 @@ import (
 
 	"github.com/redis/go-redis/v9"
 	"gorm.io/gorm/schema"
+
+	"github.com/ppxb/oreo-admin-go/pkg/utils"
 )
 
 type RedisOptions struct {
@@ -24,3 +26,41 @@ func getRedisOptionsOrSetDefault(options *RedisOptions) *RedisOptions {
 	}
 	return options
 }
+
+func WithRedisCtx(ctx context.Context) func(*RedisOptions) {
+	return func(options *RedisOptions) {
+		if !utils.InterfaceIsNil(ctx) {
+			getRedisOptionsOrSetDefault(options).ctx = ctx
+		}
+	}
+}
+
+func WithRedisClient(rd redis.UniversalClient) func(*RedisOptions) {
+	return func(options *RedisOptions) {
+		if rd != nil {
+			getRedisOptionsOrSetDefault(options).redis = rd
+		}
+	}
+}
+
+func WithRedisUri(uri string) func(*RedisOptions) {
+	return func(options *RedisOptions) {
+		getRedisOptionsOrSetDefault(options).redisUri = uri
+	}
+}
+
+func WithRedisDatabase(database string) func(*RedisOptions) {
+	return func(options *RedisOptions) {
+		if database != "" {
+			getRedisOptionsOrSetDefault(options).database = database
+		}
+	}
+}
+
+func WithRedisNamingStrategy(name schema.Namer) func(*RedisOptions) {
+	return func(options *RedisOptions) {
+		if name != nil {
+			getRedisOptionsOrSetDefault(options).namingStrategy = name
+		}
+	}
+}
@@ -2,16 +2,27 @@ package query
 
 import (
 	"context"
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/hibiken/asynq"
 	"github.com/pkg/errors"
 	"github.com/redis/go-redis/v9"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
 	"github.com/ppxb/oreo-admin-go/pkg/tracing"
 )
 
+// TimeFormat is the layout of time values in mirrored rows
+const TimeFormat = "2006-01-02T15:04:05.000000Z07:00"
+
+// Redis reads rows mirrored into redis hashes with a chainable api like gorm, conditions are evaluated in memory
 type Redis struct {
 	ops        RedisOptions
 	Ctx        context.Context
@@ -41,14 +52,76 @@ func NewRedis(options ...func(*RedisOptions)) Redis {
 		panic("redis namingStrategy is empty")
 	}
 	rds := Redis{
-		ops:   *ops,
-		clone: 1,
+		ops:        *ops,
+		clone:      1,
+		cacheStore: &sync.Map{},
 	}
 	rdsCtx := tracing.NewId(ops.ctx)
 	rds.Ctx = rdsCtx
+	rds.Statement = &gorm.Statement{
+		Context: rdsCtx,
+		Clauses: map[string]clause.Clause{},
+	}
 	return rds
 }
 
+// TableKey is the hash key which mirrors a table, each field is a primary key and each value a JSON row
+func TableKey(database, table string) string {
+	return fmt.Sprintf("%s_%s", database, table)
+}
+
+// RowField is the hash field of a row, composite primary keys are joined in schema order
+func RowField(values ...interface{}) string {
+	items := make([]string, 0, len(values))
+	for _, v := range values {
+		s, _ := FormatValue(v)
+		items = append(items, s)
+	}
+	return strings.Join(items, ",")
+}
+
+// FormatValue converts a column value to the string stored in the row JSON, ok is false for NULL,
+// times use a fixed width UTC layout so that they sort as strings
+func FormatValue(v interface{}) (string, bool) {
+	switch val := v.(type) {
+	case nil:
+		return "", false
+	case string:
+		return val, true
+	case []byte:
+		return string(val), true
+	case time.Time:
+		return val.UTC().Format(TimeFormat), true
+	case bool:
+		if val {
+			return "1", true
+		}
+		return "0", true
+	case float32:
+		return strconv.FormatFloat(float64(val), 'f', -1, 32), true
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64), true
+	case driver.Valuer:
+		rv := reflect.ValueOf(val)
+		if rv.Kind() == reflect.Ptr && rv.IsNil() {
+			return "", false
+		}
+		dv, err := val.Value()
+		if err != nil {
+			return "", false
+		}
+		return FormatValue(dv)
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return "", false
+		}
+		return FormatValue(rv.Elem().Interface())
+	}
+	return fmt.Sprint(v), true
+}
+
 func ParseRedisURI(uri string) (client redis.UniversalClient, err error) {
 	var opt asynq.RedisConnOpt
 	if uri != "" {
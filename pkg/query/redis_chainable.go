@@ -0,0 +1,114 @@
+package query
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Table specify the table name, by default it is parsed from the model with the naming strategy
+func (rd *Redis) Table(name string) *Redis {
+	ins := rd.getInstance()
+	ins.Statement.Table = name
+	return ins
+}
+
+// Where add conditions, supports:
+//
+//	Where("name = ?", "jack")
+//	Where("id IN ?", []uint{1, 2})
+//	Where("name LIKE ? AND age >= ?", "%ja%", 18)
+//	Where("created_at BETWEEN ? AND ?", start, end)
+//	Where(map[string]interface{}{"status": 1})
+//	Where(clause.Gt{Column: "age", Value: 18})
+func (rd *Redis) Where(query interface{}, args ...interface{}) *Redis {
+	ins := rd.getInstance()
+	var exprs []clause.Expression
+	switch q := query.(type) {
+	case string:
+		if strings.TrimSpace(q) == "" {
+			return ins
+		}
+		exprs = append(exprs, clause.Expr{SQL: q, Vars: args})
+	case map[string]interface{}:
+		for k, v := range q {
+			exprs = append(exprs, clause.Eq{Column: k, Value: v})
+		}
+	case clause.Expression:
+		exprs = append(exprs, q)
+	default:
+		ins.AddError(errors.Errorf("unsupported where condition %T", query))
+		return ins
+	}
+	ins.Statement.AddClause(clause.Where{Exprs: exprs})
+	return ins
+}
+
+// Order specify order when retrieving records, like: Order("sort, created_at desc")
+func (rd *Redis) Order(value interface{}) *Redis {
+	ins := rd.getInstance()
+	switch v := value.(type) {
+	case clause.OrderByColumn:
+		ins.Statement.AddClause(clause.OrderBy{Columns: []clause.OrderByColumn{v}})
+	case string:
+		var columns []clause.OrderByColumn
+		for _, item := range strings.Split(v, ",") {
+			fields := strings.Fields(item)
+			if len(fields) == 0 {
+				continue
+			}
+			columns = append(columns, clause.OrderByColumn{
+				Column: clause.Column{Name: fields[0]},
+				Desc:   len(fields) > 1 && strings.EqualFold(fields[1], "desc"),
+			})
+		}
+		if len(columns) > 0 {
+			ins.Statement.AddClause(clause.OrderBy{Columns: columns})
+		}
+	default:
+		ins.AddError(errors.Errorf("unsupported order %T", value))
+	}
+	return ins
+}
+
+// Limit specify the number of records to be retrieved, -1 cancels the limit
+func (rd *Redis) Limit(limit int) *Redis {
+	ins := rd.getInstance()
+	ins.Statement.AddClause(clause.Limit{Limit: &limit})
+	return ins
+}
+
+// Offset specify the number of records to skip, -1 cancels the offset
+func (rd *Redis) Offset(offset int) *Redis {
+	ins := rd.getInstance()
+	ins.Statement.AddClause(clause.Limit{Offset: offset})
+	return ins
+}
+
+// AddError keeps the first error like gorm
+func (rd *Redis) AddError(err error) error {
+	if rd.Error == nil {
+		rd.Error = err
+	} else if err != nil && !errors.Is(rd.Error, err) {
+		rd.Error = errors.Wrap(rd.Error, err.Error())
+	}
+	return rd.Error
+}
+
+func (rd *Redis) getInstance() *Redis {
+	if rd.clone > 0 {
+		return &Redis{
+			ops:        rd.ops,
+			Ctx:        rd.Ctx,
+			Error:      rd.Error,
+			cacheStore: rd.cacheStore,
+			Statement: &gorm.Statement{
+				Context: rd.Ctx,
+				Clauses: map[string]clause.Clause{},
+			},
+		}
+	}
+	return rd
+}
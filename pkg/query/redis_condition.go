@@ -0,0 +1,314 @@
+package query
+
+import (
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm/clause"
+)
+
+type row map[string]*string
+
+type condition struct {
+	column string
+	op     string
+	values []interface{}
+	like   *regexp.Regexp
+}
+
+var (
+	andRe       = regexp.MustCompile(`(?i)\s+and\s+`)
+	orRe        = regexp.MustCompile(`(?i)\s+or\s+`)
+	conditionRe = regexp.MustCompile(`(?i)^([\w.` + "`" + `]+)\s*(=|!=|<>|>=|<=|>|<|not\s+in|in|not\s+like|like|between|is\s+not\s+null|is\s+null)\s*(.*)$`)
+	// numberRe matches numbers without zero padding, the groups are the fraction and exponent
+	numberRe = regexp.MustCompile(`^-?(?:0|[1-9]\d*)(\.\d+)?([eE][+-]?\d+)?$`)
+)
+
+// parseWhere converts where clause to conditions, like patterns are compiled once per query
+func parseWhere(where clause.Where) ([]condition, error) {
+	var list []condition
+	for _, expr := range where.Exprs {
+		conditions, err := parseExpression(expr)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, conditions...)
+	}
+	for i, c := range list {
+		if c.column == "" {
+			return nil, errors.New("condition column is empty")
+		}
+		if (c.op == "like" || c.op == "not like") && len(c.values) > 0 {
+			pattern, _ := FormatValue(c.values[0])
+			list[i].like = likeRegexp(pattern)
+		}
+	}
+	return list, nil
+}
+
+// parseExpr splits a sql expression joined by AND into conditions, OR and sub queries are not supported
+func parseExpr(expr clause.Expr) ([]condition, error) {
+	if orRe.MatchString(expr.SQL) {
+		return nil, errors.Errorf("unsupported condition: %s", expr.SQL)
+	}
+	parts := andRe.Split(strings.TrimSpace(expr.SQL), -1)
+	vars := expr.Vars
+	list := make([]condition, 0, len(parts))
+	for i := 0; i < len(parts); i++ {
+		matches := conditionRe.FindStringSubmatch(strings.TrimSpace(parts[i]))
+		if matches == nil {
+			return nil, errors.Errorf("unsupported condition: %s", parts[i])
+		}
+		c := condition{
+			column: columnName(matches[1]),
+			op:     strings.ToLower(strings.Join(strings.Fields(matches[2]), " ")),
+		}
+		rest := strings.TrimSpace(matches[3])
+		// the second operand of between was split as a separate part
+		if c.op == "between" && i+1 < len(parts) {
+			i++
+			rest += " and " + strings.TrimSpace(parts[i])
+		}
+		n := strings.Count(rest, "?")
+		if n > len(vars) {
+			return nil, errors.Errorf("condition %s expects %d vars", parts[i], n)
+		}
+		if n == 0 && rest != "" {
+			// literal value like: status = 1
+			c.values = []interface{}{strings.Trim(rest, `'"`)}
+		}
+		c.values = append(c.values, vars[:n]...)
+		vars = vars[n:]
+		if c.op == "in" || c.op == "not in" {
+			c.values = flatten(c.values)
+		}
+		list = append(list, c)
+	}
+	return list, nil
+}
+
+func parseExpression(expr clause.Expression) ([]condition, error) {
+	switch e := expr.(type) {
+	case clause.Expr:
+		return parseExpr(e)
+	case clause.Eq:
+		return []condition{{column: exprColumn(e.Column), op: "=", values: []interface{}{e.Value}}}, nil
+	case clause.Neq:
+		return []condition{{column: exprColumn(e.Column), op: "!=", values: []interface{}{e.Value}}}, nil
+	case clause.Gt:
+		return []condition{{column: exprColumn(e.Column), op: ">", values: []interface{}{e.Value}}}, nil
+	case clause.Gte:
+		return []condition{{column: exprColumn(e.Column), op: ">=", values: []interface{}{e.Value}}}, nil
+	case clause.Lt:
+		return []condition{{column: exprColumn(e.Column), op: "<", values: []interface{}{e.Value}}}, nil
+	case clause.Lte:
+		return []condition{{column: exprColumn(e.Column), op: "<=", values: []interface{}{e.Value}}}, nil
+	case clause.Like:
+		return []condition{{column: exprColumn(e.Column), op: "like", values: []interface{}{e.Value}}}, nil
+	case clause.IN:
+		return []condition{{column: exprColumn(e.Column), op: "in", values: flatten(e.Values)}}, nil
+	case clause.AndConditions:
+		var list []condition
+		for _, item := range e.Exprs {
+			conditions, err := parseExpression(item)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, conditions...)
+		}
+		return list, nil
+	}
+	return nil, errors.Errorf("unsupported condition %T", expr)
+}
+
+func (c condition) match(r row) bool {
+	v := r[c.column]
+	switch c.op {
+	case "is null":
+		return v == nil
+	case "is not null":
+		return v != nil
+	}
+	// like sql, comparing with NULL is never true
+	if v == nil {
+		return false
+	}
+	switch c.op {
+	case "=", "!=", "<>", ">", ">=", "<", "<=":
+		n, ok := c.compare(*v, 0)
+		if !ok {
+			return false
+		}
+		switch c.op {
+		case "=":
+			return n == 0
+		case "!=", "<>":
+			return n != 0
+		case ">":
+			return n > 0
+		case ">=":
+			return n >= 0
+		case "<":
+			return n < 0
+		}
+		return n <= 0
+	case "between":
+		low, ok1 := c.compare(*v, 0)
+		high, ok2 := c.compare(*v, 1)
+		return ok1 && ok2 && low >= 0 && high <= 0
+	case "in", "not in":
+		found := false
+		for i := range c.values {
+			if n, ok := c.compare(*v, i); ok && n == 0 {
+				found = true
+				break
+			}
+		}
+		return found == (c.op == "in")
+	case "like", "not like":
+		if c.like == nil {
+			return false
+		}
+		return c.like.MatchString(*v) == (c.op == "like")
+	}
+	return false
+}
+
+// compare returns -1/0/1 for the row value against values[i], ok is false when values[i] is NULL
+func (c condition) compare(v string, i int) (int, bool) {
+	if i >= len(c.values) {
+		return 0, false
+	}
+	expected, ok := FormatValue(c.values[i])
+	if !ok {
+		return 0, false
+	}
+	return compareValue(v, expected), true
+}
+
+// compareValue compares integers exactly, decimals as floats and everything else as strings,
+// zero padded values like 0123 are strings
+func compareValue(a, b string) int {
+	ma := numberRe.FindStringSubmatch(a)
+	mb := numberRe.FindStringSubmatch(b)
+	if ma == nil || mb == nil {
+		return strings.Compare(a, b)
+	}
+	if ma[1] == "" && ma[2] == "" && mb[1] == "" && mb[2] == "" {
+		if n, ok := compareInteger(a, b); ok {
+			return n
+		}
+	}
+	fa, errA := strconv.ParseFloat(a, 64)
+	fb, errB := strconv.ParseFloat(b, 64)
+	if errA != nil || errB != nil {
+		return strings.Compare(a, b)
+	}
+	switch {
+	case fa < fb:
+		return -1
+	case fa > fb:
+		return 1
+	}
+	return 0
+}
+
+// compareInteger compares integers in the range of int64 and uint64 without losing precision
+func compareInteger(a, b string) (int, bool) {
+	negA, absA, okA := parseInteger(a)
+	negB, absB, okB := parseInteger(b)
+	if !okA || !okB {
+		return 0, false
+	}
+	n := 0
+	switch {
+	case negA != negB:
+		n = 1
+		if negA {
+			n = -1
+		}
+		// -0 equals 0
+		if absA == 0 && absB == 0 {
+			n = 0
+		}
+		return n, true
+	case absA < absB:
+		n = -1
+	case absA > absB:
+		n = 1
+	}
+	if negA {
+		n = -n
+	}
+	return n, true
+}
+
+// parseInteger splits s into the sign and absolute value
+func parseInteger(s string) (neg bool, abs uint64, ok bool) {
+	neg = strings.HasPrefix(s, "-")
+	abs, err := strconv.ParseUint(strings.TrimPrefix(s, "-"), 10, 64)
+	return neg, abs, err == nil
+}
+
+// likeRegexp converts sql LIKE to a case-insensitive regexp like mysql's default collation
+func likeRegexp(pattern string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString("(?is)^")
+	escaped := false
+	for _, r := range pattern {
+		switch {
+		case escaped:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '%':
+			b.WriteString(".*")
+		case r == '_':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.MustCompile(b.String())
+}
+
+func flatten(values []interface{}) []interface{} {
+	list := make([]interface{}, 0, len(values))
+	for _, v := range values {
+		rv := reflect.ValueOf(v)
+		if v != nil && (rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array) {
+			if _, ok := v.([]byte); !ok {
+				for i := 0; i < rv.Len(); i++ {
+					list = append(list, rv.Index(i).Interface())
+				}
+				continue
+			}
+		}
+		list = append(list, v)
+	}
+	return list
+}
+
+func exprColumn(column interface{}) string {
+	switch c := column.(type) {
+	case clause.Column:
+		return columnName(c.Name)
+	case string:
+		return columnName(c)
+	}
+	return ""
+}
+
+// columnName removes the table qualifier and quotes, user.name -> name
+func columnName(s string) string {
+	s = strings.ReplaceAll(s, "`", "")
+	if i := strings.LastIndex(s, "."); i >= 0 {
+		s = s[i+1:]
+	}
+	return s
+}
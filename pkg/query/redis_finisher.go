@@ -0,0 +1,331 @@
+package query
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+var deletedAtType = reflect.TypeOf(gorm.DeletedAt{})
+
+// First finds the first record ordered by primary key(or Order), returns gorm.ErrRecordNotFound if none
+func (rd *Redis) First(dest interface{}) *Redis {
+	ins := rd.getInstance()
+	if _, ok := ins.Statement.Clauses["ORDER BY"]; !ok {
+		ins.Statement.AddClause(clause.OrderBy{Columns: []clause.OrderByColumn{{Column: clause.Column{Name: clause.PrimaryKey}}}})
+	}
+	ins = ins.Limit(1)
+	rows, sch, err := ins.query(dest)
+	if err != nil {
+		ins.AddError(err)
+		return ins
+	}
+	rows = ins.paginate(rows)
+	if len(rows) == 0 {
+		ins.AddError(gorm.ErrRecordNotFound)
+		return ins
+	}
+	rv := reflect.ValueOf(dest)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+		ins.AddError(errors.Errorf("first dest must be a pointer to struct, got %T", dest))
+		return ins
+	}
+	ins.AddError(ins.scan(sch, rv.Elem(), rows[0]))
+	return ins
+}
+
+// Find finds all records matching given conditions, dest must be a pointer to slice
+func (rd *Redis) Find(dest interface{}) *Redis {
+	ins := rd.getInstance()
+	rv := reflect.ValueOf(dest)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Slice {
+		ins.AddError(errors.Errorf("find dest must be a pointer to slice, got %T", dest))
+		return ins
+	}
+	rows, sch, err := ins.query(dest)
+	if err != nil {
+		ins.AddError(err)
+		return ins
+	}
+	rows = ins.paginate(rows)
+
+	slice := rv.Elem()
+	elemType := slice.Type().Elem()
+	isPtr := elemType.Kind() == reflect.Ptr
+	if isPtr {
+		elemType = elemType.Elem()
+	}
+	list := reflect.MakeSlice(slice.Type(), 0, len(rows))
+	for _, r := range rows {
+		elem := reflect.New(elemType)
+		if err = ins.scan(sch, elem.Elem(), r); err != nil {
+			ins.AddError(err)
+			return ins
+		}
+		if isPtr {
+			list = reflect.Append(list, elem)
+		} else {
+			list = reflect.Append(list, elem.Elem())
+		}
+	}
+	slice.Set(list)
+	return ins
+}
+
+// Count counts records matching given conditions, Limit/Offset are ignored like gorm
+func (rd *Redis) Count(count *int64) *Redis {
+	ins := rd.getInstance()
+	rows, _, err := ins.query(ins.Statement.Model)
+	if err != nil {
+		ins.AddError(err)
+		return ins
+	}
+	*count = int64(len(rows))
+	return ins
+}
+
+// Unscoped disables the soft delete filter
+func (rd *Redis) Unscoped() *Redis {
+	ins := rd.getInstance()
+	ins.Statement.Unscoped = true
+	return ins
+}
+
+// Model specify the model used by Count when Table is not given
+func (rd *Redis) Model(value interface{}) *Redis {
+	ins := rd.getInstance()
+	ins.Statement.Model = value
+	return ins
+}
+
+// query loads rows of the table and applies conditions and order, model may be nil when Table is given
+func (rd *Redis) query(model interface{}) ([]row, *schema.Schema, error) {
+	if rd.Error != nil {
+		return nil, nil, rd.Error
+	}
+
+	var sch *schema.Schema
+	if model != nil {
+		var err error
+		if sch, err = schema.Parse(model, rd.cacheStore, rd.ops.namingStrategy); err != nil {
+			return nil, nil, err
+		}
+	}
+	table := rd.Statement.Table
+	if table == "" && sch != nil {
+		table = sch.Table
+	}
+	if table == "" {
+		return nil, nil, errors.New("table name is required, use Table or a model")
+	}
+	rd.Statement.Table = table
+	rd.Statement.Schema = sch
+
+	var conditions []condition
+	if c, ok := rd.Statement.Clauses["WHERE"]; ok {
+		if where, ok := c.Expression.(clause.Where); ok {
+			var err error
+			if conditions, err = parseWhere(where); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+
+	// soft deleted rows are skipped like gorm unless Unscoped
+	if sch != nil && !rd.Statement.Unscoped {
+		for _, field := range sch.Fields {
+			if field.FieldType == deletedAtType && field.DBName != "" {
+				conditions = append(conditions, condition{column: field.DBName, op: "is null"})
+			}
+		}
+	}
+
+	values, err := rd.fetch(TableKey(rd.ops.database, table), sch, conditions)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rows := make([]row, 0, len(values))
+	for _, item := range values {
+		r := make(row)
+		if err = json.Unmarshal([]byte(item), &r); err != nil {
+			return nil, nil, errors.Wrapf(err, "decode row of %s", table)
+		}
+		matched := true
+		for _, c := range conditions {
+			if !c.match(r) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			rows = append(rows, r)
+		}
+	}
+	rd.sort(rows, sch)
+	return rows, sch, nil
+}
+
+// fetch only reads the needed hash fields when the primary key is filtered, otherwise the whole table
+func (rd *Redis) fetch(key string, sch *schema.Schema, conditions []condition) ([]string, error) {
+	if sch != nil && len(sch.PrimaryFieldDBNames) == 1 {
+		for _, c := range conditions {
+			if c.column != sch.PrimaryFieldDBNames[0] || (c.op != "=" && c.op != "in") {
+				continue
+			}
+			fields := make([]string, 0, len(c.values))
+			for _, v := range c.values {
+				fields = append(fields, RowField(v))
+			}
+			if len(fields) == 0 {
+				return nil, nil
+			}
+			res, err := rd.ops.redis.HMGet(rd.Ctx, key, fields...).Result()
+			if err != nil {
+				return nil, err
+			}
+			values := make([]string, 0, len(res))
+			for _, item := range res {
+				if s, ok := item.(string); ok {
+					values = append(values, s)
+				}
+			}
+			return values, nil
+		}
+	}
+
+	res, err := rd.ops.redis.HGetAll(rd.Ctx, key).Result()
+	if err != nil {
+		return nil, err
+	}
+	values := make([]string, 0, len(res))
+	for _, item := range res {
+		values = append(values, item)
+	}
+	return values, nil
+}
+
+func (rd *Redis) sort(rows []row, sch *schema.Schema) {
+	c, ok := rd.Statement.Clauses["ORDER BY"]
+	if !ok {
+		return
+	}
+	orderBy, ok := c.Expression.(clause.OrderBy)
+	if !ok || len(orderBy.Columns) == 0 {
+		return
+	}
+	columns := make([]clause.OrderByColumn, 0, len(orderBy.Columns))
+	for _, item := range orderBy.Columns {
+		if item.Column.Name == clause.PrimaryKey {
+			if sch == nil || sch.PrioritizedPrimaryField == nil {
+				continue
+			}
+			item.Column.Name = sch.PrioritizedPrimaryField.DBName
+		}
+		item.Column.Name = columnName(item.Column.Name)
+		columns = append(columns, item)
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		for _, item := range columns {
+			n := compareNullable(rows[i][item.Column.Name], rows[j][item.Column.Name])
+			if n == 0 {
+				continue
+			}
+			if item.Desc {
+				return n > 0
+			}
+			return n < 0
+		}
+		return false
+	})
+}
+
+func (rd *Redis) paginate(rows []row) []row {
+	c, ok := rd.Statement.Clauses["LIMIT"]
+	if !ok {
+		return rows
+	}
+	limit, ok := c.Expression.(clause.Limit)
+	if !ok {
+		return rows
+	}
+	if limit.Offset > 0 {
+		if limit.Offset >= len(rows) {
+			return nil
+		}
+		rows = rows[limit.Offset:]
+	}
+	if limit.Limit != nil && *limit.Limit >= 0 && *limit.Limit < len(rows) {
+		rows = rows[:*limit.Limit]
+	}
+	return rows
+}
+
+// scan sets columns into the struct with gorm's field setters
+func (rd *Redis) scan(sch *schema.Schema, rv reflect.Value, r row) error {
+	if sch == nil {
+		return errors.New("scan requires a model")
+	}
+	for _, field := range sch.Fields {
+		if field.DBName == "" {
+			continue
+		}
+		v, ok := r[field.DBName]
+		if !ok || v == nil {
+			continue
+		}
+		value, err := parseValue(field, *v)
+		if err == nil {
+			err = field.Set(rd.Ctx, rv, value)
+		}
+		if err != nil {
+			return errors.Wrapf(err, "scan %s.%s", sch.Table, field.DBName)
+		}
+	}
+	return nil
+}
+
+// parseValue converts the stored string to the value a database driver would return,
+// so that pointer and Scanner fields(*bool, gorm.DeletedAt...) are set correctly
+func parseValue(field *schema.Field, s string) (interface{}, error) {
+	switch field.DataType {
+	case schema.Bool:
+		return strconv.ParseBool(s)
+	case schema.Int:
+		return strconv.ParseInt(s, 10, 64)
+	case schema.Uint:
+		return strconv.ParseUint(s, 10, 64)
+	case schema.Float:
+		return strconv.ParseFloat(s, 64)
+	case schema.Bytes:
+		return []byte(s), nil
+	case schema.Time:
+		for _, layout := range []string{TimeFormat, time.RFC3339Nano, "2006-01-02 15:04:05.999999999", "2006-01-02"} {
+			if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+				return t.In(time.Local), nil
+			}
+		}
+		return nil, errors.Errorf("invalid time %s", s)
+	}
+	return s, nil
+}
+
+// compareNullable sorts NULL first like mysql
+func compareNullable(a, b *string) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return -1
+	case b == nil:
+		return 1
+	}
+	return compareValue(*a, *b)
+}
@@ -0,0 +1,233 @@
+package query
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+type testUser struct {
+	Id        uint64 `gorm:"primaryKey"`
+	Username  string
+	Code      string
+	Age       int
+	Score     float64
+	Status    *bool
+	CreatedAt time.Time
+	DeletedAt gorm.DeletedAt
+}
+
+var testCreatedAt = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+// newTestRedis mirrors users into miniredis like the binlog service, columns of nil values are NULL
+func newTestRedis(t *testing.T, users ...map[string]interface{}) Redis {
+	mr := miniredis.RunT(t)
+	rd := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rd.Close() })
+	for _, u := range users {
+		r := make(map[string]*string, len(u))
+		for k, v := range u {
+			if s, ok := FormatValue(v); ok {
+				r[k] = &s
+			} else {
+				r[k] = nil
+			}
+		}
+		b, err := json.Marshal(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mr.HSet(TableKey("query_redis", "test_users"), RowField(u["id"]), string(b))
+	}
+	return NewRedis(WithRedisClient(rd), WithRedisNamingStrategy(schema.NamingStrategy{}))
+}
+
+// 2^53+1 and 2^53 are equal as float64
+const (
+	bigId1 uint64 = 1<<53 + 1
+	bigId2 uint64 = 1 << 53
+)
+
+func testUsers() []map[string]interface{} {
+	return []map[string]interface{}{
+		{"id": 1, "username": "jack", "code": "0123", "age": 18, "score": 9.5, "status": true, "created_at": testCreatedAt},
+		{"id": 2, "username": "Jane", "code": "123", "age": 20, "score": 10, "status": false, "created_at": testCreatedAt.Add(time.Hour)},
+		{"id": 3, "username": "tom", "code": "99", "age": 9, "score": 7.25, "status": nil, "created_at": testCreatedAt.Add(2 * time.Hour)},
+		{"id": bigId1, "username": "big1", "code": "1000", "age": -1, "score": -0.5, "status": true, "created_at": testCreatedAt},
+		{"id": bigId2, "username": "big2", "code": "-5", "age": 30, "score": 1e3, "status": true, "created_at": testCreatedAt},
+		{"id": 4, "username": "deleted", "code": "x", "age": 40, "score": 0, "status": nil, "created_at": testCreatedAt, "deleted_at": testCreatedAt},
+	}
+}
+
+func usernames(list []testUser) string {
+	names := ""
+	for i, u := range list {
+		if i > 0 {
+			names += ","
+		}
+		names += u.Username
+	}
+	return names
+}
+
+func TestRedisFind(t *testing.T) {
+	rd := newTestRedis(t, testUsers()...)
+	tests := []struct {
+		name  string
+		query func(rd *Redis) *Redis
+		want  string
+	}{
+		{"all ordered by id", func(rd *Redis) *Redis { return rd.Order("id") }, "jack,Jane,tom,big2,big1"},
+		{"big id equal", func(rd *Redis) *Redis { return rd.Where("id = ?", bigId1) }, "big1"},
+		{"big id greater", func(rd *Redis) *Redis { return rd.Where("id > ?", bigId2) }, "big1"},
+		{"big id in", func(rd *Redis) *Redis { return rd.Where("id IN ?", []uint64{bigId2, 3}).Order("id desc") }, "big2,tom"},
+		{"zero padded string", func(rd *Redis) *Redis { return rd.Where("code = ?", "123") }, "Jane"},
+		{"padded string is not a number", func(rd *Redis) *Redis { return rd.Where("code = ?", "0123") }, "jack"},
+		{"negative integer", func(rd *Redis) *Redis { return rd.Where("code < ?", 0).Order("id") }, "big2"},
+		{"integer column", func(rd *Redis) *Redis { return rd.Where("age >= ? AND age < ?", 10, 30).Order("age desc") }, "Jane,jack"},
+		{"decimal against integer", func(rd *Redis) *Redis { return rd.Where("score > ?", 9).Order("score") }, "jack,Jane,big2"},
+		{"exponent", func(rd *Redis) *Redis { return rd.Where("score = ?", "1000") }, "big2"},
+		{"between", func(rd *Redis) *Redis { return rd.Where("age BETWEEN ? AND ?", 9, 18).Order("id") }, "jack,tom"},
+		{"like is case-insensitive", func(rd *Redis) *Redis { return rd.Where("username LIKE ?", "j%").Order("id") }, "jack,Jane"},
+		{"not like", func(rd *Redis) *Redis { return rd.Where("username NOT LIKE ?", "%big%").Order("id") }, "jack,Jane,tom"},
+		{"is null", func(rd *Redis) *Redis { return rd.Where("status IS NULL") }, "tom"},
+		{"bool", func(rd *Redis) *Redis { return rd.Where("status = ?", false) }, "Jane"},
+		{"map", func(rd *Redis) *Redis { return rd.Where(map[string]interface{}{"username": "tom"}) }, "tom"},
+		{"time", func(rd *Redis) *Redis { return rd.Where("created_at > ?", testCreatedAt).Order("created_at desc") }, "tom,Jane"},
+		{"padded strings sort as strings", func(rd *Redis) *Redis { return rd.Where("id < ?", 3).Order("code") }, "jack,Jane"},
+		{"multiple orders", func(rd *Redis) *Redis { return rd.Order("created_at, id desc") }, "big1,big2,jack,Jane,tom"},
+		{"limit and offset", func(rd *Redis) *Redis { return rd.Order("id").Limit(2).Offset(1) }, "Jane,tom"},
+		{"offset beyond rows", func(rd *Redis) *Redis { return rd.Order("id").Offset(10) }, ""},
+		{"unscoped", func(rd *Redis) *Redis { return rd.Unscoped().Where("age = ?", 40) }, "deleted"},
+		{"table", func(rd *Redis) *Redis { return rd.Table("test_users").Where("id = ?", 2) }, "Jane"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var list []testUser
+			if err := tt.query(&rd).Find(&list).Error; err != nil {
+				t.Fatal(err)
+			}
+			if got := usernames(list); got != tt.want {
+				t.Errorf("users = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedisFirst(t *testing.T) {
+	rd := newTestRedis(t, testUsers()...)
+	var u testUser
+	if err := rd.Where("username = ?", "jack").First(&u).Error; err != nil {
+		t.Fatal(err)
+	}
+	if u.Id != 1 || u.Age != 18 || u.Score != 9.5 || u.Status == nil || !*u.Status || !u.CreatedAt.Equal(testCreatedAt) || u.DeletedAt.Valid {
+		t.Errorf("user = %+v", u)
+	}
+
+	// primary key order by default
+	var first testUser
+	if err := rd.Where("age > ?", 10).First(&first).Error; err != nil || first.Id != 1 {
+		t.Errorf("first = %+v, %v", first, err)
+	}
+	var last testUser
+	if err := rd.Order("id desc").First(&last).Error; err != nil || last.Id != bigId1 {
+		t.Errorf("last = %+v, %v, want id %d", last, err, bigId1)
+	}
+	if err := rd.Where("id = ?", 4).First(&testUser{}).Error; !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("soft deleted user err = %v", err)
+	}
+	if err := rd.Where("id = ?", 100).First(&testUser{}).Error; !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("missing user err = %v", err)
+	}
+	var list []testUser
+	if err := rd.First(&list).Error; err == nil {
+		t.Error("First accepts a slice")
+	}
+}
+
+func TestRedisCount(t *testing.T) {
+	rd := newTestRedis(t, testUsers()...)
+	tests := []struct {
+		name  string
+		query func(rd *Redis) *Redis
+		want  int64
+	}{
+		{"model", func(rd *Redis) *Redis { return rd.Model(&testUser{}) }, 5},
+		{"where", func(rd *Redis) *Redis { return rd.Model(&testUser{}).Where("age > ?", 10) }, 3},
+		{"limit is ignored", func(rd *Redis) *Redis { return rd.Model(&testUser{}).Limit(1) }, 5},
+		{"table keeps soft deleted rows", func(rd *Redis) *Redis { return rd.Table("test_users") }, 6},
+		{"empty table", func(rd *Redis) *Redis { return rd.Table("missing") }, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var n int64
+			if err := tt.query(&rd).Count(&n).Error; err != nil {
+				t.Fatal(err)
+			}
+			if n != tt.want {
+				t.Errorf("count = %d, want %d", n, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedisErrors(t *testing.T) {
+	rd := newTestRedis(t, testUsers()...)
+	tests := []struct {
+		name  string
+		query func(rd *Redis) *Redis
+	}{
+		{"or", func(rd *Redis) *Redis { return rd.Where("age = ? OR age = ?", 1, 2) }},
+		{"missing vars", func(rd *Redis) *Redis { return rd.Where("age = ? AND id = ?", 1) }},
+		{"where type", func(rd *Redis) *Redis { return rd.Where(1) }},
+		{"order type", func(rd *Redis) *Redis { return rd.Order(1) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var list []testUser
+			if err := tt.query(&rd).Find(&list).Error; err == nil {
+				t.Errorf("users = %s without error", usernames(list))
+			}
+		})
+	}
+	var n int64
+	if err := rd.Count(&n).Error; err == nil {
+		t.Error("Count without table or model succeeded")
+	}
+}
+
+func TestCompareValue(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1", "2", -1},
+		{"10", "9", 1},
+		{"-3", "2", -1},
+		{"-3", "-10", 1},
+		{"0", "-0", 0},
+		{fmt.Sprint(bigId1), fmt.Sprint(bigId2), 1},
+		{"18446744073709551615", "18446744073709551614", 1},
+		{"-9223372036854775808", "18446744073709551615", -1},
+		{"0123", "123", -1},
+		{"0123", "0123", 0},
+		{"1.5", "1", 1},
+		{"1e3", "1000", 0},
+		{"2.50", "2.5", 0},
+		{"abc", "abd", -1},
+		{"9", "10a", 1},
+		{"NaN", "1", 1},
+	}
+	for _, tt := range tests {
+		if got := compareValue(tt.a, tt.b); got != tt.want {
+			t.Errorf("compareValue(%s, %s) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package req
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/pkg/errors"
+
+	"github.com/ppxb/oreo-admin-go/pkg/resp"
+)
+
+// FieldError is a failed rule of a field, Field is the json path, e.g. users[0].mobile
+type FieldError struct {
+	Field string `json:"field"`
+	Tag   string `json:"tag"`
+	Msg   string `json:"msg"`
+}
+
+var invalidMsg = map[string]string{
+	LocaleZh: "请求参数格式错误",
+	LocaleEn: "invalid request parameters",
+}
+
+// Json binds the body into r, the failure is written to c and false is returned if r is invalid
+func Json(c *gin.Context, r interface{}) bool {
+	return bind(c, c.ShouldBindWith(r, binding.JSON))
+}
+
+// Query binds query params into r by form tags, e.g. form:"pageNum,default=1"
+func Query(c *gin.Context, r interface{}) bool {
+	return bind(c, c.ShouldBindWith(r, binding.Query))
+}
+
+// Uri binds path params into r by uri tags
+func Uri(c *gin.Context, r interface{}) bool {
+	return bind(c, c.ShouldBindUri(r))
+}
+
+func bind(c *gin.Context, err error) bool {
+	if err == nil {
+		return true
+	}
+	msg, details := Translate(err, c.GetHeader("Accept-Language"))
+	resp.FailWithData(c, msg, details)
+	return false
+}
+
+// Translate returns the first message and the details of err in the language of acceptLanguage,
+// errors of decoding(e.g. a string for an int field) have no details
+func Translate(err error, acceptLanguage string) (string, []FieldError) {
+	trans := translator(acceptLanguage)
+	var ves validator.ValidationErrors
+	if !errors.As(err, &ves) {
+		return invalidMsg[trans.Locale()], []FieldError{}
+	}
+	details := make([]FieldError, 0, len(ves))
+	for _, fe := range ves {
+		details = append(details, FieldError{
+			Field: fieldPath(fe.Namespace()),
+			Tag:   fe.Tag(),
+			Msg:   fe.Translate(trans),
+		})
+	}
+	return details[0].Msg, details
+}
+
+// fieldPath drops the struct name of namespace, e.g. createUserReq.users[0].mobile is users[0].mobile
+func fieldPath(namespace string) string {
+	for i := 0; i < len(namespace); i++ {
+		if namespace[i] == '.' {
+			return namespace[i+1:]
+		}
+	}
+	return namespace
+}
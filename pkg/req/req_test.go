@@ -0,0 +1,196 @@
+package req
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/pkg/errors"
+
+	"github.com/ppxb/oreo-admin-go/pkg/password"
+	"github.com/ppxb/oreo-admin-go/pkg/resp"
+)
+
+func TestRules(t *testing.T) {
+	type mobileReq struct {
+		Mobile string `json:"mobile" binding:"mobile"`
+	}
+	type passwordReq struct {
+		Password string `json:"password" binding:"password"`
+	}
+	tests := []struct {
+		name  string
+		req   interface{}
+		valid bool
+	}{
+		{"mobile", mobileReq{Mobile: "13800138000"}, true},
+		{"mobile 19x", mobileReq{Mobile: "19912345678"}, true},
+		{"mobile second digit", mobileReq{Mobile: "12800138000"}, false},
+		{"mobile short", mobileReq{Mobile: "1380013800"}, false},
+		{"mobile long", mobileReq{Mobile: "138001380001"}, false},
+		{"mobile country code", mobileReq{Mobile: "+8613800138000"}, false},
+		{"mobile empty", mobileReq{}, false},
+		{"password", passwordReq{Password: "abcdefg1"}, true},
+		{"password short", passwordReq{Password: "abcde1"}, false},
+		{"password long", passwordReq{Password: strings.Repeat("a", 32) + "1"}, false},
+		{"password no digit", passwordReq{Password: "abcdefgh"}, false},
+		{"password no lower", passwordReq{Password: "ABCDEFG1"}, false},
+		{"password continuous digits", passwordReq{Password: "abcd1234"}, false},
+		{"password chinese", passwordReq{Password: "abcdefg1密"}, false},
+	}
+	for _, tt := range tests {
+		err := binding.Validator.ValidateStruct(tt.req)
+		if (err == nil) != tt.valid {
+			t.Errorf("%s: err = %v, want valid %v", tt.name, err, tt.valid)
+		}
+	}
+}
+
+func TestLocale(t *testing.T) {
+	tests := []struct {
+		acceptLanguage string
+		want           string
+	}{
+		{"", LocaleZh},
+		{"en", LocaleEn},
+		{"en-US,en;q=0.9", LocaleEn},
+		{"en_GB", LocaleEn},
+		{"zh-CN,zh;q=0.9,en;q=0.8", LocaleZh},
+		{"fr-FR,en;q=0.8", LocaleEn},
+		{"fr", DefaultLocale},
+		{"*", DefaultLocale},
+		{"en;q=0.5, zh;q=0.9", LocaleZh},
+		{"zh;q=0, en;q=0.1", LocaleEn},
+		{"en;q=bad", LocaleEn},
+	}
+	for _, tt := range tests {
+		if got := Locale(tt.acceptLanguage); got != tt.want {
+			t.Errorf("Locale(%q) = %q, want %q", tt.acceptLanguage, got, tt.want)
+		}
+	}
+}
+
+type createUserReq struct {
+	Mobile   string `json:"mobile" binding:"required,mobile"`
+	Password string `json:"password" binding:"password"`
+	Users    []struct {
+		Name string `json:"name" binding:"required"`
+	} `json:"users" binding:"dive"`
+}
+
+func TestJson(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name           string
+		body           string
+		acceptLanguage string
+		msg            string
+		details        []FieldError
+	}{
+		{
+			name:           "zh",
+			body:           `{"mobile": "123", "password": "abcdefg1", "users": [{"name": "a"}]}`,
+			acceptLanguage: "zh-CN,zh;q=0.9",
+			msg:            "mobile必须是有效的手机号码",
+			details:        []FieldError{{Field: "mobile", Tag: "mobile", Msg: "mobile必须是有效的手机号码"}},
+		},
+		{
+			name:           "en",
+			body:           `{"mobile": "123", "password": "abcdefg1", "users": [{"name": "a"}]}`,
+			acceptLanguage: "en-US",
+			msg:            "mobile must be a valid mobile number",
+			details:        []FieldError{{Field: "mobile", Tag: "mobile", Msg: "mobile must be a valid mobile number"}},
+		},
+		{
+			name:           "password details",
+			body:           `{"mobile": "13800138000", "password": "abc", "users": []}`,
+			acceptLanguage: "en",
+			msg:            "password violates the password policy: at least 8 characters, a digit is required",
+			details: []FieldError{
+				{Field: "password", Tag: "password", Msg: "password violates the password policy: at least 8 characters, a digit is required"},
+			},
+		},
+		{
+			name:           "password details zh",
+			body:           `{"mobile": "13800138000", "password": "abc", "users": []}`,
+			acceptLanguage: "",
+			msg:            "password不符合密码策略: 至少8个字符, 需要包含数字",
+			details: []FieldError{
+				{Field: "password", Tag: "password", Msg: "password不符合密码策略: 至少8个字符, 需要包含数字"},
+			},
+		},
+		{
+			name:           "nested field and builtin rule",
+			body:           `{"password": "abcdefg1", "users": [{"name": "a"}, {}]}`,
+			acceptLanguage: "en",
+			msg:            "mobile is a required field",
+			details: []FieldError{
+				{Field: "mobile", Tag: "required", Msg: "mobile is a required field"},
+				{Field: "users[1].name", Tag: "required", Msg: "name is a required field"},
+			},
+		},
+		{
+			name:           "decoding error",
+			body:           `{"mobile": 13800138000}`,
+			acceptLanguage: "en",
+			msg:            "invalid request parameters",
+			details:        []FieldError{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(tt.body))
+			c.Request.Header.Set("Content-Type", "application/json")
+			c.Request.Header.Set("Accept-Language", tt.acceptLanguage)
+
+			var r createUserReq
+			if Json(c, &r) {
+				t.Fatal("invalid request is accepted")
+			}
+			var res struct {
+				Code int          `json:"code"`
+				Msg  string       `json:"msg"`
+				Data []FieldError `json:"data"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+				t.Fatal(err)
+			}
+			if res.Code != resp.NotOk || res.Msg != tt.msg {
+				t.Errorf("code %d msg %q, want %d %q", res.Code, res.Msg, resp.NotOk, tt.msg)
+			}
+			if !reflect.DeepEqual(res.Data, tt.details) {
+				t.Errorf("details = %+v, want %+v", res.Data, tt.details)
+			}
+		})
+	}
+}
+
+func TestPasswordMsg(t *testing.T) {
+	err := &password.PolicyError{Violations: []password.Violation{
+		{Rule: password.RuleContinuousNum, Param: 3},
+		{Rule: password.RuleHistory, Param: 5},
+	}}
+	tests := []struct {
+		err    error
+		locale string
+		want   string
+	}{
+		{err, LocaleEn, "no more than 3 continuous digits, must differ from the last 5 passwords"},
+		{err, LocaleZh, "不能包含超过3位的连续数字, 不能与最近5次使用的密码相同"},
+		{err, "fr", "不能包含超过3位的连续数字, 不能与最近5次使用的密码相同"},
+		{errors.Wrap(err, "change password"), LocaleEn, "no more than 3 continuous digits, must differ from the last 5 passwords"},
+		{errors.New("db down"), LocaleEn, ""},
+	}
+	for _, tt := range tests {
+		if got := PasswordMsg(tt.err, tt.locale); got != tt.want {
+			t.Errorf("PasswordMsg(%v, %s) = %q, want %q", tt.err, tt.locale, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package req
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	"github.com/pkg/errors"
+
+	"github.com/ppxb/oreo-admin-go/pkg/password"
+)
+
+var mobileRegexp = regexp.MustCompile(`^1[3-9]\d{9}$`)
+
+// rule is a custom validation tag with its messages, {1} is filled by detail if set
+type rule struct {
+	tag    string
+	fn     validator.Func
+	zh     string
+	en     string
+	detail func(fe validator.FieldError, locale string) string
+}
+
+var rules = []rule{
+	{
+		tag:    "password",
+		fn:     strongPassword,
+		zh:     "{0}不符合密码策略: {1}",
+		en:     "{0} violates the password policy: {1}",
+		detail: passwordDetail,
+	},
+	{
+		tag: "mobile",
+		fn:  mobile,
+		zh:  "{0}必须是有效的手机号码",
+		en:  "{0} must be a valid mobile number",
+	},
+}
+
+// violationMsg is the text of each password rule, %d is the param
+var violationMsg = map[string]map[string]string{
+	LocaleZh: {
+		password.RuleMinLength:     "至少%d个字符",
+		password.RuleMaxLength:     "最多%d个字符",
+		password.RuleLower:         "需要包含小写字母",
+		password.RuleUpper:         "需要包含大写字母",
+		password.RuleDigit:         "需要包含数字",
+		password.RuleSymbol:        "需要包含特殊字符",
+		password.RuleContinuousNum: "不能包含超过%d位的连续数字",
+		password.RuleChinese:       "不能包含中文",
+		password.RuleHistory:       "不能与最近%d次使用的密码相同",
+	},
+	LocaleEn: {
+		password.RuleMinLength:     "at least %d characters",
+		password.RuleMaxLength:     "at most %d characters",
+		password.RuleLower:         "a lowercase letter is required",
+		password.RuleUpper:         "an uppercase letter is required",
+		password.RuleDigit:         "a digit is required",
+		password.RuleSymbol:        "a special character is required",
+		password.RuleContinuousNum: "no more than %d continuous digits",
+		password.RuleChinese:       "chinese characters are not allowed",
+		password.RuleHistory:       "must differ from the last %d passwords",
+	},
+}
+
+func registerRule(v *validator.Validate, r rule, zhTrans, enTrans ut.Translator) error {
+	if err := v.RegisterValidation(r.tag, r.fn); err != nil {
+		return err
+	}
+	for trans, text := range map[ut.Translator]string{zhTrans: r.zh, enTrans: r.en} {
+		text := text
+		err := v.RegisterTranslation(r.tag, trans, func(t ut.Translator) error {
+			return t.Add(r.tag, text, true)
+		}, func(t ut.Translator, fe validator.FieldError) string {
+			params := []string{fe.Field()}
+			if r.detail != nil {
+				params = append(params, r.detail(fe, t.Locale()))
+			}
+			msg, _ := t.T(r.tag, params...)
+			return msg
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// strongPassword checks password.DefaultPolicy
+func strongPassword(fl validator.FieldLevel) bool {
+	return password.DefaultPolicy.Check(fl.Field().String()) == nil
+}
+
+func passwordDetail(fe validator.FieldError, locale string) string {
+	s, _ := fe.Value().(string)
+	return PasswordMsg(password.DefaultPolicy.Check(s), locale)
+}
+
+// PasswordMsg joins the broken rules of a *password.PolicyError in locale, e.g. for the history rule checked by handlers
+func PasswordMsg(err error, locale string) string {
+	var pe *password.PolicyError
+	if !errors.As(err, &pe) {
+		return ""
+	}
+	texts, ok := violationMsg[locale]
+	if !ok {
+		texts = violationMsg[DefaultLocale]
+	}
+	items := make([]string, 0, len(pe.Violations))
+	for _, v := range pe.Violations {
+		text := texts[v.Rule]
+		if strings.Contains(text, "%d") {
+			text = fmt.Sprintf(text, v.Param)
+		}
+		items = append(items, text)
+	}
+	return strings.Join(items, ", ")
+}
+
+func mobile(fl validator.FieldLevel) bool {
+	return mobileRegexp.MatchString(fl.Field().String())
+}
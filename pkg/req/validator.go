@@ -0,0 +1,110 @@
+package req
+
+import (
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/zh"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	enTranslations "github.com/go-playground/validator/v10/translations/en"
+	zhTranslations "github.com/go-playground/validator/v10/translations/zh"
+	"github.com/pkg/errors"
+)
+
+const (
+	LocaleZh = "zh"
+	LocaleEn = "en"
+	// DefaultLocale is used if Accept-Language matches no translator
+	DefaultLocale = LocaleZh
+)
+
+var uni *ut.UniversalTranslator
+
+// init sets up the validator of gin: json names in messages, zh/en translations and the custom rules
+func init() {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		panic("validator engine of gin is not go-playground/validator")
+	}
+	v.RegisterTagNameFunc(fieldName)
+
+	zhLocale := zh.New()
+	uni = ut.New(zhLocale, zhLocale, en.New())
+	zhTrans, _ := uni.GetTranslator(LocaleZh)
+	enTrans, _ := uni.GetTranslator(LocaleEn)
+	if err := zhTranslations.RegisterDefaultTranslations(v, zhTrans); err != nil {
+		panic(errors.Wrap(err, "register zh translations failed"))
+	}
+	if err := enTranslations.RegisterDefaultTranslations(v, enTrans); err != nil {
+		panic(errors.Wrap(err, "register en translations failed"))
+	}
+	for _, r := range rules {
+		if err := registerRule(v, r, zhTrans, enTrans); err != nil {
+			panic(errors.Wrapf(err, "register rule %s failed", r.tag))
+		}
+	}
+}
+
+// fieldName names fields by json, form or uri tags, e.g. Username `json:"username"` is username
+func fieldName(f reflect.StructField) string {
+	for _, tag := range []string{"json", "form", "uri"} {
+		name := strings.SplitN(f.Tag.Get(tag), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		if name != "" {
+			return name
+		}
+	}
+	return f.Name
+}
+
+// Locale is the locale of the Accept-Language header which has a translator, DefaultLocale otherwise
+func Locale(acceptLanguage string) string {
+	return translator(acceptLanguage).Locale()
+}
+
+// translator picks the translator of the Accept-Language header by weight, e.g. zh-CN,zh;q=0.9,en;q=0.8
+func translator(acceptLanguage string) ut.Translator {
+	type language struct {
+		locale string
+		q      float64
+	}
+	list := make([]language, 0, 2)
+	for _, item := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(item, ";")
+		tag = strings.TrimSpace(tag)
+		if tag == "" {
+			continue
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+		// q=0 means not acceptable
+		if q <= 0 {
+			continue
+		}
+		// zh-CN/en-US are matched by the language
+		list = append(list, language{
+			locale: strings.ToLower(strings.SplitN(strings.ReplaceAll(tag, "_", "-"), "-", 2)[0]),
+			q:      q,
+		})
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].q > list[j].q
+	})
+	locales := make([]string, 0, len(list))
+	for _, item := range list {
+		locales = append(locales, item.locale)
+	}
+	trans, _ := uni.FindTranslator(locales...)
+	return trans
+}
@@ -0,0 +1,70 @@
+package resp
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ppxb/oreo-admin-go/pkg/tracing"
+)
+
+const (
+	Ok                  = 200
+	NotOk               = 400
+	Unauthorized        = 401
+	Forbidden           = 403
+	InternalServerError = 500
+)
+
+const (
+	OkMsg                  = "success"
+	NotOkMsg               = "failed"
+	UnauthorizedMsg        = "unauthorized"
+	ForbiddenMsg           = "forbidden"
+	InternalServerErrorMsg = "internal server error"
+)
+
+var codeMsg = map[int]string{
+	Ok:                  OkMsg,
+	NotOk:               NotOkMsg,
+	Unauthorized:        UnauthorizedMsg,
+	Forbidden:           ForbiddenMsg,
+	InternalServerError: InternalServerErrorMsg,
+}
+
+// Result writes Resp with the request id of c, the http status is always 200 and code tells the result
+func Result(c *gin.Context, code int, msg string, data interface{}) {
+	requestId, _, _ := tracing.GetId(c)
+	if msg == "" {
+		msg = codeMsg[code]
+	}
+	c.JSON(http.StatusOK, Resp{
+		Code:      code,
+		Data:      data,
+		Msg:       msg,
+		RequestId: requestId,
+	})
+}
+
+func Success(c *gin.Context) {
+	Result(c, Ok, OkMsg, map[string]interface{}{})
+}
+
+func SuccessWithData(c *gin.Context, data interface{}) {
+	Result(c, Ok, OkMsg, data)
+}
+
+func FailWithMsg(c *gin.Context, msg string) {
+	Result(c, NotOk, msg, map[string]interface{}{})
+}
+
+// FailWithData carries details of the failure, e.g. field errors of a request
+func FailWithData(c *gin.Context, msg string, data interface{}) {
+	Result(c, NotOk, msg, data)
+}
+
+// FailWithCode aborts the chain, used by middlewares
+func FailWithCode(c *gin.Context, code int) {
+	Result(c, code, "", map[string]interface{}{})
+	c.Abort()
+}
@@ -36,7 +36,7 @@ func envToInterface(m map[string]interface{}, prefix string, fun func(key string
 			env := strings.TrimSpace(os.Getenv(newKey))
 			if env != "" {
 				newMap[key] = env
-				log.Info("[ENV TO INTERFACE] Get %v", fun(newKey, newMap[key]))
+				log.WithComponent("env").Info("Get env", "value", fun(newKey, newMap[key]))
 				continue
 			}
 		case bool:
@@ -47,11 +47,11 @@ func envToInterface(m map[string]interface{}, prefix string, fun func(key string
 				if ok && err == nil {
 					if itemB && !b {
 						newMap[key] = false
-						log.Info("[ENV TO INTERFACE] Get %v", fun(newKey, newMap[key]))
+						log.WithComponent("env").Info("Get env", "value", fun(newKey, newMap[key]))
 						continue
 					} else if !itemB && b {
 						newMap[key] = true
-						log.Info("[ENV TO INTERFACE] Get %v", fun(newKey, newMap[key]))
+						log.WithComponent("env").Info("Get env", "value", fun(newKey, newMap[key]))
 						continue
 					}
 				}
@@ -62,7 +62,7 @@ func envToInterface(m map[string]interface{}, prefix string, fun func(key string
 				v, err := strconv.ParseFloat(e, 64)
 				if err == nil {
 					newMap[key] = v
-					log.Info("[ENV TO INTERFACE] Get %v", fun(newKey, newMap[key]))
+					log.WithComponent("env").Info("Get env", "value", fun(newKey, newMap[key]))
 					continue
 				}
 			}
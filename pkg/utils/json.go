@@ -9,7 +9,7 @@ import (
 func Struct2Json(obj interface{}) string {
 	str, err := json.Marshal(obj)
 	if err != nil {
-		log.Error("[STRUCT2JSON] Can not convert: %v", err)
+		log.WithComponent("json").WithError(err).Error("Can not convert")
 	}
 	return string(str)
 }
@@ -17,7 +17,7 @@ func Struct2Json(obj interface{}) string {
 func Json2Struct(str string, obj interface{}) {
 	err := json.Unmarshal([]byte(str), obj)
 	if err != nil {
-		log.Error("[JSON2Struct] Can not convert: %v", err)
+		log.WithComponent("json").WithError(err).Error("Can not convert")
 	}
 }
 
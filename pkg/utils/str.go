@@ -138,37 +138,28 @@ func StrContainsChinese(str string) (exists bool) {
 	return
 }
 
+// StrContainsContinuousNum is the longest ascending or descending run of adjacent digits,
+// e.g. 4 of ab1234 and 1 of a1b2c3d4
 func StrContainsContinuousNum(str string) int {
-	arr := strings.Split(regexp.MustCompile("[^0-9]+").ReplaceAllString(str, ""), "")
-	l := len(arr)
-	maxCount := 0
-	if l > 0 {
-		count := 0
-		lastItem := Str2Int(arr[0])
-		direction := 0
-		for i := 1; i < l; i++ {
-			item := Str2Int(arr[i])
-			if item == lastItem+1 {
-				if direction == 1 && count >= 0 {
-					direction = 0
-					count = 0
-				}
-				count++
-			} else if item == lastItem-1 {
-				if direction == 0 && count >= 0 {
-					direction = 1
-					count = 0
-				}
-				count++
-			} else {
-				direction = 0
-				count = 0
-			}
-			lastItem = item
-			if count+1 > maxCount {
-				maxCount = count + 1
-			}
+	longest, n, step := 0, 0, rune(0)
+	var last rune
+	for _, r := range str {
+		if r < '0' || r > '9' {
+			n, step = 0, 0
+			continue
 		}
+		d := r - last
+		switch {
+		case n > 0 && (d == 1 || d == -1) && (step == 0 || step == d):
+			n++
+		case n > 0 && (d == 1 || d == -1):
+			// the direction changes, e.g. 1232, the last digit starts the new run
+			n = 2
+		default:
+			n, d = 1, 0
+		}
+		step, last = d, r
+		longest = max(longest, n)
 	}
-	return maxCount
+	return longest
 }
@@ -0,0 +1,27 @@
+package utils
+
+import "testing"
+
+func TestStrContainsContinuousNum(t *testing.T) {
+	tests := []struct {
+		s    string
+		want int
+	}{
+		{"", 0},
+		{"abc", 0},
+		{"7", 1},
+		{"a1b2c3d4", 1},
+		{"135", 1},
+		{"12", 2},
+		{"x1234y", 4},
+		{"9876", 4},
+		{"12321", 3},
+		{"1223", 2},
+		{"12a3456", 4},
+	}
+	for _, tt := range tests {
+		if got := StrContainsContinuousNum(tt.s); got != tt.want {
+			t.Errorf("StrContainsContinuousNum(%q) = %d, want %d", tt.s, got, tt.want)
+		}
+	}
+}
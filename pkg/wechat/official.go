@@ -0,0 +1,283 @@
+package wechat
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/ppxb/oreo-admin-go/pkg/log"
+	"github.com/ppxb/oreo-admin-go/pkg/utils"
+)
+
+// token errors of wechat, the cached token is dropped and the call is retried once
+var tokenErrCodes = map[int]bool{
+	40001: true,
+	40014: true,
+	42001: true,
+}
+
+// dropTokenScript deletes the token only if it is still the bad one, another node may have refreshed it
+var dropTokenScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// Error is an errcode returned by wechat
+type Error struct {
+	Code int    `json:"errcode"`
+	Msg  string `json:"errmsg"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("wechat errcode %d: %s", e.Code, e.Msg)
+}
+
+// RequestError is a failed http call, it leaves out the query which carries the app secret or the access token
+type RequestError struct {
+	Method string
+	Path   string
+	Err    error
+}
+
+func (e *RequestError) Error() string {
+	return fmt.Sprintf("wechat %s %s: %v", e.Method, e.Path, e.Err)
+}
+
+func (e *RequestError) Unwrap() error {
+	return e.Err
+}
+
+// Official is a client of the official account api
+type Official struct {
+	ops Options
+	// refresh serializes refreshes of this node
+	refresh sync.Mutex
+	// token caches the token without redis
+	lock     sync.RWMutex
+	token    string
+	expireAt time.Time
+	// rd is ops.redis or the client attached by UseRedis, guarded by lock
+	rd redis.UniversalClient
+}
+
+func NewOfficial(options ...func(*Options)) (*Official, error) {
+	ops := getOptionsOrSetDefault(nil)
+	for _, f := range options {
+		f(ops)
+	}
+	if ops.appId == "" || ops.appSecret == "" {
+		return nil, errors.New("wechat app id or secret is empty")
+	}
+	ops.baseUrl = strings.TrimSuffix(ops.baseUrl, "/")
+	return &Official{ops: *ops, rd: ops.redis}, nil
+}
+
+// UseRedis shares the token with other nodes from now on, e.g. redis connected after a degraded start,
+// the token cached in memory is dropped and refreshed once under the lock
+func (o *Official) UseRedis(rd redis.UniversalClient) {
+	if utils.InterfaceIsNil(rd) {
+		return
+	}
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	if o.rd == nil {
+		o.rd = rd
+		o.token = ""
+		o.expireAt = time.Time{}
+	}
+}
+
+func (o *Official) client() redis.UniversalClient {
+	o.lock.RLock()
+	defer o.lock.RUnlock()
+	return o.rd
+}
+
+// AccessToken returns the cached token, or refreshes it under the lock
+func (o *Official) AccessToken(ctx context.Context) (string, error) {
+	if token, err := o.cachedToken(ctx); token != "" || err != nil {
+		return token, err
+	}
+
+	o.refresh.Lock()
+	defer o.refresh.Unlock()
+	if token, err := o.cachedToken(ctx); token != "" || err != nil {
+		return token, err
+	}
+	if o.ops.locker != nil {
+		lockCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		defer cancel()
+		lease, err := o.ops.locker.Lock(lockCtx, o.ops.prefix+"_token_"+o.ops.appId)
+		if err != nil {
+			return "", errors.Wrap(err, "lock wechat access token")
+		}
+		defer func() {
+			if err := lease.Unlock(context.Background()); err != nil {
+				log.WithContext(ctx).WithError(err).WithComponent("wechat").Warn("Release token lock failed")
+			}
+		}()
+		// another node may have refreshed it while waiting
+		if token, err := o.cachedToken(ctx); token != "" || err != nil {
+			return token, err
+		}
+	}
+	return o.refreshToken(ctx)
+}
+
+func (o *Official) cachedToken(ctx context.Context) (string, error) {
+	rd := o.client()
+	if rd == nil {
+		o.lock.RLock()
+		defer o.lock.RUnlock()
+		if time.Now().Before(o.expireAt) {
+			return o.token, nil
+		}
+		return "", nil
+	}
+	token, err := rd.Get(ctx, o.tokenKey()).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", nil
+	}
+	if err != nil {
+		return "", errors.Wrap(err, "get wechat access token")
+	}
+	return token, nil
+}
+
+func (o *Official) refreshToken(ctx context.Context) (string, error) {
+	var res struct {
+		Error
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	query := url.Values{}
+	query.Set("grant_type", "client_credential")
+	query.Set("appid", o.ops.appId)
+	query.Set("secret", o.ops.appSecret)
+	if err := o.do(ctx, http.MethodGet, "/cgi-bin/token?"+query.Encode(), nil, &res); err != nil {
+		return "", errors.Wrap(err, "refresh wechat access token")
+	}
+	if res.Code != 0 {
+		return "", errors.Wrap(&res.Error, "refresh wechat access token")
+	}
+
+	ttl := time.Duration(res.ExpiresIn)*time.Second - o.ops.margin
+	if ttl <= 0 {
+		ttl = time.Duration(res.ExpiresIn) * time.Second / 2
+	}
+	if rd := o.client(); rd != nil {
+		if err := rd.Set(ctx, o.tokenKey(), res.AccessToken, ttl).Err(); err != nil {
+			return "", errors.Wrap(err, "save wechat access token")
+		}
+	} else {
+		o.lock.Lock()
+		o.token = res.AccessToken
+		o.expireAt = time.Now().Add(ttl)
+		o.lock.Unlock()
+	}
+	log.WithContext(ctx).WithComponent("wechat").Info("Access token refreshed", "appId", o.ops.appId, "expiresIn", ttl)
+	return res.AccessToken, nil
+}
+
+// dropToken forgets a token rejected by wechat
+func (o *Official) dropToken(ctx context.Context, token string) {
+	rd := o.client()
+	if rd == nil {
+		o.lock.Lock()
+		if o.token == token {
+			o.token = ""
+			o.expireAt = time.Time{}
+		}
+		o.lock.Unlock()
+		return
+	}
+	if err := dropTokenScript.Run(ctx, rd, []string{o.tokenKey()}, token).Err(); err != nil {
+		log.WithContext(ctx).WithError(err).WithComponent("wechat").Warn("Drop access token failed")
+	}
+}
+
+func (o *Official) tokenKey() string {
+	return o.ops.prefix + ":access_token:" + o.ops.appId
+}
+
+// call posts body to path with the access token, the token is refreshed once if wechat rejects it,
+// res must embed Error
+func (o *Official) call(ctx context.Context, path string, body interface{}, res interface{ err() *Error }) error {
+	for i := 0; ; i++ {
+		token, err := o.AccessToken(ctx)
+		if err != nil {
+			return err
+		}
+		if err = o.do(ctx, http.MethodPost, path+"?access_token="+url.QueryEscape(token), body, res); err != nil {
+			return err
+		}
+		e := res.err()
+		if e.Code == 0 {
+			return nil
+		}
+		if i == 0 && tokenErrCodes[e.Code] {
+			log.WithContext(ctx).WithComponent("wechat").Warn("Access token is rejected, refresh and retry", "errCode", e.Code)
+			o.dropToken(ctx, token)
+			*e = Error{}
+			continue
+		}
+		return e
+	}
+}
+
+// do sends the request, errors are RequestError so that logs and stored messages never contain the query
+func (o *Official) do(ctx context.Context, method, path string, body, res interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(b)
+	}
+	name, _, _ := strings.Cut(path, "?")
+	req, err := http.NewRequestWithContext(ctx, method, o.ops.baseUrl+path, reader)
+	if err != nil {
+		return newRequestError(method, name, err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	r, err := o.ops.httpClient.Do(req)
+	if err != nil {
+		return newRequestError(method, name, err)
+	}
+	defer r.Body.Close()
+	if r.StatusCode != http.StatusOK {
+		return newRequestError(method, name, errors.Errorf("http status %d", r.StatusCode))
+	}
+	if err = json.NewDecoder(r.Body).Decode(res); err != nil {
+		return newRequestError(method, name, errors.Wrap(err, "decode response"))
+	}
+	return nil
+}
+
+// newRequestError drops the url of *url.Error, it contains the full query
+func newRequestError(method, path string, err error) error {
+	var e *url.Error
+	if errors.As(err, &e) {
+		err = e.Err
+	}
+	return &RequestError{Method: method, Path: path, Err: err}
+}
+
+func (e *Error) err() *Error {
+	return e
+}
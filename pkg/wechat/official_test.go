@@ -0,0 +1,201 @@
+package wechat
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/pkg/errors"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	testAppId     = "wx-app"
+	testAppSecret = "top-secret"
+)
+
+// stub is a local wechat api, sendCodes are the errcodes of successive sends(0 after them)
+type stub struct {
+	lock      sync.Mutex
+	refreshes int
+	sends     []TemplateMessage
+	tokens    []string
+	sendCodes []int
+}
+
+func newStub(t *testing.T, s *stub) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		switch r.URL.Path {
+		case "/cgi-bin/token":
+			if r.URL.Query().Get("secret") != testAppSecret {
+				_ = json.NewEncoder(w).Encode(Error{Code: 40125, Msg: "invalid appsecret"})
+				return
+			}
+			s.refreshes++
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"access_token": fmt.Sprintf("token-%d", s.refreshes),
+				"expires_in":   7200,
+			})
+		case "/cgi-bin/message/template/send":
+			var msg TemplateMessage
+			_ = json.NewDecoder(r.Body).Decode(&msg)
+			s.sends = append(s.sends, msg)
+			s.tokens = append(s.tokens, r.URL.Query().Get("access_token"))
+			code := 0
+			if len(s.sendCodes) > 0 {
+				code, s.sendCodes = s.sendCodes[0], s.sendCodes[1:]
+			}
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"errcode": code,
+				"msgid":   len(s.sends),
+			})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func newTestOfficial(t *testing.T, baseUrl string, options ...func(*Options)) *Official {
+	o, err := NewOfficial(append([]func(*Options){
+		WithAppId(testAppId),
+		WithAppSecret(testAppSecret),
+		WithBaseUrl(baseUrl),
+	}, options...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return o
+}
+
+func testMessage() TemplateMessage {
+	return TemplateMessage{
+		ToUser:      "openid",
+		TemplateId:  "tpl",
+		Data:        map[string]TemplateValue{"first": {Value: "hi"}},
+		ClientMsgId: "task_openid",
+	}
+}
+
+func TestTokenCached(t *testing.T) {
+	mr := miniredis.RunT(t)
+	rd := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rd.Close() })
+
+	tests := []struct {
+		name string
+		// nodes share the token over redis
+		options []func(*Options)
+		nodes   int
+	}{
+		{"memory", nil, 1},
+		{"redis", []func(*Options){WithRedis(rd)}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mr.FlushAll()
+			s := &stub{}
+			srv := newStub(t, s)
+			for i := 0; i < tt.nodes; i++ {
+				o := newTestOfficial(t, srv.URL, tt.options...)
+				for j := 0; j < 3; j++ {
+					if _, err := o.SendTemplateMessage(context.Background(), testMessage()); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+			if s.refreshes != 1 {
+				t.Errorf("refreshes = %d, want 1", s.refreshes)
+			}
+			for _, token := range s.tokens {
+				if token != "token-1" {
+					t.Errorf("sent with token %q", token)
+				}
+			}
+		})
+	}
+}
+
+func TestTokenRejected(t *testing.T) {
+	tests := []struct {
+		name      string
+		sendCodes []int
+		wantCode  int
+		// sends and refreshes seen by wechat
+		sends     int
+		refreshes int
+	}{
+		{"refreshed once", []int{40001}, 0, 2, 2},
+		{"rejected again", []int{42001, 40001}, 40001, 2, 2},
+		{"not a token error", []int{43004}, 43004, 1, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &stub{sendCodes: tt.sendCodes}
+			o := newTestOfficial(t, newStub(t, s).URL)
+			_, err := o.SendTemplateMessage(context.Background(), testMessage())
+			var e *Error
+			switch {
+			case tt.wantCode == 0 && err != nil:
+				t.Fatal(err)
+			case tt.wantCode != 0 && (!errors.As(err, &e) || e.Code != tt.wantCode):
+				t.Fatalf("err = %v, want errcode %d", err, tt.wantCode)
+			}
+			if len(s.sends) != tt.sends || s.refreshes != tt.refreshes {
+				t.Fatalf("sends = %d, refreshes = %d, want %d, %d", len(s.sends), s.refreshes, tt.sends, tt.refreshes)
+			}
+			// the retry uses the new token and the same client_msg_id so that wechat deduplicates it
+			if tt.sends == 2 {
+				if s.tokens[0] == s.tokens[1] {
+					t.Errorf("retried with the rejected token %q", s.tokens[1])
+				}
+				for _, msg := range s.sends {
+					if msg.ClientMsgId != "task_openid" {
+						t.Errorf("client_msg_id = %q", msg.ClientMsgId)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestRequestErrorHidesQuery(t *testing.T) {
+	s := &stub{}
+	srv := newStub(t, s)
+	o := newTestOfficial(t, srv.URL)
+	token, err := o.AccessToken(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.Close()
+
+	_, err = o.SendTemplateMessage(context.Background(), testMessage())
+	if err == nil {
+		t.Fatal("send to a closed server succeeded")
+	}
+	var e *RequestError
+	if !errors.As(err, &e) || e.Path != "/cgi-bin/message/template/send" {
+		t.Fatalf("err = %#v, want RequestError", err)
+	}
+	if strings.Contains(err.Error(), token) {
+		t.Errorf("error contains the access token: %v", err)
+	}
+
+	o = newTestOfficial(t, srv.URL)
+	_, err = o.AccessToken(context.Background())
+	if err == nil {
+		t.Fatal("refresh from a closed server succeeded")
+	}
+	if strings.Contains(err.Error(), testAppSecret) {
+		t.Errorf("error contains the app secret: %v", err)
+	}
+}
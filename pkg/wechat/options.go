@@ -0,0 +1,102 @@
+package wechat
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/ppxb/oreo-admin-go/pkg/lock"
+	"github.com/ppxb/oreo-admin-go/pkg/utils"
+)
+
+type Options struct {
+	ctx        context.Context
+	appId      string
+	appSecret  string
+	baseUrl    string
+	redis      redis.UniversalClient
+	locker     lock.Locker
+	httpClient *http.Client
+	prefix     string
+	// margin refreshes the token before wechat expires it
+	margin time.Duration
+}
+
+func WithCtx(ctx context.Context) func(*Options) {
+	return func(options *Options) {
+		if !utils.InterfaceIsNil(ctx) {
+			getOptionsOrSetDefault(options).ctx = ctx
+		}
+	}
+}
+
+func WithAppId(s string) func(*Options) {
+	return func(options *Options) {
+		getOptionsOrSetDefault(options).appId = s
+	}
+}
+
+func WithAppSecret(s string) func(*Options) {
+	return func(options *Options) {
+		getOptionsOrSetDefault(options).appSecret = s
+	}
+}
+
+// WithBaseUrl replaces https://api.weixin.qq.com, e.g. a proxy or a local stub
+func WithBaseUrl(s string) func(*Options) {
+	return func(options *Options) {
+		if s != "" {
+			getOptionsOrSetDefault(options).baseUrl = s
+		}
+	}
+}
+
+// WithRedis shares the access token between nodes, it is kept in memory without redis
+func WithRedis(rd redis.UniversalClient) func(*Options) {
+	return func(options *Options) {
+		if !utils.InterfaceIsNil(rd) {
+			getOptionsOrSetDefault(options).redis = rd
+		}
+	}
+}
+
+// WithLocker lets one node refresh the token at a time, a refresh invalidates the token of other nodes
+func WithLocker(l lock.Locker) func(*Options) {
+	return func(options *Options) {
+		if !utils.InterfaceIsNil(l) {
+			getOptionsOrSetDefault(options).locker = l
+		}
+	}
+}
+
+func WithHttpClient(c *http.Client) func(*Options) {
+	return func(options *Options) {
+		if c != nil {
+			getOptionsOrSetDefault(options).httpClient = c
+		}
+	}
+}
+
+// WithPrefix namespaces the redis key and the lock name of the token
+func WithPrefix(s string) func(*Options) {
+	return func(options *Options) {
+		if s != "" {
+			getOptionsOrSetDefault(options).prefix = s
+		}
+	}
+}
+
+func getOptionsOrSetDefault(options *Options) *Options {
+	if options == nil {
+		return &Options{
+			ctx:        context.Background(),
+			baseUrl:    "https://api.weixin.qq.com",
+			httpClient: &http.Client{Timeout: 10 * time.Second},
+			prefix:     "wechat",
+			margin:     5 * time.Minute,
+		}
+	}
+	return options
+}
@@ -0,0 +1,38 @@
+package wechat
+
+import (
+	"context"
+)
+
+// TemplateMessage is a template message, MiniProgram opens a page instead of Url if the client supports it
+type TemplateMessage struct {
+	ToUser      string                   `json:"touser"`
+	TemplateId  string                   `json:"template_id"`
+	Url         string                   `json:"url,omitempty"`
+	MiniProgram *MiniProgram             `json:"miniprogram,omitempty"`
+	Data        map[string]TemplateValue `json:"data"`
+	// ClientMsgId deduplicates retries of the same message
+	ClientMsgId string `json:"client_msg_id,omitempty"`
+}
+
+type MiniProgram struct {
+	AppId    string `json:"appid"`
+	PagePath string `json:"pagepath,omitempty"`
+}
+
+type TemplateValue struct {
+	Value string `json:"value"`
+	Color string `json:"color,omitempty"`
+}
+
+// SendTemplateMessage returns the msg id of the sent message
+func (o *Official) SendTemplateMessage(ctx context.Context, msg TemplateMessage) (int64, error) {
+	var res struct {
+		Error
+		MsgId int64 `json:"msgid"`
+	}
+	if err := o.call(ctx, "/cgi-bin/message/template/send", msg, &res); err != nil {
+		return 0, err
+	}
+	return res.MsgId, nil
+}